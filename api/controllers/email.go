@@ -6,6 +6,9 @@ import (
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/optimatiq/threatbite/email"
+	"github.com/optimatiq/threatbite/locallist"
+	"github.com/optimatiq/threatbite/scoring"
+	"github.com/optimatiq/threatbite/velocity"
 )
 
 // EmailResult response object, which contains detailed information returned from Check method.
@@ -17,17 +20,30 @@ type EmailResult struct {
 	Disposal      bool  `json:"disposal"`
 	Free          bool  `json:"free"`
 	Leaked        bool  `json:"leaked"`
+	Threat        bool  `json:"threat"`
 	Valid         bool  `json:"valid"`
+
+	// LocalHistory is reputation of the address built from outcome feedback (-100 - 100).
+	LocalHistory int `json:"local_history,omitempty"`
+
+	// List is the name of the local list, which contains the address or its domain and decided about the scoring.
+	List string `json:"list,omitempty"`
+
+	// Signals contains names of velocity signals, which lowered the scoring.
+	Signals []string `json:"signals,omitempty"`
 }
 
 // Email is a controller container with the cache.
 type Email struct {
 	cache     *lru.Cache
 	emailInfo *email.Email
+	velocity  *velocity.Velocity
+	lists     *locallist.Store
 }
 
-// NewEmail creates an Email scoring controller.
-func NewEmail(emailInfo *email.Email) (*Email, error) {
+// NewEmail creates an Email scoring controller, velocity (optional) counts addresses per domain.
+// Addresses and domains on local lists (optional) get the best or the worst scoring.
+func NewEmail(emailInfo *email.Email, v *velocity.Velocity, lists *locallist.Store) (*Email, error) {
 	cache, err := lru.New(4096)
 	if err != nil {
 		return nil, err
@@ -36,6 +52,8 @@ func NewEmail(emailInfo *email.Email) (*Email, error) {
 	return &Email{
 		cache:     cache,
 		emailInfo: emailInfo,
+		velocity:  v,
+		lists:     lists,
 	}, nil
 }
 
@@ -56,13 +74,54 @@ func (e *Email) Check(address string) (*EmailResult, error) {
 		return nil, ErrInvalidEmail
 	}
 
+	var result *EmailResult
 	if v, ok := e.cache.Get(address); ok {
-		return v.(*EmailResult), nil
+		result = v.(*EmailResult)
+	} else {
+		result = newEmailResult(e.emailInfo.GetInfo(address))
+		e.cache.Add(address, result)
 	}
 
-	info := e.emailInfo.GetInfo(address)
+	if signals := e.velocity.ObserveEmailDomain(emailDomain(address, result)); len(signals) > 0 {
+		result = withEmailSignals(result, signals)
+	}
+	if match := e.lists.Match(nil, 0, address); match.Type != "" {
+		adjusted := *result
+		adjusted.List = match.List
+		adjusted.Scoring, _ = listDecision(match)
+		return &adjusted, nil
+	}
+	return result, nil
+}
 
-	result := &EmailResult{
+// Forget removes the address from the cache, so the next check uses updated local history.
+func (e *Email) Forget(address string) {
+	e.cache.Remove(address)
+}
+
+// emailDomain returns domain of the address, which is counted by velocity.
+// Free email providers are skipped, because they are used by many unrelated users.
+func emailDomain(address string, result *EmailResult) string {
+	if result == nil || result.Free {
+		return ""
+	}
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return address[i+1:]
+	}
+	return ""
+}
+
+// withEmailSignals returns copy of the (cached) result with scoring lowered by signals.
+func withEmailSignals(result *EmailResult, signals scoring.Signals) *EmailResult {
+	adjusted := *result
+	adjusted.Scoring = signals.Score(int(result.Scoring))
+	adjusted.Signals = signals.Names()
+	return &adjusted
+}
+
+// newEmailResult converts email information into response object.
+func newEmailResult(info email.Info) *EmailResult {
+	return &EmailResult{
 		Scoring:       info.EmailScoring,
 		AccountExists: info.IsExistingAccount,
 		CatchAll:      info.IsCatchAll,
@@ -70,12 +129,8 @@ func (e *Email) Check(address string) (*EmailResult, error) {
 		Disposal:      info.IsDisposal,
 		Free:          info.IsFree,
 		Leaked:        info.IsLeaked,
+		Threat:        info.IsThreat,
 		Valid:         info.IsValid,
+		LocalHistory:  info.LocalHistory,
 	}
-
-	if !e.cache.Contains(address) {
-		e.cache.Add(address, result)
-	}
-
-	return result, nil
 }
@@ -9,3 +9,6 @@ var ErrInvalidIP = errors.New("invalid IP address")
 
 // ErrInvalidEmail indicates that email is invalid
 var ErrInvalidEmail = errors.New("invalid email")
+
+// ErrMissingSubject indicates that feedback doesn't refer to IP address, email or request
+var ErrMissingSubject = errors.New("ip, email or requestid is required")
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"net"
+
+	"github.com/go-playground/validator"
+	"github.com/optimatiq/threatbite/credential"
+	"github.com/optimatiq/threatbite/reputation"
+)
+
+// LoginFeedback is a result of the login attempt reported by the protected application.
+type LoginFeedback struct {
+	IP           string `json:"ip" form:"ip" validate:"required,ip"`
+	UsernameHash string `json:"username_hash" form:"username_hash" validate:"omitempty,max=128"`
+	Success      bool   `json:"success" form:"success"`
+}
+
+// OutcomeFeedback is an outcome reported for IP address, email address or scored request (request ID).
+type OutcomeFeedback struct {
+	IP        string `json:"ip" form:"ip" validate:"omitempty,ip"`
+	Email     string `json:"email" form:"email" validate:"omitempty,email"`
+	RequestID string `json:"requestid" form:"requestid" validate:"omitempty,max=64"`
+	Outcome   string `json:"outcome" form:"outcome" validate:"required,oneof=chargeback fraud good spam_complaint"`
+}
+
+// Feedback is a controller, which receives feedback about results of scored requests.
+type Feedback struct {
+	validator   *validator.Validate
+	credentials *credential.Guard
+	reputation  *reputation.Store
+}
+
+// NewFeedback creates feedback controller, failed logins are recorded in credentials guard,
+// outcomes in local reputation store.
+func NewFeedback(credentials *credential.Guard, store *reputation.Store) *Feedback {
+	return &Feedback{
+		validator:   validator.New(),
+		credentials: credentials,
+		reputation:  store,
+	}
+}
+
+// Validate returns nil if provided data (LoginFeedback or OutcomeFeedback) is valid,
+// otherwise, returns error, which can be presented to the user.
+func (f *Feedback) Validate(feedback interface{}) error {
+	return f.validator.Struct(feedback)
+}
+
+// Login records result of the login attempt, only failed attempts are counted.
+func (f *Feedback) Login(feedback LoginFeedback) error {
+	ip := net.ParseIP(feedback.IP)
+	if ip == nil {
+		return ErrInvalidIP
+	}
+
+	if !feedback.Success {
+		f.credentials.Failed(ip, feedback.UsernameHash)
+	}
+	return nil
+}
+
+// Track remembers IP address and email of the scored request, so outcome can be reported with request ID.
+func (f *Feedback) Track(requestID string, request RequestQuery) {
+	f.reputation.Track(requestID, reputation.Subject{IP: request.IP, Email: request.Email})
+}
+
+// Outcome records outcome in local reputation, fields missing in feedback are taken from the tracked request.
+// Subject, which reputation was changed, is returned.
+func (f *Feedback) Outcome(feedback OutcomeFeedback) (reputation.Subject, error) {
+	subject := reputation.Subject{IP: feedback.IP, Email: feedback.Email}
+	if feedback.RequestID != "" {
+		tracked, err := f.reputation.Resolve(feedback.RequestID)
+		if err != nil {
+			return subject, err
+		}
+		if subject.IP == "" {
+			subject.IP = tracked.IP
+		}
+		if subject.Email == "" {
+			subject.Email = tracked.Email
+		}
+	}
+
+	if subject.IP == "" && subject.Email == "" {
+		return subject, ErrMissingSubject
+	}
+	return subject, f.reputation.Record(subject, feedback.Outcome)
+}
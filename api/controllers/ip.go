@@ -2,9 +2,12 @@ package controllers
 
 import (
 	"net"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/optimatiq/threatbite/ip"
+	"github.com/optimatiq/threatbite/locallist"
+	"github.com/optimatiq/threatbite/scoring"
 )
 
 // IPResult response object, which contains detailed information returned from Check method.
@@ -12,34 +15,76 @@ type IPResult struct {
 	Scoring       uint8  `json:"scoring"`
 	Action        string `json:"action"`
 	Company       string `json:"company"`
+	ASN           uint   `json:"asn,omitempty"`
 	Country       string `json:"country"`
 	BadReputation bool   `json:"bad"`
 	Bot           bool   `json:"bot"`
 	Datacenter    bool   `json:"dc"`
 	Private       bool   `json:"private"`
+	AddressClass  string `json:"address_class"`
 	Proxy         bool   `json:"proxy"`
-	SearchEngine  bool   `json:"se"`
+	Crawler       string `json:"crawler,omitempty"`
 	Spam          bool   `json:"spam"`
 	Tor           bool   `json:"tor"`
 	Vpn           bool   `json:"vpn"`
+	Bogon         bool   `json:"bogon"`
+	CloudProvider string `json:"cloud_provider,omitempty"`
+	CloudService  string `json:"cloud_service,omitempty"`
+	CloudRegion   string `json:"cloud_region,omitempty"`
+
+	Matches []string `json:"matches,omitempty"`
+
+	// LocalHistory is reputation of the address built from outcome feedback (-100 - 100).
+	LocalHistory int `json:"local_history,omitempty"`
+
+	// Categories contains configured categories of IP lists, value is set when the address is on the list.
+	// Evidence contains details of the list entries, which matched the address.
+	Categories map[string]bool    `json:"categories,omitempty"`
+	Evidence   []CategoryEvidence `json:"evidence,omitempty"`
+
+	// List is the name of the local list, which contains the address or ASN and decided about the action.
+	List string `json:"list,omitempty"`
+
+	Transition string    `json:"transition,omitempty"`
+	Embedded   *IPResult `json:"embedded,omitempty"`
+}
+
+// CategoryEvidence describes the entry of the category list, which contains the address.
+type CategoryEvidence struct {
+	Category   string `json:"category"`
+	Detail     string `json:"detail,omitempty"`
+	Confidence uint8  `json:"confidence,omitempty"`
+	FirstSeen  string `json:"first_seen,omitempty"`
+	LastSeen   string `json:"last_seen,omitempty"`
+	Reference  string `json:"reference,omitempty"`
+}
+
+// IPInfo returns information about IP address, it's implemented by ip.IP.
+type IPInfo interface {
+	GetInfo(ip net.IP) (*ip.Info, error)
 }
 
 // IP a container for IP controller.
 type IP struct {
-	ipinfo *ip.IP
-	cache  *lru.Cache
+	ipinfo     IPInfo
+	cache      *lru.Cache
+	thresholds scoring.Thresholds
+	lists      *locallist.Store
 }
 
-// NewIP creates new IP scoring controller.
-func NewIP(ipinfo *ip.IP) (*IP, error) {
+// NewIP creates new IP scoring controller, action is suggested based on the given thresholds.
+// Addresses on local lists (optional) are allowed or blocked regardless of the scoring.
+func NewIP(ipinfo IPInfo, thresholds scoring.Thresholds, lists *locallist.Store) (*IP, error) {
 	cache, err := lru.New(4096)
 	if err != nil {
 		return nil, err
 	}
 
 	ip := &IP{
-		cache:  cache,
-		ipinfo: ipinfo,
+		cache:      cache,
+		ipinfo:     ipinfo,
+		thresholds: thresholds,
+		lists:      lists,
 	}
 
 	return ip, nil
@@ -61,7 +106,25 @@ func (i *IP) Check(addr string) (*IPResult, error) {
 		return nil, ErrInvalidIP
 	}
 
-	if v, ok := i.cache.Get(addr); ok {
+	result, err := i.result(addr, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	// local lists can change at any time, so they are checked on every call
+	if match := i.lists.Match(ip, result.ASN, ""); match.Type != "" {
+		adjusted := *result
+		adjusted.List = match.List
+		adjusted.Scoring, adjusted.Action = listDecision(match)
+		return &adjusted, nil
+	}
+
+	return result, nil
+}
+
+// result returns scoring of the address cached under the key, local lists are not applied.
+func (i *IP) result(key string, ip net.IP) (*IPResult, error) {
+	if v, ok := i.cache.Get(key); ok {
 		return v.(*IPResult), nil
 	}
 
@@ -70,22 +133,72 @@ func (i *IP) Check(addr string) (*IPResult, error) {
 		return nil, err
 	}
 
-	result := &IPResult{
-		Scoring:      info.IPScoring,
-		Country:      info.Country,
-		Company:      info.Company,
-		Tor:          info.IsTor,
-		Proxy:        info.IsProxy,
-		SearchEngine: info.IsSearchEngine,
-		Private:      info.IsPrivate,
-		Spam:         info.IsSpam,
-		Datacenter:   info.IsDatacenter,
-		Vpn:          info.IsVpn,
+	result := newIPResult(info)
+	result.Action = i.thresholds.Action(result.Scoring)
+	i.cache.Add(key, result)
+	return result, nil
+}
+
+// Forget removes the address from the cache, so the next check uses updated local history.
+func (i *IP) Forget(addr string) {
+	i.cache.Remove(addr)
+	if ip := net.ParseIP(addr); ip != nil {
+		i.cache.Remove(ip.String())
 	}
+}
 
-	if !i.cache.Contains(addr) {
-		i.cache.Add(addr, result)
+// newIPResult converts IP information into response object, nil is returned for nil info.
+func newIPResult(info *ip.Info) *IPResult {
+	if info == nil {
+		return nil
 	}
 
-	return result, nil
+	return &IPResult{
+		Scoring:       info.IPScoring,
+		Country:       info.Country,
+		Company:       info.Company,
+		ASN:           info.ASN,
+		Tor:           info.IsTor,
+		Proxy:         info.IsProxy,
+		Crawler:       info.Crawler,
+		Private:       info.IsPrivate,
+		AddressClass:  info.AddressClass,
+		Spam:          info.IsSpam,
+		Datacenter:    info.IsDatacenter,
+		Bogon:         info.IsBogon,
+		CloudProvider: info.CloudProvider,
+		CloudService:  info.CloudService,
+		CloudRegion:   info.CloudRegion,
+		Matches:       info.Matches,
+		LocalHistory:  info.LocalHistory,
+		Categories:    info.Categories,
+		Evidence:      newCategoryEvidence(info.Evidence),
+		Transition:    info.Transition,
+		Embedded:      newIPResult(info.Embedded),
+		Vpn:           info.IsVpn,
+	}
+}
+
+// newCategoryEvidence converts evidence into response objects, dates are formatted as RFC 3339.
+func newCategoryEvidence(evidence []ip.Evidence) []CategoryEvidence {
+	var result []CategoryEvidence
+	for _, e := range evidence {
+		result = append(result, CategoryEvidence{
+			Category:   e.Category,
+			Detail:     e.Detail,
+			Confidence: e.Confidence,
+			FirstSeen:  formatTime(e.FirstSeen),
+			LastSeen:   formatTime(e.LastSeen),
+			Reference:  e.Reference,
+		})
+	}
+	return result
+}
+
+// formatTime returns time in RFC 3339 format or empty string for zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
 }
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"github.com/go-playground/validator"
+	"github.com/optimatiq/threatbite/locallist"
+	"github.com/optimatiq/threatbite/scoring"
+)
+
+// ListType is a type of the local list: allow or deny.
+type ListType struct {
+	Type string `json:"type" form:"type" validate:"required,oneof=allow deny"`
+}
+
+// ListEntries are entries added to or removed from the local list:
+// IP addresses, CIDR networks, ASNs (AS64500), email domains or addresses.
+type ListEntries struct {
+	Entries []string `json:"entries" form:"entries" validate:"required,min=1,max=1000,dive,required,max=320"`
+}
+
+// Lists is a controller, which manages local allow and deny lists.
+type Lists struct {
+	validator *validator.Validate
+	store     *locallist.Store
+}
+
+// NewLists creates local lists controller.
+func NewLists(store *locallist.Store) *Lists {
+	return &Lists{
+		validator: validator.New(),
+		store:     store,
+	}
+}
+
+// Validate returns nil if provided data (ListType or ListEntries) is valid,
+// otherwise, returns error, which can be presented to the user.
+func (l *Lists) Validate(request interface{}) error {
+	return l.validator.Struct(request)
+}
+
+// All returns all local lists.
+func (l *Lists) All() map[string]locallist.List {
+	return l.store.Lists()
+}
+
+// Get returns the local list.
+func (l *Lists) Get(name string) (locallist.List, error) {
+	return l.store.Get(name)
+}
+
+// Put creates the local list or changes its type.
+func (l *Lists) Put(name string, request ListType) error {
+	return l.store.Put(name, request.Type)
+}
+
+// Delete removes the local list.
+func (l *Lists) Delete(name string) error {
+	return l.store.Delete(name)
+}
+
+// Add adds entries to the local list.
+func (l *Lists) Add(name string, request ListEntries) error {
+	return l.store.Add(name, request.Entries)
+}
+
+// Remove removes entries from the local list.
+func (l *Lists) Remove(name string, request ListEntries) error {
+	return l.store.Remove(name, request.Entries)
+}
+
+// listDecision returns scoring and action for the address on the local list,
+// allow list results in the best scoring, deny list in the worst one.
+func listDecision(match locallist.Match) (uint8, string) {
+	if match.Type == locallist.TypeAllow {
+		return 100, scoring.ActionAllow
+	}
+	return 0, scoring.ActionBlock
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"github.com/optimatiq/threatbite/guard"
+)
+
+// Quarantine is a controller, which manages list updates rejected by guards.
+type Quarantine struct {
+	quarantine *guard.Quarantine
+}
+
+// NewQuarantine creates quarantine controller.
+func NewQuarantine(quarantine *guard.Quarantine) *Quarantine {
+	return &Quarantine{quarantine: quarantine}
+}
+
+// All returns reports of all quarantined updates.
+func (q *Quarantine) All() []guard.Report {
+	return q.quarantine.Reports()
+}
+
+// Release applies quarantined update of the list.
+func (q *Quarantine) Release(list string) error {
+	return q.quarantine.Release(list)
+}
+
+// Discard drops quarantined update of the list.
+func (q *Quarantine) Discard(list string) error {
+	return q.quarantine.Discard(list)
+}
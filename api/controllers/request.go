@@ -5,20 +5,255 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"net"
+	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/go-playground/validator"
 	lru "github.com/hashicorp/golang-lru"
+	"github.com/labstack/gommon/log"
 	"github.com/optimatiq/threatbite/browser"
+	"github.com/optimatiq/threatbite/credential"
+	"github.com/optimatiq/threatbite/email"
+	"github.com/optimatiq/threatbite/fingerprint"
+	"github.com/optimatiq/threatbite/header"
 	"github.com/optimatiq/threatbite/ip"
+	"github.com/optimatiq/threatbite/locallist"
+	"github.com/optimatiq/threatbite/pattern"
+	"github.com/optimatiq/threatbite/policy"
+	"github.com/optimatiq/threatbite/rule"
+	"github.com/optimatiq/threatbite/scoring"
+	"github.com/optimatiq/threatbite/signature"
+	"github.com/optimatiq/threatbite/velocity"
+	"golang.org/x/sync/errgroup"
 )
 
 // RequestResult response object, which contains detailed information returned from Check method.
 type RequestResult struct {
 	IPResult
 	browser.UserAgent
-	Bot    bool
-	Mobile bool
-	Script bool
+	Bot        bool
+	BotPattern string `json:",omitempty"`
+	Mobile     bool
+	Script     bool
+
+	// FakeBot is set when user agent claims to be a verifiable crawler, but IP address doesn't belong to it.
+	FakeBot        bool   `json:"fake_bot"`
+	ClaimedCrawler string `json:"claimed_crawler,omitempty"`
+
+	// RequestID identifies scored request in outcome feedback.
+	RequestID string `json:"requestid,omitempty"`
+
+	// Signals contains names of all signals, which changed the scoring of the request.
+	Signals []string `json:"signals,omitempty"`
+
+	// PolicyID is the ID of the policy rule, which decided about the action.
+	PolicyID string `json:"policy_id,omitempty"`
+
+	// Rules contains IDs of matched custom rules.
+	Rules []string `json:"rules,omitempty"`
+
+	// FingerprintFamily is the client family recognized by TLS or HTTP/2 fingerprint.
+	FingerprintFamily string `json:"fingerprint_family,omitempty"`
+
+	// Attacks contains IDs of matched attack signatures, their categories are reported as signals.
+	Attacks []string `json:"attacks,omitempty"`
+
+	// Forwarded contains addresses found in forwarding headers (Forwarded, X-Forwarded-For, X-Real-IP, Client-IP).
+	Forwarded []ForwardedHop `json:"forwarded,omitempty"`
+
+	// Sensitive is set for requests to sensitive routes (e.g. login, signup), which use stricter thresholds.
+	Sensitive bool `json:"sensitive,omitempty"`
+
+	// Email contains information about email address, when it was provided in the request.
+	Email *EmailResult `json:"email,omitempty"`
+}
+
+// Signals raised for HTTP requests.
+const (
+	SignalFakeBot             = "fake_bot"
+	SignalBot                 = "bot"
+	SignalScript              = "script"
+	SignalOldBrowser          = "old_browser"
+	SignalClientHintsMismatch = "client_hints_mismatch"
+	SignalFingerprintMismatch = "fingerprint_mismatch"
+	SignalFingerprintTool     = "fingerprint_tool"
+	SignalUnusualMethod       = "unusual_method"
+
+	SignalProxyHeaders          = "proxy_headers"
+	SignalForwardedPrivate      = "forwarded_private"
+	SignalForwardedInconsistent = "forwarded_inconsistent"
+)
+
+// Weights of the request signals, fake crawlers are one of the most common scraping patterns.
+const (
+	fakeBotWeight             = -60
+	botWeight                 = -20
+	scriptWeight              = -25
+	oldBrowserWeight          = -15
+	clientHintsMismatchWeight = -25
+	fingerprintMismatchWeight = -35
+	fingerprintToolWeight     = -20
+	unusualMethodWeight       = -20
+
+	proxyHeadersWeight          = -10
+	forwardedPrivateWeight      = -10
+	forwardedInconsistentWeight = -20
+)
+
+// maxForwardedHops limits number of addresses from forwarding headers, which are scored.
+const maxForwardedHops = 5
+
+// ForwardedHop contains scoring of the address found in forwarding headers.
+type ForwardedHop struct {
+	IP      string `json:"ip"`
+	Scoring uint8  `json:"scoring"`
+	Private bool   `json:"private,omitempty"`
+}
+
+// fingerprintSignals checks if the client recognized by TLS and HTTP/2 fingerprints is the same as claimed
+// in User-Agent, and if fingerprints belong to known tools. Family of the recognized client is returned.
+func (r *Request) fingerprintSignals(request RequestQuery, userAgent *browser.UserAgent) (string, scoring.Signals) {
+	var signals scoring.Signals
+	var family string
+	mismatch, tool := false, false
+
+	claimed := fingerprint.Family(userAgent)
+	for _, f := range []struct{ kind, value string }{
+		{fingerprint.KindJA4, request.JA4},
+		{fingerprint.KindJA3, request.JA3},
+		{fingerprint.KindHTTP2, request.HTTP2},
+	} {
+		client, ok := r.fingerprints.Lookup(f.kind, f.value)
+		if !ok {
+			continue
+		}
+		if family == "" {
+			family = client.Family
+		}
+		if claimed != "" && !fingerprint.Matches(client.Family, claimed) {
+			log.Debugf("[Request] ip: %s %s: %s family: %s claimed: %s", request.IP, f.kind, f.value, client.Family, claimed)
+			mismatch = true
+		}
+		// headless Chrome has fingerprints of Chrome, so it's a tool, when fingerprints confirm the User-Agent
+		if client.Class == fingerprint.ClassTool ||
+			(claimed == fingerprint.FamilyHeadlessChrome && fingerprint.Matches(client.Family, claimed)) {
+			tool = true
+		}
+	}
+
+	if mismatch {
+		signals.Add(SignalFingerprintMismatch, fingerprintMismatchWeight)
+	}
+	if tool {
+		signals.Add(SignalFingerprintTool, fingerprintToolWeight)
+	}
+	return family, signals
+}
+
+// forwardedSignals scores addresses found in forwarding headers, the request address is skipped as it's already scored.
+// The lowest scoring of public hops is returned, private hops belong to internal proxies, so they are only flagged.
+func (r *Request) forwardedSignals(addr net.IP, chain header.Chain) ([]ForwardedHop, uint8, scoring.Signals) {
+	var signals scoring.Signals
+	if len(chain.Headers) > 0 {
+		signals.Add(SignalProxyHeaders, proxyHeadersWeight)
+	}
+	if chain.Inconsistent {
+		signals.Add(SignalForwardedInconsistent, forwardedInconsistentWeight)
+	}
+
+	var addrs []net.IP
+	for _, hop := range chain.Hops {
+		if !hop.Equal(addr) && len(addrs) < maxForwardedHops {
+			addrs = append(addrs, hop)
+		}
+	}
+
+	// hops are scored concurrently, the hop, which cannot be scored is skipped
+	var g errgroup.Group
+	results := make([]*IPResult, len(addrs))
+	for n, hop := range addrs {
+		n, hop := n, hop
+		g.Go(func() error {
+			result, err := r.hops.result(hop.String(), hop)
+			if err != nil {
+				log.Errorf("[request] cannot score forwarded hop: %s, error: %s", hop, err)
+				return nil
+			}
+			results[n] = result
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	var hops []ForwardedHop
+	worst := uint8(100)
+	private := false
+	for n, result := range results {
+		if result == nil {
+			continue
+		}
+		hops = append(hops, ForwardedHop{IP: addrs[n].String(), Scoring: result.Scoring, Private: result.Private})
+
+		if result.Private {
+			private = true
+		} else if result.Scoring < worst {
+			worst = result.Scoring
+		}
+	}
+
+	if private {
+		signals.Add(SignalForwardedPrivate, forwardedPrivateWeight)
+	}
+	return hops, worst, signals
+}
+
+// methodSignals checks HTTP method, which is rarely used by regular users.
+func methodSignals(request RequestQuery) scoring.Signals {
+	var signals scoring.Signals
+	switch request.Method {
+	case http.MethodTrace, http.MethodDelete, http.MethodPut, http.MethodPatch:
+		signals.Add(SignalUnusualMethod, unusualMethodWeight)
+	}
+	return signals
+}
+
+// ipSignals returns names of IP reputation flags, which are available for policy rules.
+func ipSignals(result *IPResult) []string {
+	flags := []struct {
+		name string
+		set  bool
+	}{
+		{"tor", result.Tor},
+		{"proxy", result.Proxy},
+		{"vpn", result.Vpn},
+		{"dc", result.Datacenter},
+		{"spam", result.Spam},
+		{"bogon", result.Bogon},
+		{"private", result.Private},
+		{"crawler", result.Crawler != ""},
+		{"bad_history", result.LocalHistory < 0},
+	}
+
+	var signals []string
+	for _, f := range flags {
+		if f.set {
+			signals = append(signals, f.name)
+		}
+	}
+	return append(signals, ipCategories(result)...)
+}
+
+// ipCategories returns sorted names of configured categories, which contain the address.
+func ipCategories(result *IPResult) []string {
+	var categories []string
+	for name, found := range result.Categories {
+		if found {
+			categories = append(categories, name)
+		}
+	}
+	sort.Strings(categories)
+	return categories
 }
 
 // RequestQuery struct, which is used to calculate scoring for given request (based on HTTP values).
@@ -36,6 +271,18 @@ type RequestQuery struct {
 	Scheme      string            `json:"scheme" form:"scheme" validate:"omitempty,oneof=http https"`
 	ContentType string            `json:"content_type" form:"content_type"`
 	Headers     map[string]string `json:"headers" form:"headers"`
+	Email       string            `json:"email" form:"email" validate:"omitempty,email"`
+
+	// Fingerprints computed by edge proxy: JA3 (string or hash), JA4 and HTTP/2 in Akamai format.
+	JA3   string `json:"ja3" form:"ja3"`
+	JA4   string `json:"ja4" form:"ja4"`
+	HTTP2 string `json:"http2" form:"http2"`
+
+	// UsernameHash is a hash of the username sent to sensitive route, it's used to count distinct usernames per IP address.
+	UsernameHash string `json:"username_hash" form:"username_hash" validate:"omitempty,max=128"`
+
+	// Payload is a body of the request (or its beginning), it's inspected for attack signatures.
+	Payload string `json:"payload" form:"payload"`
 }
 
 func (r RequestQuery) hash() (string, error) {
@@ -54,22 +301,82 @@ func (r RequestQuery) hash() (string, error) {
 
 // Request is a container for HTTP request controller.
 type Request struct {
-	ipinfo    *ip.IP
-	cache     *lru.Cache
-	validator *validator.Validate
+	ipinfo       IPInfo
+	hops         *IP
+	emailInfo    *email.Email
+	fingerprints *fingerprint.Database
+	botAgents    *pattern.Set
+	rules        *rule.Rules
+	signatures   *signature.Ruleset
+	cache        *lru.Cache
+	validator    *validator.Validate
+	thresholds   scoring.Thresholds
+	policy       *policy.Policy
+	velocity     *velocity.Velocity
+	credentials  *credential.Guard
+	lists        *locallist.Store
+}
+
+// RequestOptions configure HTTP request controller, all fields except IPInfo and Hops are optional.
+type RequestOptions struct {
+	// IPInfo scores the client address, forwarded hops are scored with the Hops IP controller,
+	// so they share its cache.
+	IPInfo IPInfo
+	Hops   *IP
+
+	// Email is used only when email is provided in the request.
+	Email        *email.Email
+	Fingerprints *fingerprint.Database
+	Signatures   *signature.Ruleset
+
+	// BotAgents are patterns of bots user agents, nil set is replaced with built-in patterns.
+	BotAgents *pattern.Set
+
+	// Thresholds decide about the action, when no policy rule matches (or Policy is nil).
+	// Custom Rules adjust the score and may force the action.
+	Thresholds scoring.Thresholds
+	Policy     *policy.Policy
+	Rules      *rule.Rules
+
+	// Velocity counts requests per IP address, subnet, user agent and URI.
+	Velocity *velocity.Velocity
+
+	// Requests to sensitive routes defined in Credentials guard are checked against credential stuffing
+	// and use its stricter thresholds.
+	Credentials *credential.Guard
+
+	// Addresses, ASNs and emails on local Lists are allowed or blocked regardless of all of the above.
+	Lists *locallist.Store
 }
 
 // NewRequest creates new HTTP request scoring module.
-func NewRequest(ipinfo *ip.IP) (*Request, error) {
+// Scoring combines IP reputation with signals found in user agent, headers, method, and attack signatures in URI and payload.
+// Action is taken from the first matching policy rule, thresholds are used when no rule matches.
+func NewRequest(options RequestOptions) (*Request, error) {
 	cache, err := lru.New(4096)
 	if err != nil {
 		return nil, err
 	}
 
+	if options.BotAgents == nil {
+		options.BotAgents = browser.NewBotAgents()
+	}
+
 	request := &Request{
-		validator: validator.New(),
-		cache:     cache,
-		ipinfo:    ipinfo,
+		validator:    validator.New(),
+		cache:        cache,
+		ipinfo:       options.IPInfo,
+		hops:         options.Hops,
+		emailInfo:    options.Email,
+		fingerprints: options.Fingerprints,
+		botAgents:    options.BotAgents,
+		rules:        options.Rules,
+		signatures:   options.Signatures,
+		thresholds:   options.Thresholds,
+		policy:       options.Policy,
+		velocity:     options.Velocity,
+		credentials:  options.Credentials,
+		lists:        options.Lists,
 	}
 
 	return request, nil
@@ -85,44 +392,228 @@ func (r *Request) Validate(request RequestQuery) error {
 }
 
 // Check is the main module functions which is used to perform all checks for given argument.
+// Results of checks, which depend only on the request, are cached. Velocity depends on previous requests,
+// so it's counted on every call, and the score and action are decided on cached result copy.
 func (r *Request) Check(request RequestQuery) (*RequestResult, error) {
-	// TODO add business logic
+	a, err := r.analyze(request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := a.result
+	signals := append(scoring.Signals{}, a.signals...)
+	signals = append(signals, r.velocity.Observe(velocity.Observation{
+		IP:        net.ParseIP(request.IP),
+		UserAgent: request.UserAgent,
+		Host:      request.Host,
+		URI:       request.URI,
+	})...)
+
+	emailSignals := r.velocity.ObserveEmailDomain(emailDomain(request.Email, result.Email))
+	if len(emailSignals) > 0 {
+		result.Email = withEmailSignals(result.Email, emailSignals)
+		signals = append(signals, emailSignals...)
+	}
+
+	thresholds := r.thresholds
+	if r.credentials.Sensitive(request.Method, request.Host, request.URI) {
+		result.Sensitive = true
+		thresholds = r.credentials.Thresholds()
+		signals = append(signals, r.credentials.Check(credential.Request{
+			IP:           net.ParseIP(request.IP),
+			UsernameHash: request.UsernameHash,
+			Script:       result.Script,
+			Datacenter:   result.Datacenter,
+		})...)
+	}
+
+	result.Scoring = signals.Score(int(a.base))
+	result.Signals = signals.Names()
+
+	// custom rules can change the score, so they are evaluated before thresholds and policy
+	rules := r.rules.Evaluate(ruleVars(request, a.info, &result, result.Email))
+	signals = append(signals, rules.Signals...)
+	result.Scoring = signals.Score(int(a.base))
+	result.Signals = signals.Names()
+	result.Rules = rules.Matched
+	result.Action = thresholds.Action(result.Scoring)
+
+	policyID, action, err := r.policy.Evaluate(policy.Input{
+		Host:    request.Host,
+		URI:     request.URI,
+		Method:  request.Method,
+		Country: a.info.Country,
+		ASN:     a.info.ASN,
+		Signals: append(ipSignals(&result.IPResult), result.Signals...),
+		Score:   result.Scoring,
+	})
+	if err == nil {
+		log.Debugf("[Request] ip: %s host: %s policy: %s action: %s", request.IP, request.Host, policyID, action)
+		result.Action = action
+		result.PolicyID = policyID
+	}
+
+	// action forced by the rule takes precedence over policy
+	if rules.Action != "" {
+		log.Debugf("[Request] ip: %s rules: %v action: %s", request.IP, rules.Matched, rules.Action)
+		result.Action = rules.Action
+	}
+
+	// local lists are managed at runtime by the operator, so they take precedence over everything else
+	if match := r.lists.Match(net.ParseIP(request.IP), a.info.ASN, request.Email); match.Type != "" {
+		log.Debugf("[Request] ip: %s email: %s list: %s", request.IP, request.Email, match.List)
+		result.List = match.List
+		result.Scoring, result.Action = listDecision(match)
+	}
+
+	return &result, nil
+}
+
+// Forget removes cached results of requests from the IP address (or forwarded through it) and with the email address,
+// so the next check uses updated local history. Empty arguments are skipped.
+func (r *Request) Forget(addr, email string) {
+	ip := net.ParseIP(addr)
+	for _, key := range r.cache.Keys() {
+		if v, ok := r.cache.Peek(key); ok && v.(*analysis).refers(ip, email) {
+			r.cache.Remove(key)
+		}
+	}
+}
+
+// analysis contains results of checks, which depend only on the request, so they can be cached.
+// Base is the scoring of the worst of the request address and forwarded addresses.
+type analysis struct {
+	result  RequestResult
+	info    *ip.Info
+	signals scoring.Signals
+	base    uint8
+	addr    net.IP
+	email   string
+}
+
+// refers returns true when the request was sent from the address (or forwarded through it) or contains the email.
+func (a *analysis) refers(addr net.IP, email string) bool {
+	if email != "" && strings.EqualFold(a.email, email) {
+		return true
+	}
+	if addr == nil {
+		return false
+	}
+	if a.addr.Equal(addr) {
+		return true
+	}
+	for _, hop := range a.result.Forwarded {
+		if net.ParseIP(hop.IP).Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Request) analyze(request RequestQuery) (*analysis, error) {
 	key, err := request.hash()
 	if err != nil {
 		return nil, err
 	}
 
 	if v, ok := r.cache.Get(key); ok {
-		return v.(*RequestResult), nil
+		return v.(*analysis), nil
 	}
-
-	ip := net.ParseIP(request.IP)
-	if ip == nil {
+	addr := net.ParseIP(request.IP)
+	if addr == nil {
 		return nil, ErrInvalidIP
 	}
 
-	info, err := r.ipinfo.GetInfo(ip)
+	info, err := r.ipinfo.GetInfo(addr)
 	if err != nil {
 		return nil, err
 	}
 
-	result := &RequestResult{
-		IPResult: IPResult{
-			Country:      info.Country,
-			Tor:          info.IsTor,
-			Proxy:        info.IsProxy,
-			SearchEngine: info.IsSearchEngine,
-			Private:      info.IsPrivate,
-			Spam:         info.IsSpam,
-			Datacenter:   info.IsDatacenter,
-		},
-		UserAgent: *browser.GetUserAgent(request.UserAgent),
-		Bot:       browser.IsBotUserAgent(request.UserAgent),
-		Mobile:    browser.IsMobileUserAgent(request.UserAgent),
-		Script:    browser.IsScriptUserAgent(request.UserAgent),
+	headers := header.Parse(request.Headers)
+	userAgent := browser.GetUserAgent(request.UserAgent)
+	userAgent.ParseClientHints(headers)
+	botPattern, isBot := browser.MatchBotUserAgent(r.botAgents, request.UserAgent)
+	isScript := browser.IsScriptUserAgent(request.UserAgent)
+
+	claimedCrawler := ip.ClaimedCrawler(request.UserAgent)
+	fakeBot := claimedCrawler != "" && claimedCrawler != info.Crawler
+	if fakeBot {
+		log.Debugf("[Request] ip: %s claims to be %s, verified crawler: %q", request.IP, claimedCrawler, info.Crawler)
+	}
+
+	var signals scoring.Signals
+	switch {
+	case fakeBot:
+		signals.Add(SignalFakeBot, fakeBotWeight)
+	case isBot && info.Crawler == "":
+		signals.Add(SignalBot, botWeight)
+	}
+	if isScript {
+		signals.Add(SignalScript, scriptWeight)
+	}
+	if userAgent.Browser.IsOld {
+		signals.Add(SignalOldBrowser, oldBrowserWeight)
+	}
+	if userAgent.ClientHintsMismatch() {
+		signals.Add(SignalClientHintsMismatch, clientHintsMismatchWeight)
+	}
+	signals = append(signals, header.Analyze(header.Request{
+		Host:         request.Host,
+		URI:          request.URI,
+		Protocol:     request.Protocol,
+		Scheme:       request.Scheme,
+		Headers:      headers,
+		Browser:      userAgent.Browser.Name,
+		BrowserMajor: userAgent.Browser.Version.Major,
+		Automated:    isBot || isScript,
+		Country:      info.Country,
+	})...)
+	forwarded, worstHop, forwardedSignals := r.forwardedSignals(addr, header.ForwardedChain(headers))
+	signals = append(signals, forwardedSignals...)
+	fingerprintFamily, fingerprintSignals := r.fingerprintSignals(request, userAgent)
+	signals = append(signals, fingerprintSignals...)
+	signals = append(signals, methodSignals(request)...)
+	attacks := r.signatures.Match(request.URI, request.Payload)
+	signals = append(signals, attacks.Signals...)
+
+	var emailResult *EmailResult
+	if request.Email != "" && r.emailInfo != nil {
+		emailResult = newEmailResult(r.emailInfo.GetInfo(request.Email))
+	}
+
+	// the worst of the request address and forwarded addresses is used as a base for the scoring
+	base := info.IPScoring
+	if worstHop < base {
+		base = worstHop
+	}
+
+	result := RequestResult{
+		IPResult:   *newIPResult(info),
+		UserAgent:  *userAgent,
+		Bot:        isBot,
+		BotPattern: botPattern,
+		Mobile:     browser.IsMobileUserAgent(request.UserAgent),
+		Script:     isScript,
+
+		FakeBot:        fakeBot,
+		ClaimedCrawler: claimedCrawler,
+		Email:          emailResult,
+
+		FingerprintFamily: fingerprintFamily,
+		Forwarded:         forwarded,
+		Attacks:           attacks.Matched,
+	}
+
+	a := &analysis{
+		result:  result,
+		info:    info,
+		signals: signals,
+		base:    base,
+		addr:    addr,
+		email:   request.Email,
 	}
 	if !r.cache.Contains(key) {
-		r.cache.Add(key, result)
+		r.cache.Add(key, a)
 	}
-	return result, nil
+	return a, nil
 }
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/optimatiq/threatbite/browser"
+	"github.com/optimatiq/threatbite/fingerprint"
+	"github.com/optimatiq/threatbite/ip"
+	"github.com/optimatiq/threatbite/locallist"
+	"github.com/optimatiq/threatbite/policy"
+	"github.com/optimatiq/threatbite/rule"
+	"github.com/optimatiq/threatbite/scoring"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	chromeAgent    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	googlebotAgent = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+// staticIPInfo returns prepared information about addresses, so requests are scored without lists and DNS.
+type staticIPInfo map[string]*ip.Info
+
+func (s staticIPInfo) GetInfo(addr net.IP) (*ip.Info, error) {
+	if info, ok := s[addr.String()]; ok {
+		return info, nil
+	}
+	return &ip.Info{IPScoring: 80}, nil
+}
+
+func newTestRequest(t *testing.T, options RequestOptions) *Request {
+	hops, err := NewIP(options.IPInfo, options.Thresholds, options.Lists)
+	assert.NoError(t, err)
+	options.Hops = hops
+
+	r, err := NewRequest(options)
+	assert.NoError(t, err)
+	return r
+}
+
+func TestRequestCheckCrawler(t *testing.T) {
+	r := newTestRequest(t, RequestOptions{
+		IPInfo: staticIPInfo{
+			"203.0.113.10": {IPScoring: 80},
+			"203.0.113.20": {IPScoring: 80, Crawler: ip.CrawlerGooglebot},
+		},
+	})
+
+	// Googlebot user agent from the address, which is not verified as Googlebot
+	result, err := r.Check(RequestQuery{IP: "203.0.113.10", Host: "example.com", URI: "/", Method: "GET", UserAgent: googlebotAgent})
+	assert.NoError(t, err)
+	assert.True(t, result.FakeBot)
+	assert.Equal(t, ip.CrawlerGooglebot, result.ClaimedCrawler)
+	assert.Contains(t, result.Signals, SignalFakeBot)
+
+	// verified crawler is neither a fake bot nor a bot
+	result, err = r.Check(RequestQuery{IP: "203.0.113.20", Host: "example.com", URI: "/", Method: "GET", UserAgent: googlebotAgent})
+	assert.NoError(t, err)
+	assert.False(t, result.FakeBot)
+	assert.NotContains(t, result.Signals, SignalFakeBot)
+	assert.NotContains(t, result.Signals, SignalBot)
+	assert.Equal(t, uint8(80), result.Scoring)
+}
+
+func TestRequestCheckThresholds(t *testing.T) {
+	r := newTestRequest(t, RequestOptions{
+		IPInfo: staticIPInfo{
+			"203.0.113.1": {IPScoring: 80},
+			"203.0.113.2": {IPScoring: 60},
+			"203.0.113.3": {IPScoring: 59},
+			"203.0.113.4": {IPScoring: 30},
+			"203.0.113.5": {IPScoring: 29},
+		},
+		Thresholds: scoring.Thresholds{BlockBelow: 30, ChallengeBelow: 60},
+	})
+
+	tests := []struct {
+		ip     string
+		action string
+	}{
+		{"203.0.113.1", scoring.ActionAllow},
+		{"203.0.113.2", scoring.ActionAllow},
+		{"203.0.113.3", scoring.ActionChallenge},
+		{"203.0.113.4", scoring.ActionChallenge},
+		{"203.0.113.5", scoring.ActionBlock},
+	}
+
+	for _, test := range tests {
+		result, err := r.Check(RequestQuery{IP: test.ip, Host: "example.com", URI: "/", Method: "GET", UserAgent: chromeAgent})
+		assert.NoError(t, err)
+		assert.Equal(t, test.action, result.Action, test.ip)
+	}
+}
+
+func TestRequestCheckPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "precedence")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	policyFile := filepath.Join(dir, "policy.json")
+	assert.NoError(t, ioutil.WriteFile(policyFile, []byte(`{"rules": [
+		{"id": "admin", "hosts": ["admin.example.com"], "action": "challenge"}
+	]}`), 0600))
+	requestPolicy, err := policy.Load(policyFile)
+	assert.NoError(t, err)
+
+	rulesFile := filepath.Join(dir, "rules.json")
+	assert.NoError(t, ioutil.WriteFile(rulesFile, []byte(`{"rules": [
+		{"id": "wp-login", "expression": "request.uri.startsWith(\"/wp-login\")", "action": "block"}
+	]}`), 0600))
+	rules, err := rule.Load(rulesFile, RuleSchema)
+	assert.NoError(t, err)
+
+	lists, err := locallist.NewStore("")
+	assert.NoError(t, err)
+	assert.NoError(t, lists.Put("partners", locallist.TypeAllow))
+	assert.NoError(t, lists.Add("partners", []string{"203.0.113.50"}))
+	assert.NoError(t, lists.Put("abusers", locallist.TypeDeny))
+	assert.NoError(t, lists.Add("abusers", []string{"203.0.113.60"}))
+
+	r := newTestRequest(t, RequestOptions{
+		IPInfo:     staticIPInfo{},
+		Thresholds: scoring.Thresholds{BlockBelow: 30, ChallengeBelow: 60},
+		Policy:     requestPolicy,
+		Rules:      rules,
+		Lists:      lists,
+	})
+
+	tests := []struct {
+		name   string
+		ip     string
+		host   string
+		uri    string
+		action string
+		policy string
+		list   string
+	}{
+		{"thresholds", "203.0.113.1", "example.com", "/", scoring.ActionAllow, "", ""},
+		{"policy over thresholds", "203.0.113.1", "admin.example.com", "/", scoring.ActionChallenge, "admin", ""},
+		{"rule over policy", "203.0.113.1", "admin.example.com", "/wp-login.php", scoring.ActionBlock, "admin", ""},
+		{"allow list over rule", "203.0.113.50", "admin.example.com", "/wp-login.php", scoring.ActionAllow, "admin", "partners"},
+		{"deny list over thresholds", "203.0.113.60", "example.com", "/", scoring.ActionBlock, "", "abusers"},
+	}
+
+	for _, test := range tests {
+		result, err := r.Check(RequestQuery{IP: test.ip, Host: test.host, URI: test.uri, Method: "GET", UserAgent: chromeAgent})
+		assert.NoError(t, err)
+		assert.Equal(t, test.action, result.Action, test.name)
+		assert.Equal(t, test.policy, result.PolicyID, test.name)
+		assert.Equal(t, test.list, result.List, test.name)
+	}
+}
+
+func TestFingerprintSignals(t *testing.T) {
+	r := &Request{fingerprints: fingerprint.NewDatabase(nil, nil, 0)}
+	chrome := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	headless := "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36"
+
+	tests := []struct {
+		request RequestQuery
+		signals []string
+	}{
+		{RequestQuery{UserAgent: "curl/7.88.1", JA4: "t13d3112h2_e8f1e7e78f70_b26ce05bbdd6"}, []string{SignalFingerprintTool}},
+		{RequestQuery{UserAgent: "Go-http-client/1.1", JA3: "95b6f6d62c2c0f5258859e829e0055f5"}, []string{SignalFingerprintTool}},
+		{RequestQuery{UserAgent: chrome, JA3: "95b6f6d62c2c0f5258859e829e0055f5"}, []string{SignalFingerprintMismatch, SignalFingerprintTool}},
+		{RequestQuery{UserAgent: headless, JA4: "t13d1516h2_8daaf6152771_02713d6af862"}, []string{SignalFingerprintTool}},
+		{RequestQuery{UserAgent: chrome, JA4: "t13d1516h2_8daaf6152771_02713d6af862"}, nil},
+	}
+
+	for _, test := range tests {
+		_, signals := r.fingerprintSignals(test.request, browser.GetUserAgent(test.request.UserAgent))
+		var names []string
+		for _, s := range signals {
+			names = append(names, s.Name)
+		}
+		assert.Equal(t, test.signals, names, test.request.UserAgent)
+	}
+}
+
+func TestRequestForget(t *testing.T) {
+	cache, err := lru.New(16)
+	assert.NoError(t, err)
+	r := &Request{cache: cache}
+
+	r.cache.Add("direct", &analysis{addr: net.ParseIP("192.0.2.1")})
+	r.cache.Add("forwarded", &analysis{
+		addr:   net.ParseIP("192.0.2.2"),
+		result: RequestResult{Forwarded: []ForwardedHop{{IP: "198.51.100.1"}}},
+	})
+	r.cache.Add("email", &analysis{addr: net.ParseIP("192.0.2.3"), email: "User@Example.com"})
+
+	r.Forget("198.51.100.1", "")
+	assert.ElementsMatch(t, []interface{}{"direct", "email"}, r.cache.Keys())
+
+	r.Forget("", "user@example.com")
+	assert.ElementsMatch(t, []interface{}{"direct"}, r.cache.Keys())
+
+	r.Forget("192.0.2.1", "")
+	assert.Empty(t, r.cache.Keys())
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/optimatiq/threatbite/ip"
+	"github.com/optimatiq/threatbite/rule"
+)
+
+// RuleSchema declares variables, which are available in custom rules evaluated for HTTP requests.
+// Email variables are set only when email was provided in the request, email.present can be used to check it.
+var RuleSchema = rule.Schema{
+	"ip.score":          rule.Number,
+	"ip.country":        rule.String,
+	"ip.company":        rule.String,
+	"ip.asn":            rule.Number,
+	"ip.tor":            rule.Bool,
+	"ip.proxy":          rule.Bool,
+	"ip.vpn":            rule.Bool,
+	"ip.dc":             rule.Bool,
+	"ip.spam":           rule.Bool,
+	"ip.bogon":          rule.Bool,
+	"ip.private":        rule.Bool,
+	"ip.address_class":  rule.String,
+	"ip.crawler":        rule.String,
+	"ip.cloud_provider": rule.String,
+	"ip.local_history":  rule.Number,
+	"ip.categories":     rule.List,
+
+	"request.score":      rule.Number,
+	"request.host":       rule.String,
+	"request.uri":        rule.String,
+	"request.method":     rule.String,
+	"request.scheme":     rule.String,
+	"request.protocol":   rule.String,
+	"request.user_agent": rule.String,
+	"request.bot":        rule.Bool,
+	"request.script":     rule.Bool,
+	"request.mobile":     rule.Bool,
+	"request.fake_bot":   rule.Bool,
+	"request.sensitive":  rule.Bool,
+	"request.signals":    rule.List,
+
+	"email.present":  rule.Bool,
+	"email.address":  rule.String,
+	"email.domain":   rule.String,
+	"email.score":    rule.Number,
+	"email.valid":    rule.Bool,
+	"email.exists":   rule.Bool,
+	"email.free":     rule.Bool,
+	"email.disposal": rule.Bool,
+	"email.catchall": rule.Bool,
+	"email.leaked":   rule.Bool,
+	"email.threat":   rule.Bool,
+	"email.default":  rule.Bool,
+
+	"email.local_history": rule.Number,
+}
+
+// ruleVars returns values of variables declared in RuleSchema, emailResult is nil when email was not provided.
+func ruleVars(request RequestQuery, info *ip.Info, result *RequestResult, emailResult *EmailResult) rule.Vars {
+	vars := rule.Vars{
+		"ip.score":          info.IPScoring,
+		"ip.country":        result.Country,
+		"ip.company":        result.Company,
+		"ip.asn":            result.ASN,
+		"ip.tor":            result.Tor,
+		"ip.proxy":          result.Proxy,
+		"ip.vpn":            result.Vpn,
+		"ip.dc":             result.Datacenter,
+		"ip.spam":           result.Spam,
+		"ip.bogon":          result.Bogon,
+		"ip.private":        result.Private,
+		"ip.address_class":  result.AddressClass,
+		"ip.crawler":        result.Crawler,
+		"ip.cloud_provider": result.CloudProvider,
+		"ip.local_history":  result.LocalHistory,
+		"ip.categories":     ipCategories(&result.IPResult),
+
+		"request.score":      result.Scoring,
+		"request.host":       request.Host,
+		"request.uri":        request.URI,
+		"request.method":     request.Method,
+		"request.scheme":     request.Scheme,
+		"request.protocol":   request.Protocol,
+		"request.user_agent": request.UserAgent,
+		"request.bot":        result.Bot,
+		"request.script":     result.Script,
+		"request.mobile":     result.Mobile,
+		"request.fake_bot":   result.FakeBot,
+		"request.sensitive":  result.Sensitive,
+		"request.signals":    result.Signals,
+	}
+
+	if emailResult != nil {
+		vars["email.present"] = true
+		vars["email.address"] = request.Email
+		if at := strings.LastIndex(request.Email, "@"); at >= 0 {
+			vars["email.domain"] = strings.ToLower(request.Email[at+1:])
+		}
+		vars["email.score"] = emailResult.Scoring
+		vars["email.valid"] = emailResult.Valid
+		vars["email.exists"] = emailResult.AccountExists
+		vars["email.free"] = emailResult.Free
+		vars["email.disposal"] = emailResult.Disposal
+		vars["email.catchall"] = emailResult.CatchAll
+		vars["email.leaked"] = emailResult.Leaked
+		vars["email.threat"] = emailResult.Threat
+		vars["email.default"] = emailResult.DefaultUser
+		vars["email.local_history"] = emailResult.LocalHistory
+	}
+	return vars
+}
@@ -1,7 +1,10 @@
 package transport
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"net/url"
@@ -13,67 +16,270 @@ import (
 	"github.com/labstack/gommon/log"
 	"github.com/optimatiq/threatbite/api/controllers"
 	"github.com/optimatiq/threatbite/api/transport/middlewares"
+	"github.com/optimatiq/threatbite/browser"
 	"github.com/optimatiq/threatbite/config"
+	"github.com/optimatiq/threatbite/credential"
 	"github.com/optimatiq/threatbite/email"
 	emailDatasource "github.com/optimatiq/threatbite/email/datasource"
+	"github.com/optimatiq/threatbite/fetch"
+	"github.com/optimatiq/threatbite/fingerprint"
+	"github.com/optimatiq/threatbite/guard"
+	"github.com/optimatiq/threatbite/intel"
 	"github.com/optimatiq/threatbite/ip"
 	ipDatasource "github.com/optimatiq/threatbite/ip/datasource"
+	"github.com/optimatiq/threatbite/locallist"
+	"github.com/optimatiq/threatbite/policy"
+	"github.com/optimatiq/threatbite/reputation"
+	"github.com/optimatiq/threatbite/rule"
+	"github.com/optimatiq/threatbite/scoring"
+	"github.com/optimatiq/threatbite/signature"
+	"github.com/optimatiq/threatbite/velocity"
 	"golang.org/x/crypto/acme/autocert"
 )
 
 // API state container
 type API struct {
-	config            *config.Config
-	echo              *echo.Echo
-	controllerEmail   *controllers.Email
-	controllerIP      *controllers.IP
-	controllerRequest *controllers.Request
+	config             *config.Config
+	echo               *echo.Echo
+	controllerEmail    *controllers.Email
+	controllerIP       *controllers.IP
+	controllerRequest  *controllers.Request
+	controllerFeedback *controllers.Feedback
+	controllerLists    *controllers.Lists
+	controllerGuard    *controllers.Quarantine
 }
 
 // NewAPI returns new HTTP server, which is listening on given port
 func NewAPI(config *config.Config) (*API, error) {
+	keys := fetch.Keys{}
+	for name, key := range config.SigningKeys {
+		publicKey, err := fetch.ParsePublicKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing key: %s, error: %w", name, err)
+		}
+		keys[name] = publicKey
+	}
+	poll := config.WatchInterval
+
+	guards, err := newGuards(config)
+	if err != nil {
+		return nil, err
+	}
+	quarantine := guard.NewQuarantine()
+
 	emailData := email.NewEmail(
 		config.PwnedKey,
 		config.SMTPHello,
 		config.SMTPFrom,
-		emailDatasource.NewURLDataSource(config.EmailDisposalList),
-		emailDatasource.NewURLDataSource(config.EmailFreeList),
+		emailDatasource.NewURLDataSource(config.EmailDisposalList, keys, poll),
+		emailDatasource.NewURLDataSource(config.EmailFreeList, keys, poll),
 	)
-	emailData.RunUpdates()
 
-	emailController, err := controllers.NewEmail(emailData)
+	// IP and email lists are reloaded at the same time, so indicators are fetched once for both of them.
+	var intelFeed *intel.Feed
+	if config.Intel.Enabled() {
+		var sources []intel.Source
+		if config.Intel.TAXIIURL != "" {
+			sources = append(sources, intel.NewTAXII(config.Intel.TAXIIURL, config.Intel.TAXIIUser, config.Intel.TAXIIPassword))
+		}
+		if config.Intel.MISPURL != "" {
+			sources = append(sources, intel.NewMISP(config.Intel.MISPURL, config.Intel.MISPKey, config.Intel.MISPTTL))
+		}
+		intelFeed = intel.NewFeed(config.Intel.Interval/2, sources...)
+		emailData.SetThreats(intel.NewEmailDataSource(intelFeed), config.Intel.Interval)
+	}
+	emailData.SetGuards(guards, quarantine)
+	emailData.RunUpdates(poll)
+
+	store, err := reputation.NewStore(config.ReputationFile, config.ReputationHalfLife)
 	if err != nil {
 		return nil, err
 	}
+	store.RunUpdates()
+	emailData.SetHistory(store)
 
-	ipdata := ip.NewIP(
-		config.MaxmindKey,
-		ipDatasource.NewURLDataSource(config.ProxyList),
-		ipDatasource.NewURLDataSource(config.SpamList),
-		ipDatasource.NewURLDataSource(config.VPNList),
-		ipDatasource.NewURLDataSource(config.DCList),
-	)
-	ipdata.RunUpdates()
+	requestVelocity, err := velocity.New(velocity.Limits{
+		IP:          velocity.Limit{Minute: config.Velocity.IPMinute, Hour: config.Velocity.IPHour},
+		IPUserAgent: velocity.Limit{TenMinutes: config.Velocity.IPUserAgentTenMinutes},
+		Subnet:      velocity.Limit{Minute: config.Velocity.SubnetMinute},
+		URI:         velocity.Limit{Minute: config.Velocity.URIMinute},
+		EmailDomain: velocity.Limit{Hour: config.Velocity.EmailDomainHour},
+	}, velocity.DefaultSize)
+	if err != nil {
+		return nil, err
+	}
+
+	lists, err := locallist.NewStore(config.LocalListsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	emailController, err := controllers.NewEmail(emailData, requestVelocity, lists)
+	if err != nil {
+		return nil, err
+	}
 
-	ipController, err := controllers.NewIP(ipdata)
+	var cloudSources []ipDatasource.CloudSource
+	for _, source := range config.CloudList {
+		cloudSources = append(cloudSources, ipDatasource.CloudSource{Provider: source.Provider, URL: source.URL})
+	}
+	cloudData, err := ipDatasource.NewCloudDataSource(cloudSources, keys, poll)
 	if err != nil {
 		return nil, err
 	}
 
-	requestController, err := controllers.NewRequest(ipdata)
+	dcNames := ip.NewDatacenterNames()
+	dcNames.SetSources(config.PatternLists.DCNames, keys, poll)
+	dcHosts := ip.NewDatacenterHosts()
+	dcHosts.SetSources(config.PatternLists.DCHosts, keys, poll)
+	proxyHosts := ip.NewProxyHosts()
+	proxyHosts.SetSources(config.PatternLists.ProxyHosts, keys, poll)
+
+	var categories []*ip.Category
+	for _, category := range config.Categories {
+		ds := ipDatasource.NewURLDataSource(category.Sources, keys, poll)
+		categories = append(categories, ip.NewCategory(category.Name, ds, category.Interval, category.Weight))
+	}
+	if intelFeed != nil {
+		categories = append(categories, ip.NewCategory("threat_intel", intel.NewIPDataSource(intelFeed),
+			config.Intel.Interval, config.Intel.Weight))
+	}
+
+	ipdata := ip.NewIP(ip.Options{
+		MaxmindKey: config.MaxmindKey,
+		MaxmindURL: config.MaxmindURL,
+		Keys:       keys,
+		Proxy:      ipDatasource.NewURLDataSource(config.ProxyList, keys, poll),
+		Spam:       ipDatasource.NewURLDataSource(config.SpamList, keys, poll),
+		VPN:        ipDatasource.NewURLDataSource(config.VPNList, keys, poll),
+		Datacenter: ipDatasource.NewURLDataSource(config.DCList, keys, poll),
+		Bogon:      ipDatasource.NewURLDataSource(config.BogonList, keys, poll),
+		Cloud:      cloudData,
+
+		DatacenterNames: dcNames,
+		DatacenterHosts: dcHosts,
+		ProxyHosts:      proxyHosts,
+
+		Categories: categories,
+		Guards:     guards,
+		Quarantine: quarantine,
+		History:    store,
+	})
+	ipdata.RunUpdates(poll)
+
+	thresholds := scoring.Thresholds{
+		BlockBelow:     config.ActionBlockBelow,
+		ChallengeBelow: config.ActionChallengeBelow,
+	}
+
+	ipController, err := controllers.NewIP(ipdata, thresholds, lists)
+	if err != nil {
+		return nil, err
+	}
+
+	var requestPolicy *policy.Policy
+	if config.PolicyFile != "" {
+		requestPolicy, err = policy.Load(config.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fingerprints := fingerprint.NewDatabase(config.FingerprintList, keys, poll)
+	fingerprints.RunUpdates()
+
+	botAgents := browser.NewBotAgents()
+	botAgents.SetSources(config.PatternLists.BotAgents, keys, poll)
+	botAgents.RunUpdates(poll)
+
+	var rules *rule.Rules
+	if config.RulesFile != "" {
+		rules, err = rule.Load(config.RulesFile, controllers.RuleSchema)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	signatures := signature.Default()
+	if config.SignaturesFile != "" {
+		signatures, err = signature.Load(config.SignaturesFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var routes []credential.Route
+	for _, route := range config.SensitiveRoutes {
+		routes = append(routes, credential.Route{Method: route.Method, Host: route.Host, Path: route.Path})
+	}
+	credentials, err := credential.NewGuard(routes, credential.Limits{
+		UsernamesPerIP:    int(config.SensitiveUsernamesPerIP),
+		FailedLoginsPerIP: config.SensitiveFailedLoginsPerIP,
+	}, scoring.Thresholds{
+		BlockBelow:     config.SensitiveBlockBelow,
+		ChallengeBelow: config.SensitiveChallengeBelow,
+	}, velocity.DefaultSize)
+	if err != nil {
+		return nil, err
+	}
+
+	requestController, err := controllers.NewRequest(controllers.RequestOptions{
+		IPInfo:       ipdata,
+		Hops:         ipController,
+		Email:        emailData,
+		Fingerprints: fingerprints,
+		Signatures:   signatures,
+		BotAgents:    botAgents,
+		Thresholds:   thresholds,
+		Policy:       requestPolicy,
+		Rules:        rules,
+		Velocity:     requestVelocity,
+		Credentials:  credentials,
+		Lists:        lists,
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	return &API{
-		config:            config,
-		echo:              echo.New(),
-		controllerEmail:   emailController,
-		controllerIP:      ipController,
-		controllerRequest: requestController,
+		config:             config,
+		echo:               echo.New(),
+		controllerEmail:    emailController,
+		controllerIP:       ipController,
+		controllerRequest:  requestController,
+		controllerFeedback: controllers.NewFeedback(credentials, store),
+		controllerLists:    controllers.NewLists(lists),
+		controllerGuard:    controllers.NewQuarantine(quarantine),
 	}, nil
 }
 
+// newGuards converts configured limits of lists into guards, protected ranges are shared by all protected lists.
+func newGuards(config *config.Config) (map[string]*guard.Guard, error) {
+	var protected []*net.IPNet
+	for _, r := range config.ProtectedRanges {
+		_, network, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid protected range: %s, error: %w", r, err)
+		}
+		protected = append(protected, network)
+	}
+
+	guards := make(map[string]*guard.Guard, len(config.Guards))
+	for list, limits := range config.Guards {
+		g := &guard.Guard{
+			MaxPrefix4: limits.MaxPrefix4,
+			MaxPrefix6: limits.MaxPrefix6,
+			MinEntries: limits.MinEntries,
+			MaxChange:  limits.MaxChange,
+		}
+		if limits.Protected {
+			g.Protected = protected
+		}
+		guards[list] = g
+	}
+	return guards, nil
+}
+
 // Run starts HTTP server and exposes endpoints
 func (a *API) Run() {
 	a.echo.HideBanner = true
@@ -109,6 +315,29 @@ func (a *API) Run() {
 	endpoints.POST("/request", a.handleRequest)
 	endpoints.GET("/email/:email", a.handleEmail)
 
+	// Feedback changes local reputation, so it's available only when feedback or admin token is configured
+	if a.config.FeedbackToken != "" || a.config.AdminToken != "" {
+		feedback := a.echo.Group("/v1/feedback", middleware.KeyAuth(a.validateFeedbackToken))
+		feedback.POST("", a.handleFeedback)
+		feedback.POST("/login", a.handleLoginFeedback)
+	}
+
+	// Management endpoints are available only when admin token is configured
+	if a.config.AdminToken != "" {
+		lists := a.echo.Group("/v1/lists", middleware.KeyAuth(a.validateAdminToken))
+		lists.GET("", a.handleLists)
+		lists.GET("/:name", a.handleList)
+		lists.PUT("/:name", a.handlePutList)
+		lists.DELETE("/:name", a.handleDeleteList)
+		lists.POST("/:name/entries", a.handleAddListEntries)
+		lists.DELETE("/:name/entries", a.handleRemoveListEntries)
+
+		quarantine := a.echo.Group("/v1/quarantine", middleware.KeyAuth(a.validateAdminToken))
+		quarantine.GET("", a.handleQuarantine)
+		quarantine.POST("/:list/release", a.handleReleaseQuarantine)
+		quarantine.DELETE("/:list", a.handleDiscardQuarantine)
+	}
+
 	if a.config.AutoTLS {
 		a.echo.AutoTLSManager.Cache = autocert.DirCache("./resources/tls_cache")
 		a.echo.Logger.Fatal(a.echo.StartAutoTLS(fmt.Sprintf(":%d", a.config.Port)))
@@ -178,5 +407,174 @@ func (a *API) handleRequest(c echo.Context) error {
 		return echo.ErrInternalServerError
 	}
 
+	result.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	a.controllerFeedback.Track(result.RequestID, request)
+
 	return c.JSONPretty(http.StatusOK, result, "  ")
 }
+
+func (a *API) handleLoginFeedback(c echo.Context) error {
+	feedback := controllers.LoginFeedback{}
+	if err := c.Bind(&feedback); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := a.controllerFeedback.Validate(feedback); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := a.controllerFeedback.Login(feedback); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (a *API) handleFeedback(c echo.Context) error {
+	feedback := controllers.OutcomeFeedback{}
+	if err := c.Bind(&feedback); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := a.controllerFeedback.Validate(feedback); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	subject, err := a.controllerFeedback.Outcome(feedback)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// cached results don't contain updated local history
+	a.controllerIP.Forget(subject.IP)
+	a.controllerEmail.Forget(subject.Email)
+	a.controllerRequest.Forget(subject.IP, subject.Email)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (a *API) validateAdminToken(token string, c echo.Context) (bool, error) {
+	return a.config.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.config.AdminToken)) == 1, nil
+}
+
+func (a *API) validateFeedbackToken(token string, c echo.Context) (bool, error) {
+	if a.config.FeedbackToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.config.FeedbackToken)) == 1 {
+		return true, nil
+	}
+	return a.validateAdminToken(token, c)
+}
+
+// listError converts local list error into HTTP error.
+func listError(err error) error {
+	switch {
+	case errors.Is(err, locallist.ErrUnknownList):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, locallist.ErrInvalidName), errors.Is(err, locallist.ErrInvalidType),
+		errors.Is(err, locallist.ErrInvalidEntry):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		log.Errorf("local lists error: %s", err)
+		return echo.ErrInternalServerError
+	}
+}
+
+func (a *API) handleLists(c echo.Context) error {
+	return c.JSONPretty(http.StatusOK, a.controllerLists.All(), "  ")
+}
+
+func (a *API) handleList(c echo.Context) error {
+	list, err := a.controllerLists.Get(c.Param("name"))
+	if err != nil {
+		return listError(err)
+	}
+
+	return c.JSONPretty(http.StatusOK, list, "  ")
+}
+
+func (a *API) handlePutList(c echo.Context) error {
+	request := controllers.ListType{}
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := a.controllerLists.Validate(request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := a.controllerLists.Put(c.Param("name"), request); err != nil {
+		return listError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (a *API) handleDeleteList(c echo.Context) error {
+	if err := a.controllerLists.Delete(c.Param("name")); err != nil {
+		return listError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (a *API) handleAddListEntries(c echo.Context) error {
+	request := controllers.ListEntries{}
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := a.controllerLists.Validate(request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := a.controllerLists.Add(c.Param("name"), request); err != nil {
+		return listError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (a *API) handleRemoveListEntries(c echo.Context) error {
+	request := controllers.ListEntries{}
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := a.controllerLists.Validate(request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := a.controllerLists.Remove(c.Param("name"), request); err != nil {
+		return listError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// quarantineError converts quarantine error into HTTP error.
+func quarantineError(err error) error {
+	if errors.Is(err, guard.ErrNotQuarantined) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	log.Errorf("quarantine error: %s", err)
+	return echo.ErrInternalServerError
+}
+
+func (a *API) handleQuarantine(c echo.Context) error {
+	return c.JSONPretty(http.StatusOK, a.controllerGuard.All(), "  ")
+}
+
+func (a *API) handleReleaseQuarantine(c echo.Context) error {
+	if err := a.controllerGuard.Release(c.Param("list")); err != nil {
+		return quarantineError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (a *API) handleDiscardQuarantine(c echo.Context) error {
+	if err := a.controllerGuard.Discard(c.Param("list")); err != nil {
+		return quarantineError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+package browser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/header"
+)
+
+// Brand is a single entry of Sec-CH-UA or Sec-CH-UA-Full-Version-List header.
+type Brand struct {
+	Name    string
+	Version string
+}
+
+// ClientHints contains User-Agent Client Hints sent by Chromium based browsers.
+type ClientHints struct {
+	Brands          []Brand
+	FullVersionList []Brand
+	Platform        string
+	PlatformVersion string
+	Mobile          bool
+	Model           string
+}
+
+// hintPlatforms maps Sec-CH-UA-Platform values to names of operating systems parsed from User-Agent.
+var hintPlatforms = map[string]string{
+	"Windows":     "Windows",
+	"macOS":       "MacOSX",
+	"Android":     "Android",
+	"Linux":       "Linux",
+	"Chrome OS":   "ChromeOS",
+	"Chromium OS": "ChromeOS",
+	"iOS":         "iOS",
+}
+
+// ParseClientHints parses Sec-CH-UA* headers into UserAgent, ClientHints stays nil when hints are not present.
+func (ua *UserAgent) ParseClientHints(headers header.Headers) {
+	if !headers.Has("Sec-Ch-Ua") && !headers.Has("Sec-Ch-Ua-Platform") && !headers.Has("Sec-Ch-Ua-Mobile") {
+		return
+	}
+
+	ua.ClientHints = &ClientHints{
+		Brands:          parseBrands(headers.Get("Sec-Ch-Ua")),
+		FullVersionList: parseBrands(headers.Get("Sec-Ch-Ua-Full-Version-List")),
+		Platform:        unquote(headers.Get("Sec-Ch-Ua-Platform")),
+		PlatformVersion: unquote(headers.Get("Sec-Ch-Ua-Platform-Version")),
+		Mobile:          strings.TrimSpace(headers.Get("Sec-Ch-Ua-Mobile")) == "?1",
+		Model:           unquote(headers.Get("Sec-Ch-Ua-Model")),
+	}
+}
+
+// ClientHintsMismatch returns true when client hints contradict User-Agent string: different platform,
+// mobile flag or Chromium major version. Spoofing tools often change User-Agent, but leave hints untouched.
+func (ua *UserAgent) ClientHintsMismatch() bool {
+	hints := ua.ClientHints
+	if hints == nil {
+		return false
+	}
+
+	if os, ok := hintPlatforms[hints.Platform]; ok && ua.OS.Name != "Unknown" && ua.OS.Name != os {
+		log.Debugf("[ClientHints] platform: %s os: %s", hints.Platform, ua.OS.Name)
+		return true
+	}
+
+	if (hints.Mobile && ua.Device == "Computer") || (!hints.Mobile && ua.Device == DevicePhone) {
+		log.Debugf("[ClientHints] mobile: %t device: %s", hints.Mobile, ua.Device)
+		return true
+	}
+
+	if ua.Browser.Name == BrowserChrome {
+		for _, brand := range append(hints.FullVersionList, hints.Brands...) {
+			if brand.Name != "Chromium" {
+				continue
+			}
+			major, err := strconv.Atoi(strings.SplitN(brand.Version, ".", 2)[0])
+			if err == nil && major != ua.Browser.Version.Major {
+				log.Debugf("[ClientHints] chromium: %d chrome: %d", major, ua.Browser.Version.Major)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// parseBrands parses structured header list, e.g. "Chromium";v="112", "Not:A-Brand";v="99"
+func parseBrands(value string) []Brand {
+	var brands []Brand
+	for _, item := range splitOutsideQuotes(value, ',') {
+		params := splitOutsideQuotes(item, ';')
+		if len(params) == 0 {
+			continue
+		}
+		brand := Brand{Name: unquote(params[0])}
+		for _, p := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(p), "=", 2); len(kv) == 2 && kv[0] == "v" {
+				brand.Version = unquote(kv[1])
+			}
+		}
+		if brand.Name != "" {
+			brands = append(brands, brand)
+		}
+	}
+	return brands
+}
+
+func splitOutsideQuotes(value string, sep rune) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, c := range value {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(value[start:]) != "" {
+		parts = append(parts, value[start:])
+	}
+	return parts
+}
+
+func unquote(value string) string {
+	return strings.Trim(strings.TrimSpace(value), `"`)
+}
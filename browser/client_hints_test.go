@@ -0,0 +1,60 @@
+package browser
+
+import (
+	"testing"
+
+	"github.com/optimatiq/threatbite/header"
+	"github.com/stretchr/testify/assert"
+)
+
+const chromeWindows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Safari/537.36"
+
+func TestParseClientHints(t *testing.T) {
+	ua := GetUserAgent(chromeWindows)
+	ua.ParseClientHints(header.Parse(map[string]string{"Accept": "*/*"}))
+	assert.Nil(t, ua.ClientHints)
+	assert.False(t, ua.ClientHintsMismatch())
+
+	ua.ParseClientHints(header.Parse(map[string]string{
+		"sec-ch-ua":                   `"Chromium";v="112", "Google Chrome";v="112", "Not:A-Brand";v="99"`,
+		"sec-ch-ua-full-version-list": `"Chromium";v="112.0.5615.138", "Google Chrome";v="112.0.5615.138", "Not:A-Brand";v="99.0.0.0"`,
+		"sec-ch-ua-platform":          `"Windows"`,
+		"sec-ch-ua-platform-version":  `"15.0.0"`,
+		"sec-ch-ua-mobile":            "?0",
+		"sec-ch-ua-model":             `""`,
+	}))
+	assert.Equal(t, &ClientHints{
+		Brands: []Brand{{"Chromium", "112"}, {"Google Chrome", "112"}, {"Not:A-Brand", "99"}},
+		FullVersionList: []Brand{
+			{"Chromium", "112.0.5615.138"}, {"Google Chrome", "112.0.5615.138"}, {"Not:A-Brand", "99.0.0.0"},
+		},
+		Platform:        "Windows",
+		PlatformVersion: "15.0.0",
+	}, ua.ClientHints)
+	assert.False(t, ua.ClientHintsMismatch())
+}
+
+func TestClientHintsMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		agent   string
+		headers map[string]string
+		want    bool
+	}{
+		{"consistent", chromeWindows, map[string]string{"Sec-Ch-Ua": `"Chromium";v="112"`, "Sec-Ch-Ua-Platform": `"Windows"`, "Sec-Ch-Ua-Mobile": "?0"}, false},
+		{"platform", chromeWindows, map[string]string{"Sec-Ch-Ua": `"Chromium";v="112"`, "Sec-Ch-Ua-Platform": `"Linux"`}, true},
+		{"unknown platform", chromeWindows, map[string]string{"Sec-Ch-Ua-Platform": `"Unknown"`}, false},
+		{"mobile", chromeWindows, map[string]string{"Sec-Ch-Ua-Mobile": "?1"}, true},
+		{"version", chromeWindows, map[string]string{"Sec-Ch-Ua": `"Chromium";v="120", "Not A(Brand";v="24"`}, true},
+		{"phone without mobile", "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Mobile Safari/537.36",
+			map[string]string{"Sec-Ch-Ua-Mobile": "?0", "Sec-Ch-Ua-Platform": `"Android"`}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ua := GetUserAgent(tt.agent)
+			ua.ParseClientHints(header.Parse(tt.headers))
+			assert.Equal(t, tt.want, ua.ClientHintsMismatch())
+		})
+	}
+}
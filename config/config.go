@@ -2,12 +2,17 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"os"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/optimatiq/threatbite/fetch"
 )
 
 // Config configuration struct for the project
@@ -23,8 +28,141 @@ type Config struct {
 	SpamList          []string
 	VPNList           []string
 	DCList            []string
+	BogonList         []string
+	CloudList         []CloudSource
+	PatternLists      PatternLists
+	FingerprintList   []string
 	EmailDisposalList []string
 	EmailFreeList     []string
+
+	// MaxmindURL is the URL of the mirror of MaxMind archives, {edition} is replaced with the edition ID.
+	MaxmindURL string
+
+	// SigningKeys are public keys by name, sources select them with key option, e.g. https://example.com/list.txt#key=vendor.
+	SigningKeys map[string]string
+
+	// WatchInterval is the interval of checking local list files for changes, 0 disables change detection.
+	WatchInterval time.Duration
+
+	// Categories are named lists of IP addresses defined in addition to built-in lists.
+	Categories []Category
+
+	// Intel configures polling of threat intelligence platforms, their indicators are added to IP and email lists.
+	Intel Intel
+
+	// Guards contain limits of updates by list name, updates violating them are quarantined instead of applied.
+	// ProtectedRanges (e.g. own networks) cannot overlap with entries of lists, whose guard is Protected.
+	Guards          map[string]Guard
+	ProtectedRanges []string
+
+	// Scoring thresholds: score lower than ActionBlockBelow results in block action,
+	// lower than ActionChallengeBelow in challenge action.
+	ActionBlockBelow     uint8
+	ActionChallengeBelow uint8
+
+	// PolicyFile is a path to JSON file with per-host rules, which are evaluated after scoring.
+	PolicyFile string
+
+	// RulesFile is a path to JSON file with custom rules written in expression language.
+	RulesFile string
+
+	// SignaturesFile is a path to JSON file with attack signatures, which replace built-in signatures.
+	SignaturesFile string
+
+	// Velocity contains limits of requests counted per key in sliding windows.
+	Velocity VelocityLimits
+
+	// ReputationFile is a path to JSON file with local reputation built from outcome feedback, empty means memory only.
+	// Reputation decays with ReputationHalfLife.
+	ReputationFile     string
+	ReputationHalfLife time.Duration
+
+	// LocalListsFile is a path to JSON file with local allow and deny lists, empty means memory only.
+	LocalListsFile string
+
+	// AdminToken authorizes management of local lists, empty token disables management endpoints.
+	AdminToken string
+
+	// SensitiveRoutes (e.g. login, signup) are checked against credential stuffing and use stricter thresholds.
+	SensitiveRoutes            []SensitiveRoute
+	SensitiveBlockBelow        uint8
+	SensitiveChallengeBelow    uint8
+	SensitiveUsernamesPerIP    uint32
+	SensitiveFailedLoginsPerIP uint32
+
+	// FeedbackToken authorizes feedback endpoints (admin token is accepted too),
+	// feedback endpoints are disabled when neither of them is set.
+	FeedbackToken string
+}
+
+// SensitiveRoute is defined as METHOD:HOST/PATH, host and path are glob patterns, method "*" matches any method.
+type SensitiveRoute struct {
+	Method string
+	Host   string
+	Path   string
+}
+
+// VelocityLimits contains maximal number of requests per key in sliding windows, 0 disables the limit.
+type VelocityLimits struct {
+	IPMinute              uint32
+	IPHour                uint32
+	IPUserAgentTenMinutes uint32
+	SubnetMinute          uint32
+	URIMinute             uint32
+	EmailDomainHour       uint32
+}
+
+// PatternLists contains URLs of lists with patterns, which replace built-in patterns.
+// Empty list means that built-in patterns are used.
+type PatternLists struct {
+	DCNames    []string
+	DCHosts    []string
+	ProxyHosts []string
+	BotAgents  []string
+}
+
+// Category is a named list of IP addresses (e.g. botnet, scanners), which is reloaded every Interval
+// and changes the scoring of listed addresses by Weight.
+type Category struct {
+	Name     string
+	Sources  []string
+	Interval time.Duration
+	Weight   int
+}
+
+// Intel contains TAXII 2.1 collection and MISP instance, from which indicators are polled every Interval.
+// IP indicators change the scoring of listed addresses by Weight. MISP attributes expire MISPTTL after they were last seen.
+type Intel struct {
+	TAXIIURL      string
+	TAXIIUser     string
+	TAXIIPassword string
+	MISPURL       string
+	MISPKey       string
+	MISPTTL       time.Duration
+	Interval      time.Duration
+	Weight        int
+}
+
+// Enabled returns true when at least one platform is configured.
+func (i Intel) Enabled() bool {
+	return i.TAXIIURL != "" || i.MISPURL != ""
+}
+
+// Guard contains limits of list updates, 0 disables the check.
+// MaxPrefix4 and MaxPrefix6 are the shortest accepted prefix lengths, MaxChange is the maximal change
+// of the number of entries versus the previous load in percents.
+type Guard struct {
+	MaxPrefix4 int
+	MaxPrefix6 int
+	MinEntries int
+	MaxChange  int
+	Protected  bool
+}
+
+// CloudSource is a document with address ranges published by the cloud provider.
+type CloudSource struct {
+	Provider string
+	URL      string
 }
 
 // NewConfig returns a new configuration struct or error.
@@ -34,14 +172,60 @@ type Config struct {
 // Envs take precedence of envs that are imported from config_local.env or config.env files
 func NewConfig(configFile string) (*Config, error) {
 	config := &Config{
-		Port:              8080,
-		Debug:             false,
+		Port:                 8080,
+		Debug:                false,
+		ActionBlockBelow:     20,
+		ActionChallengeBelow: 50,
+
+		ReputationHalfLife: 30 * 24 * time.Hour,
+		WatchInterval:      30 * time.Second,
+
+		Intel: Intel{
+			MISPTTL:  30 * 24 * time.Hour,
+			Interval: 15 * time.Minute,
+			Weight:   -40,
+		},
+
+		Guards: map[string]Guard{
+			"proxy":        {MaxPrefix4: 10, MaxPrefix6: 24, MinEntries: 1, MaxChange: 90, Protected: true},
+			"spam":         {MaxPrefix4: 10, MaxPrefix6: 24, MinEntries: 1, MaxChange: 90, Protected: true},
+			"vpn":          {MaxPrefix4: 10, MaxPrefix6: 24, MinEntries: 1, MaxChange: 90, Protected: true},
+			"datacenter":   {MaxPrefix4: 10, MaxPrefix6: 24, MinEntries: 1, MaxChange: 90, Protected: true},
+			"bogon":        {MinEntries: 1, MaxChange: 90},
+			"cloud":        {MinEntries: 1, MaxChange: 90},
+			"crawler":      {MinEntries: 1, MaxChange: 90},
+			"disposal":     {MinEntries: 1, MaxChange: 90},
+			"free":         {MinEntries: 1, MaxChange: 90},
+			"threat_intel": {MaxPrefix4: 10, MaxPrefix6: 24, Protected: true},
+			"threat":       {},
+		},
+
+		SensitiveBlockBelow:        40,
+		SensitiveChallengeBelow:    70,
+		SensitiveUsernamesPerIP:    5,
+		SensitiveFailedLoginsPerIP: 10,
+		Velocity: VelocityLimits{
+			IPMinute:              120,
+			IPHour:                3000,
+			IPUserAgentTenMinutes: 600,
+			SubnetMinute:          600,
+			EmailDomainHour:       50,
+		},
 		ProxyList:         []string{"https://get.threatbite.com/public/proxy.txt"},
 		SpamList:          []string{"https://get.threatbite.com/public/spam.txt"},
 		VPNList:           []string{"https://get.threatbite.com/public/vpn.txt"},
 		DCList:            []string{"https://get.threatbite.com/public/dc-names.txt"},
+		BogonList:         []string{"https://www.team-cymru.org/Services/Bogons/fullbogons-ipv4.txt", "https://www.team-cymru.org/Services/Bogons/fullbogons-ipv6.txt"},
 		EmailDisposalList: []string{"https://get.threatbite.com/public/disposal.txt"},
 		EmailFreeList:     []string{"https://get.threatbite.com/public/free.txt"},
+		CloudList: []CloudSource{
+			{Provider: "aws", URL: "https://ip-ranges.amazonaws.com/ip-ranges.json"},
+			{Provider: "gcp", URL: "https://www.gstatic.com/ipranges/cloud.json"},
+			{Provider: "oracle", URL: "https://docs.oracle.com/en-us/iaas/tools/public_ip_ranges.json"},
+			{Provider: "digitalocean", URL: "https://digitalocean.com/geo/google.csv"},
+			{Provider: "cloudflare", URL: "https://www.cloudflare.com/ips-v4"},
+			{Provider: "cloudflare", URL: "https://www.cloudflare.com/ips-v6"},
+		},
 	}
 
 	if configFile == "" {
@@ -74,9 +258,90 @@ func NewConfig(configFile string) (*Config, error) {
 		config.AutoTLS = true
 	}
 
+	thresholds := map[string]*uint8{
+		"ACTION_BLOCK_BELOW":     &config.ActionBlockBelow,
+		"ACTION_CHALLENGE_BELOW": &config.ActionChallengeBelow,
+
+		"SENSITIVE_BLOCK_BELOW":     &config.SensitiveBlockBelow,
+		"SENSITIVE_CHALLENGE_BELOW": &config.SensitiveChallengeBelow,
+	}
+
+	for env, threshold := range thresholds {
+		if e := os.Getenv(env); e != "" {
+			t, err := strconv.ParseUint(e, 10, 8)
+			if err != nil || t > 100 {
+				return nil, fmt.Errorf("invalid %s value: %s, expected number 0-100", env, e)
+			}
+			*threshold = uint8(t)
+		}
+	}
+
+	limits := map[string]*uint32{
+		"VELOCITY_IP_MINUTE":         &config.Velocity.IPMinute,
+		"VELOCITY_IP_HOUR":           &config.Velocity.IPHour,
+		"VELOCITY_IP_UA_TEN_MINUTES": &config.Velocity.IPUserAgentTenMinutes,
+		"VELOCITY_SUBNET_MINUTE":     &config.Velocity.SubnetMinute,
+		"VELOCITY_URI_MINUTE":        &config.Velocity.URIMinute,
+		"VELOCITY_EMAIL_DOMAIN_HOUR": &config.Velocity.EmailDomainHour,
+
+		"SENSITIVE_USERNAMES_PER_IP":     &config.SensitiveUsernamesPerIP,
+		"SENSITIVE_FAILED_LOGINS_PER_IP": &config.SensitiveFailedLoginsPerIP,
+	}
+
+	for env, limit := range limits {
+		if e := os.Getenv(env); e != "" {
+			l, err := strconv.ParseUint(e, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value: %s, expected number, 0 disables the limit", env, e)
+			}
+			*limit = uint32(l)
+		}
+	}
+
+	if config.ActionBlockBelow > config.ActionChallengeBelow {
+		return nil, fmt.Errorf("ACTION_BLOCK_BELOW: %d cannot be greater than ACTION_CHALLENGE_BELOW: %d",
+			config.ActionBlockBelow, config.ActionChallengeBelow)
+	}
+
+	if config.SensitiveBlockBelow > config.SensitiveChallengeBelow {
+		return nil, fmt.Errorf("SENSITIVE_BLOCK_BELOW: %d cannot be greater than SENSITIVE_CHALLENGE_BELOW: %d",
+			config.SensitiveBlockBelow, config.SensitiveChallengeBelow)
+	}
+
+	config.PolicyFile = os.Getenv("POLICY_FILE")
+	config.RulesFile = os.Getenv("RULES_FILE")
+	config.SignaturesFile = os.Getenv("SIGNATURES_FILE")
+	config.ReputationFile = os.Getenv("REPUTATION_FILE")
+	config.LocalListsFile = os.Getenv("LOCAL_LISTS_FILE")
+	config.AdminToken = os.Getenv("ADMIN_TOKEN")
+
+	if e := os.Getenv("REPUTATION_HALF_LIFE"); e != "" {
+		halfLife, err := time.ParseDuration(e)
+		if err != nil || halfLife <= 0 {
+			return nil, fmt.Errorf("invalid REPUTATION_HALF_LIFE value: %s, expected positive duration, e.g. 720h", e)
+		}
+		config.ReputationHalfLife = halfLife
+	}
+	config.FeedbackToken = os.Getenv("FEEDBACK_TOKEN")
+
+	if e := os.Getenv("WATCH_INTERVAL"); e != "" {
+		interval, err := time.ParseDuration(e)
+		if err != nil || (interval != 0 && interval < time.Second) {
+			return nil, fmt.Errorf("invalid WATCH_INTERVAL value: %s, expected duration of at least 1s, e.g. 30s, 0 disables it", e)
+		}
+		config.WatchInterval = interval
+	}
+
 	config.PwnedKey = os.Getenv("PWNED_KEY")
 	config.MaxmindKey = os.Getenv("MAXMIND_KEY")
 
+	if e := os.Getenv("MAXMIND_URL"); e != "" {
+		if err := fetch.Validate(maxmindSource(e)); err != nil {
+			return nil, fmt.Errorf("invalid MAXMIND_URL value: %s, error: %w", e, err)
+		}
+		config.MaxmindURL = e
+	}
+
 	config.SMTPHello = os.Getenv("SMTP_HELLO")
 	config.SMTPFrom = os.Getenv("SMTP_FROM")
 
@@ -85,21 +350,325 @@ func NewConfig(configFile string) (*Config, error) {
 		"SPAM_LIST":           &config.SpamList,
 		"VPN_LIST":            &config.VPNList,
 		"DC_LIST":             &config.DCList,
+		"BOGON_LIST":          &config.BogonList,
 		"EMAIL_DISPOSAL_LIST": &config.EmailDisposalList,
 		"EMAIL_FREE_LIST":     &config.EmailFreeList,
+		"FINGERPRINT_LIST":    &config.FingerprintList,
+
+		"DC_NAMES_LIST":    &config.PatternLists.DCNames,
+		"DC_HOSTS_LIST":    &config.PatternLists.DCHosts,
+		"PROXY_HOSTS_LIST": &config.PatternLists.ProxyHosts,
+		"BOT_AGENTS_LIST":  &config.PatternLists.BotAgents,
 	}
 
 	for env, list := range lists {
 		if e := os.Getenv(env); e != "" {
 			*list = []string{}
 			for _, u := range strings.Fields(e) {
-				if _, err := url.ParseRequestURI(u); err != nil {
-					return nil, fmt.Errorf("invalid list URL: %s, error: %w", u, err)
+				if err := fetch.Validate(u); err != nil {
+					return nil, fmt.Errorf("invalid list source: %s, error: %w", u, err)
 				}
 				*list = append(*list, u)
 			}
 		}
 	}
 
+	if e := os.Getenv("CLOUD_LIST"); e != "" {
+		config.CloudList = []CloudSource{}
+		for _, source := range strings.Fields(e) {
+			parts := strings.SplitN(source, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid cloud source: %s, expected provider=URL", source)
+			}
+			if err := fetch.Validate(parts[1]); err != nil {
+				return nil, fmt.Errorf("invalid cloud source: %s, error: %w", parts[1], err)
+			}
+			config.CloudList = append(config.CloudList, CloudSource{Provider: parts[0], URL: parts[1]})
+		}
+	}
+
+	for _, name := range strings.Fields(os.Getenv("CATEGORIES")) {
+		category, err := parseCategory(name)
+		if err != nil {
+			return nil, err
+		}
+		config.Categories = append(config.Categories, category)
+	}
+
+	if err := parseIntel(&config.Intel); err != nil {
+		return nil, err
+	}
+
+	if err := parseGuards(config); err != nil {
+		return nil, err
+	}
+
+	if err := parseSigningKeys(config); err != nil {
+		return nil, err
+	}
+
+	for _, e := range strings.Fields(os.Getenv("SENSITIVE_ROUTES")) {
+		route, err := parseSensitiveRoute(e)
+		if err != nil {
+			return nil, err
+		}
+		config.SensitiveRoutes = append(config.SensitiveRoutes, route)
+	}
+
 	return config, nil
 }
+
+var reCategory = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// builtinLists are names of built-in lists, categories cannot use them, because lists are identified by names.
+var builtinLists = map[string]bool{
+	"proxy": true, "spam": true, "vpn": true, "datacenter": true, "bogon": true, "cloud": true, "crawler": true,
+	"tor": true, "disposal": true, "free": true, "threat": true, "threat_intel": true,
+}
+
+// parseCategory reads sources, interval and weight of the category from CATEGORY_<NAME>_LIST, CATEGORY_<NAME>_INTERVAL
+// (default 12h) and CATEGORY_<NAME>_WEIGHT (default -20) envs.
+func parseCategory(name string) (Category, error) {
+	if !reCategory.MatchString(name) {
+		return Category{}, fmt.Errorf("invalid category name: %s, expected 1-32 characters: a-z, 0-9 or _", name)
+	}
+	if builtinLists[name] {
+		return Category{}, fmt.Errorf("invalid category name: %s, it's the name of a built-in list", name)
+	}
+
+	prefix := "CATEGORY_" + strings.ToUpper(name) + "_"
+	category := Category{Name: name, Interval: 12 * time.Hour, Weight: -20}
+
+	for _, u := range strings.Fields(os.Getenv(prefix + "LIST")) {
+		if err := fetch.Validate(u); err != nil {
+			return Category{}, fmt.Errorf("invalid list source: %s, error: %w", u, err)
+		}
+		category.Sources = append(category.Sources, u)
+	}
+	if len(category.Sources) == 0 {
+		return Category{}, fmt.Errorf("%sLIST is required for category: %s", prefix, name)
+	}
+
+	if e := os.Getenv(prefix + "INTERVAL"); e != "" {
+		interval, err := time.ParseDuration(e)
+		if err != nil || interval < time.Minute {
+			return Category{}, fmt.Errorf("invalid %sINTERVAL value: %s, expected duration of at least 1m, e.g. 6h", prefix, e)
+		}
+		category.Interval = interval
+	}
+
+	if e := os.Getenv(prefix + "WEIGHT"); e != "" {
+		weight, err := strconv.Atoi(e)
+		if err != nil || weight < -100 || weight > 100 {
+			return Category{}, fmt.Errorf("invalid %sWEIGHT value: %s, expected number -100 - 100", prefix, e)
+		}
+		category.Weight = weight
+	}
+
+	return category, nil
+}
+
+// parseIntel reads platforms from TAXII_URL, TAXII_USER, TAXII_PASSWORD, MISP_URL, MISP_KEY and MISP_TTL envs,
+// polling interval from INTEL_INTERVAL and weight of IP indicators from INTEL_WEIGHT.
+func parseIntel(intel *Intel) error {
+	intel.TAXIIURL = os.Getenv("TAXII_URL")
+	intel.TAXIIUser = os.Getenv("TAXII_USER")
+	intel.TAXIIPassword = os.Getenv("TAXII_PASSWORD")
+	intel.MISPURL = os.Getenv("MISP_URL")
+	intel.MISPKey = os.Getenv("MISP_KEY")
+
+	for _, u := range []string{intel.TAXIIURL, intel.MISPURL} {
+		if u == "" {
+			continue
+		}
+		if _, err := url.ParseRequestURI(u); err != nil {
+			return fmt.Errorf("invalid intel URL: %s, error: %w", u, err)
+		}
+	}
+
+	if intel.MISPURL != "" && intel.MISPKey == "" {
+		return fmt.Errorf("MISP_KEY is required for MISP_URL: %s", intel.MISPURL)
+	}
+
+	if e := os.Getenv("MISP_TTL"); e != "" {
+		ttl, err := time.ParseDuration(e)
+		if err != nil || ttl < 0 {
+			return fmt.Errorf("invalid MISP_TTL value: %s, expected duration, e.g. 720h, 0 means that attributes never expire", e)
+		}
+		intel.MISPTTL = ttl
+	}
+
+	if e := os.Getenv("INTEL_INTERVAL"); e != "" {
+		interval, err := time.ParseDuration(e)
+		if err != nil || interval < time.Minute {
+			return fmt.Errorf("invalid INTEL_INTERVAL value: %s, expected duration of at least 1m, e.g. 15m", e)
+		}
+		intel.Interval = interval
+	}
+
+	if e := os.Getenv("INTEL_WEIGHT"); e != "" {
+		weight, err := strconv.Atoi(e)
+		if err != nil || weight < -100 || weight > 100 {
+			return fmt.Errorf("invalid INTEL_WEIGHT value: %s, expected number -100 - 100", e)
+		}
+		intel.Weight = weight
+	}
+
+	return nil
+}
+
+// parseGuards reads protected ranges from GUARD_PROTECTED_RANGES env and limits of lists from <LIST>_GUARD envs,
+// e.g. PROXY_GUARD=max_prefix=16&max_change=50. Given limits replace defaults, other limits are kept.
+// INTEL_GUARD applies to IP and email indicators, CATEGORY_<NAME>_GUARD to the category (default: max_prefix=10,
+// max_prefix6=24, min_entries=1 and protected=true).
+// Guards are keyed by list name, not by source: all sources of the list share its limits, max_change is checked
+// for the whole list and for each source separately, other limits apply to every entry regardless of its source.
+func parseGuards(config *Config) error {
+	for _, r := range strings.Fields(os.Getenv("GUARD_PROTECTED_RANGES")) {
+		if _, _, err := net.ParseCIDR(r); err != nil {
+			return fmt.Errorf("invalid GUARD_PROTECTED_RANGES value: %s, expected CIDR, e.g. 192.0.2.0/24", r)
+		}
+		config.ProtectedRanges = append(config.ProtectedRanges, r)
+	}
+
+	envs := map[string][]string{
+		"PROXY_GUARD":          {"proxy"},
+		"SPAM_GUARD":           {"spam"},
+		"VPN_GUARD":            {"vpn"},
+		"DC_GUARD":             {"datacenter"},
+		"BOGON_GUARD":          {"bogon"},
+		"CLOUD_GUARD":          {"cloud"},
+		"CRAWLER_GUARD":        {"crawler"},
+		"EMAIL_DISPOSAL_GUARD": {"disposal"},
+		"EMAIL_FREE_GUARD":     {"free"},
+		"INTEL_GUARD":          {"threat_intel", "threat"},
+	}
+	for _, category := range config.Categories {
+		config.Guards[category.Name] = Guard{MaxPrefix4: 10, MaxPrefix6: 24, MinEntries: 1, Protected: true}
+		envs["CATEGORY_"+strings.ToUpper(category.Name)+"_GUARD"] = []string{category.Name}
+	}
+
+	for env, lists := range envs {
+		e := os.Getenv(env)
+		if e == "" {
+			continue
+		}
+		for _, list := range lists {
+			g, err := parseGuard(e, config.Guards[list])
+			if err != nil {
+				return fmt.Errorf("invalid %s value: %s, error: %w", env, e, err)
+			}
+			config.Guards[list] = g
+		}
+	}
+
+	return nil
+}
+
+// parseGuard parses limits in form of URL query, e.g. max_prefix=10&max_prefix6=24&min_entries=1&max_change=90&protected=true.
+func parseGuard(e string, g Guard) (Guard, error) {
+	values, err := url.ParseQuery(e)
+	if err != nil {
+		return g, err
+	}
+
+	limits := map[string]struct {
+		value    *int
+		min, max int
+	}{
+		"max_prefix":  {&g.MaxPrefix4, 0, 32},
+		"max_prefix6": {&g.MaxPrefix6, 0, 128},
+		"min_entries": {&g.MinEntries, 0, 1 << 30},
+		"max_change":  {&g.MaxChange, 0, 99},
+	}
+	for key := range values {
+		value := values.Get(key)
+		if key == "protected" {
+			if g.Protected, err = strconv.ParseBool(value); err != nil {
+				return g, fmt.Errorf("invalid protected value: %s, expected true or false", value)
+			}
+			continue
+		}
+
+		limit, ok := limits[key]
+		if !ok {
+			return g, fmt.Errorf("unknown limit: %s, expected max_prefix, max_prefix6, min_entries, max_change or protected", key)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < limit.min || n > limit.max {
+			return g, fmt.Errorf("invalid %s value: %s, expected number %d - %d", key, value, limit.min, limit.max)
+		}
+		*limit.value = n
+	}
+
+	return g, nil
+}
+
+// maxmindSource returns the source of the first MaxMind edition, which is validated as other sources.
+func maxmindSource(template string) string {
+	return strings.Replace(template, "{edition}", "GeoLite2-ASN", -1)
+}
+
+// parseSigningKeys reads public keys from SIGNING_KEYS env in form of name=key separated by space
+// and checks that keys used by sources are defined.
+func parseSigningKeys(config *Config) error {
+	config.SigningKeys = map[string]string{}
+	for _, e := range strings.Fields(os.Getenv("SIGNING_KEYS")) {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid SIGNING_KEYS value: %s, expected name=key", e)
+		}
+		if _, err := fetch.ParsePublicKey(parts[1]); err != nil {
+			return fmt.Errorf("invalid signing key: %s, error: %w", parts[0], err)
+		}
+		config.SigningKeys[parts[0]] = parts[1]
+	}
+
+	var sources []string
+	for _, list := range [][]string{config.ProxyList, config.SpamList, config.VPNList, config.DCList, config.BogonList,
+		config.EmailDisposalList, config.EmailFreeList, config.FingerprintList, config.PatternLists.DCNames,
+		config.PatternLists.DCHosts, config.PatternLists.ProxyHosts, config.PatternLists.BotAgents} {
+		sources = append(sources, list...)
+	}
+	for _, source := range config.CloudList {
+		sources = append(sources, source.URL)
+	}
+	for _, category := range config.Categories {
+		sources = append(sources, category.Sources...)
+	}
+	if config.MaxmindURL != "" {
+		sources = append(sources, maxmindSource(config.MaxmindURL))
+	}
+
+	for _, source := range sources {
+		name, _, err := fetch.Signed(source)
+		if err != nil {
+			return err
+		}
+		if _, ok := config.SigningKeys[name]; name != "" && !ok {
+			return fmt.Errorf("signing key: %s of source: %s is not defined in SIGNING_KEYS", name, source)
+		}
+	}
+	return nil
+}
+
+// parseSensitiveRoute parses route in form of METHOD:HOST/PATH, e.g. POST:example.com/login or *:*.example.com/signup*.
+func parseSensitiveRoute(e string) (SensitiveRoute, error) {
+	parts := strings.SplitN(e, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return SensitiveRoute{}, fmt.Errorf("invalid sensitive route: %s, expected METHOD:HOST/PATH", e)
+	}
+
+	i := strings.IndexByte(parts[1], '/')
+	if i <= 0 {
+		return SensitiveRoute{}, fmt.Errorf("invalid sensitive route: %s, expected METHOD:HOST/PATH", e)
+	}
+	route := SensitiveRoute{Method: strings.ToUpper(parts[0]), Host: parts[1][:i], Path: parts[1][i:]}
+
+	for _, pattern := range []string{route.Host, route.Path} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return SensitiveRoute{}, fmt.Errorf("invalid sensitive route pattern: %s, error: %w", pattern, err)
+		}
+	}
+	return route, nil
+}
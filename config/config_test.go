@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -37,8 +38,19 @@ func TestNewConfigLists(t *testing.T) {
 			wantErr: false,
 			list:    "https://some_url.com https://next_url_.com",
 		},
+		{
+			name:    "proxy list local file and directory",
+			wantErr: false,
+			list:    "file:///var/lib/threatbite/proxy.txt /var/lib/threatbite/proxy.d/",
+		},
+		{
+			name:    "proxy list unsupported scheme",
+			wantErr: true,
+			list:    "ftp://some_url.com/proxy.txt",
+		},
 	}
-	for _, env := range []string{"PROXY_LIST", "SPAM_LIST", "VPN_LIST", "DC_LIST", "EMAIL_DISPOSAL_LIST", "EMAIL_FREE_LIST"} {
+	for _, env := range []string{"PROXY_LIST", "SPAM_LIST", "VPN_LIST", "DC_LIST", "BOGON_LIST", "EMAIL_DISPOSAL_LIST", "EMAIL_FREE_LIST", "FINGERPRINT_LIST",
+		"DC_NAMES_LIST", "DC_HOSTS_LIST", "PROXY_HOSTS_LIST", "BOT_AGENTS_LIST"} {
 		for _, tt := range tests {
 			t.Run(tt.name+"_"+env, func(t *testing.T) {
 				err := os.Setenv(env, tt.list)
@@ -54,3 +66,395 @@ func TestNewConfigLists(t *testing.T) {
 		os.Unsetenv(env)
 	}
 }
+
+func TestNewConfigCloudList(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+		list    string
+		want    []CloudSource
+	}{
+		{
+			name:    "missing provider",
+			wantErr: true,
+			list:    "https://ip-ranges.amazonaws.com/ip-ranges.json",
+		},
+		{
+			name:    "invalid URL",
+			wantErr: true,
+			list:    "aws=invalid_url",
+		},
+		{
+			name: "valid more",
+			list: "aws=https://ip-ranges.amazonaws.com/ip-ranges.json azure=https://download.microsoft.com/ServiceTags_Public.json",
+			want: []CloudSource{
+				{Provider: "aws", URL: "https://ip-ranges.amazonaws.com/ip-ranges.json"},
+				{Provider: "azure", URL: "https://download.microsoft.com/ServiceTags_Public.json"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := os.Setenv("CLOUD_LIST", tt.list)
+			assert.NoError(t, err)
+
+			config, err := NewConfig("")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, config.CloudList)
+			}
+		})
+	}
+	os.Unsetenv("CLOUD_LIST")
+}
+
+func TestNewConfigThresholds(t *testing.T) {
+	tests := []struct {
+		name          string
+		wantErr       bool
+		block         string
+		challenge     string
+		wantBlock     uint8
+		wantChallenge uint8
+	}{
+		{
+			name:          "default",
+			wantBlock:     20,
+			wantChallenge: 50,
+		},
+		{
+			name:          "valid",
+			block:         "10",
+			challenge:     "70",
+			wantBlock:     10,
+			wantChallenge: 70,
+		},
+		{
+			name:    "invalid number",
+			wantErr: true,
+			block:   "low",
+		},
+		{
+			name:      "out of range",
+			wantErr:   true,
+			challenge: "101",
+		},
+		{
+			name:      "block greater than challenge",
+			wantErr:   true,
+			block:     "60",
+			challenge: "40",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, os.Setenv("ACTION_BLOCK_BELOW", tt.block))
+			assert.NoError(t, os.Setenv("ACTION_CHALLENGE_BELOW", tt.challenge))
+
+			config, err := NewConfig("")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.wantBlock, config.ActionBlockBelow)
+				assert.Equal(t, tt.wantChallenge, config.ActionChallengeBelow)
+			}
+		})
+	}
+	os.Unsetenv("ACTION_BLOCK_BELOW")
+	os.Unsetenv("ACTION_CHALLENGE_BELOW")
+}
+
+func TestNewConfigVelocity(t *testing.T) {
+	config, err := NewConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(120), config.Velocity.IPMinute)
+	assert.Equal(t, uint32(0), config.Velocity.URIMinute)
+
+	assert.NoError(t, os.Setenv("VELOCITY_IP_MINUTE", "0"))
+	assert.NoError(t, os.Setenv("VELOCITY_URI_MINUTE", "5000"))
+	config, err = NewConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), config.Velocity.IPMinute)
+	assert.Equal(t, uint32(5000), config.Velocity.URIMinute)
+
+	assert.NoError(t, os.Setenv("VELOCITY_IP_MINUTE", "-1"))
+	_, err = NewConfig("")
+	assert.Error(t, err)
+
+	os.Unsetenv("VELOCITY_IP_MINUTE")
+	os.Unsetenv("VELOCITY_URI_MINUTE")
+}
+
+func TestNewConfigSensitiveRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+		routes  string
+		want    []SensitiveRoute
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:   "valid",
+			routes: "POST:example.com/login *:*.example.com/signup/*",
+			want: []SensitiveRoute{
+				{Method: "POST", Host: "example.com", Path: "/login"},
+				{Method: "*", Host: "*.example.com", Path: "/signup/*"},
+			},
+		},
+		{
+			name:    "missing method",
+			wantErr: true,
+			routes:  "example.com/login",
+		},
+		{
+			name:    "missing host",
+			wantErr: true,
+			routes:  "POST:/login",
+		},
+		{
+			name:    "invalid pattern",
+			wantErr: true,
+			routes:  "POST:example.com/[login",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, os.Setenv("SENSITIVE_ROUTES", tt.routes))
+
+			config, err := NewConfig("")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, config.SensitiveRoutes)
+			}
+		})
+	}
+	os.Unsetenv("SENSITIVE_ROUTES")
+}
+
+func TestNewConfigReputationHalfLife(t *testing.T) {
+	config, err := NewConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, 720*time.Hour, config.ReputationHalfLife)
+
+	assert.NoError(t, os.Setenv("REPUTATION_HALF_LIFE", "168h"))
+	config, err = NewConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, 168*time.Hour, config.ReputationHalfLife)
+
+	for _, value := range []string{"week", "-1h", "0"} {
+		assert.NoError(t, os.Setenv("REPUTATION_HALF_LIFE", value))
+		_, err = NewConfig("")
+		assert.Error(t, err, value)
+	}
+	os.Unsetenv("REPUTATION_HALF_LIFE")
+}
+
+func TestNewConfigCategories(t *testing.T) {
+	config, err := NewConfig("")
+	assert.NoError(t, err)
+	assert.Empty(t, config.Categories)
+
+	assert.NoError(t, os.Setenv("CATEGORIES", "botnet scanners"))
+	assert.NoError(t, os.Setenv("CATEGORY_BOTNET_LIST", "https://example.com/c2.txt https://example.org/c2.txt"))
+	assert.NoError(t, os.Setenv("CATEGORY_BOTNET_INTERVAL", "1h"))
+	assert.NoError(t, os.Setenv("CATEGORY_BOTNET_WEIGHT", "-60"))
+	assert.NoError(t, os.Setenv("CATEGORY_SCANNERS_LIST", "https://example.com/scanners.txt"))
+	config, err = NewConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, []Category{
+		{Name: "botnet", Sources: []string{"https://example.com/c2.txt", "https://example.org/c2.txt"}, Interval: time.Hour, Weight: -60},
+		{Name: "scanners", Sources: []string{"https://example.com/scanners.txt"}, Interval: 12 * time.Hour, Weight: -20},
+	}, config.Categories)
+
+	invalid := map[string]string{
+		"CATEGORY_BOTNET_INTERVAL": "10s",
+		"CATEGORY_BOTNET_WEIGHT":   "-101",
+		"CATEGORY_BOTNET_LIST":     "c2.txt",
+		"CATEGORIES":               "botnet scanners Abuse",
+	}
+	for env, value := range invalid {
+		previous := os.Getenv(env)
+		assert.NoError(t, os.Setenv(env, value))
+		_, err = NewConfig("")
+		assert.Error(t, err, env)
+		assert.NoError(t, os.Setenv(env, previous))
+	}
+
+	os.Unsetenv("CATEGORY_SCANNERS_LIST")
+	_, err = NewConfig("")
+	assert.Error(t, err)
+
+	for _, env := range []string{"CATEGORIES", "CATEGORY_BOTNET_LIST", "CATEGORY_BOTNET_INTERVAL", "CATEGORY_BOTNET_WEIGHT"} {
+		os.Unsetenv(env)
+	}
+}
+
+func TestNewConfigIntel(t *testing.T) {
+	config, err := NewConfig("")
+	assert.NoError(t, err)
+	assert.False(t, config.Intel.Enabled())
+	assert.Equal(t, Intel{MISPTTL: 720 * time.Hour, Interval: 15 * time.Minute, Weight: -40}, config.Intel)
+
+	envs := map[string]string{
+		"TAXII_URL":      "https://taxii.example.com/api/collections/indicators/",
+		"TAXII_USER":     "soc",
+		"TAXII_PASSWORD": "secret",
+		"MISP_URL":       "https://misp.example.com",
+		"MISP_KEY":       "key",
+		"MISP_TTL":       "0",
+		"INTEL_INTERVAL": "1h",
+		"INTEL_WEIGHT":   "-80",
+	}
+	for env, value := range envs {
+		assert.NoError(t, os.Setenv(env, value))
+	}
+	config, err = NewConfig("")
+	assert.NoError(t, err)
+	assert.True(t, config.Intel.Enabled())
+	assert.Equal(t, Intel{
+		TAXIIURL:      "https://taxii.example.com/api/collections/indicators/",
+		TAXIIUser:     "soc",
+		TAXIIPassword: "secret",
+		MISPURL:       "https://misp.example.com",
+		MISPKey:       "key",
+		Interval:      time.Hour,
+		Weight:        -80,
+	}, config.Intel)
+
+	invalid := map[string]string{
+		"TAXII_URL":      "collections",
+		"MISP_KEY":       "",
+		"MISP_TTL":       "-1h",
+		"INTEL_INTERVAL": "10s",
+		"INTEL_WEIGHT":   "101",
+	}
+	for env, value := range invalid {
+		assert.NoError(t, os.Setenv(env, value))
+		_, err = NewConfig("")
+		assert.Error(t, err, env)
+		assert.NoError(t, os.Setenv(env, envs[env]))
+	}
+
+	for env := range envs {
+		os.Unsetenv(env)
+	}
+}
+
+func TestNewConfigWatchInterval(t *testing.T) {
+	config, err := NewConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, config.WatchInterval)
+
+	for value, want := range map[string]time.Duration{"5s": 5 * time.Second, "0": 0} {
+		assert.NoError(t, os.Setenv("WATCH_INTERVAL", value))
+		config, err = NewConfig("")
+		assert.NoError(t, err)
+		assert.Equal(t, want, config.WatchInterval)
+	}
+
+	for _, value := range []string{"often", "-1s", "10ms"} {
+		assert.NoError(t, os.Setenv("WATCH_INTERVAL", value))
+		_, err = NewConfig("")
+		assert.Error(t, err, value)
+	}
+	os.Unsetenv("WATCH_INTERVAL")
+}
+
+func TestNewConfigGuards(t *testing.T) {
+	config, err := NewConfig("")
+	assert.NoError(t, err)
+	assert.Empty(t, config.ProtectedRanges)
+	assert.Equal(t, Guard{MaxPrefix4: 10, MaxPrefix6: 24, MinEntries: 1, MaxChange: 90, Protected: true}, config.Guards["proxy"])
+	assert.Equal(t, Guard{MinEntries: 1, MaxChange: 90}, config.Guards["bogon"])
+	assert.Equal(t, Guard{MinEntries: 1, MaxChange: 90}, config.Guards["free"])
+
+	envs := map[string]string{
+		"GUARD_PROTECTED_RANGES": "198.51.100.0/24 2001:db8::/32",
+		"PROXY_GUARD":            "max_prefix=16&max_change=50",
+		"EMAIL_FREE_GUARD":       "min_entries=0&max_change=0",
+		"INTEL_GUARD":            "protected=false",
+		"CATEGORIES":             "botnet",
+		"CATEGORY_BOTNET_LIST":   "https://example.com/c2.txt",
+		"CATEGORY_BOTNET_GUARD":  "min_entries=100",
+	}
+	for env, value := range envs {
+		assert.NoError(t, os.Setenv(env, value))
+	}
+	config, err = NewConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"198.51.100.0/24", "2001:db8::/32"}, config.ProtectedRanges)
+	assert.Equal(t, Guard{MaxPrefix4: 16, MaxPrefix6: 24, MinEntries: 1, MaxChange: 50, Protected: true}, config.Guards["proxy"])
+	assert.Equal(t, Guard{}, config.Guards["free"])
+	assert.Equal(t, Guard{MaxPrefix4: 10, MaxPrefix6: 24}, config.Guards["threat_intel"])
+	assert.Equal(t, Guard{}, config.Guards["threat"])
+	assert.Equal(t, Guard{MaxPrefix4: 10, MaxPrefix6: 24, MinEntries: 100, Protected: true}, config.Guards["botnet"])
+
+	invalid := map[string]string{
+		"GUARD_PROTECTED_RANGES": "198.51.100.0",
+		"PROXY_GUARD":            "max_prefix=33",
+		"EMAIL_FREE_GUARD":       "max_change=100",
+		"INTEL_GUARD":            "protected=maybe",
+		"CATEGORY_BOTNET_GUARD":  "max_size=10",
+		"CATEGORIES":             "proxy",
+	}
+	for env, value := range invalid {
+		assert.NoError(t, os.Setenv(env, value))
+		_, err = NewConfig("")
+		assert.Error(t, err, env)
+		assert.NoError(t, os.Setenv(env, envs[env]))
+	}
+
+	for env := range envs {
+		os.Unsetenv(env)
+	}
+}
+
+func TestNewConfigSigningKeys(t *testing.T) {
+	config, err := NewConfig("")
+	assert.NoError(t, err)
+	assert.Empty(t, config.SigningKeys)
+	assert.Empty(t, config.MaxmindURL)
+
+	envs := map[string]string{
+		"SIGNING_KEYS": "vendor=RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3 " +
+			"mirror=11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=",
+		"PROXY_LIST":  "https://example.com/proxy.txt#key=vendor",
+		"MAXMIND_URL": "https://mirror.example.com/{edition}.tar.gz#key=mirror",
+	}
+	for env, value := range envs {
+		assert.NoError(t, os.Setenv(env, value))
+	}
+	config, err = NewConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"vendor": "RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3",
+		"mirror": "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=",
+	}, config.SigningKeys)
+	assert.Equal(t, "https://mirror.example.com/{edition}.tar.gz#key=mirror", config.MaxmindURL)
+
+	invalid := map[string]string{
+		"SIGNING_KEYS": "vendor=invalid",
+		"PROXY_LIST":   "https://example.com/proxy.txt#key=unknown",
+		"MAXMIND_URL":  "mirror/{edition}.tar.gz",
+	}
+	for env, value := range invalid {
+		assert.NoError(t, os.Setenv(env, value))
+		_, err = NewConfig("")
+		assert.Error(t, err, env)
+		assert.NoError(t, os.Setenv(env, envs[env]))
+	}
+
+	for env := range envs {
+		os.Unsetenv(env)
+	}
+}
@@ -0,0 +1,184 @@
+// Package credential protects sensitive routes (login, signup) against credential stuffing.
+// It counts distinct usernames and failed logins per IP address and raises signals for suspicious clients.
+package credential
+
+import (
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/optimatiq/threatbite/scoring"
+	"github.com/optimatiq/threatbite/velocity"
+)
+
+// Signals raised for requests to sensitive routes.
+const (
+	SignalDistinctUsernames = "distinct_usernames"
+	SignalFailedLogins      = "failed_logins"
+	SignalSensitiveScript   = "sensitive_script"
+	SignalSensitiveDC       = "sensitive_datacenter"
+)
+
+// Weights of the signals.
+const (
+	distinctUsernamesWeight = -30
+	failedLoginsWeight      = -25
+	sensitiveScriptWeight   = -20
+	sensitiveDCWeight       = -15
+)
+
+// usernamesWindow is a time, in which distinct usernames are counted.
+const usernamesWindow = time.Hour
+
+// Route is a sensitive route, Host and Path are glob patterns (path.Match syntax), Method "*" matches any method.
+type Route struct {
+	Method string
+	Host   string
+	Path   string
+}
+
+// Match returns true when request matches the route, query string of the URI is ignored.
+func (r Route) Match(method, host, uri string) bool {
+	if r.Method != "*" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	if ok, _ := path.Match(strings.ToLower(r.Host), strings.ToLower(host)); !ok {
+		return false
+	}
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i]
+	}
+	ok, _ := path.Match(r.Path, uri)
+	return ok
+}
+
+// Limits of usernames and failed logins per IP address in 1 hour, zero disables the check.
+type Limits struct {
+	UsernamesPerIP    int
+	FailedLoginsPerIP uint32
+}
+
+// Request contains information about the request to sensitive route.
+type Request struct {
+	IP           net.IP
+	UsernameHash string
+	Script       bool
+	Datacenter   bool
+}
+
+// Guard checks requests to sensitive routes.
+type Guard struct {
+	routes     []Route
+	limits     Limits
+	thresholds scoring.Thresholds
+
+	mu        sync.Mutex
+	usernames *lru.Cache
+	failed    *velocity.Tracker
+	now       func() time.Time
+}
+
+// NewGuard creates guard for given routes, size limits number of tracked IP addresses.
+// Thresholds are used instead of the default ones to decide about the action for sensitive routes.
+func NewGuard(routes []Route, limits Limits, thresholds scoring.Thresholds, size int) (*Guard, error) {
+	usernames, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	failed, err := velocity.NewTracker(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Guard{
+		routes:     routes,
+		limits:     limits,
+		thresholds: thresholds,
+		usernames:  usernames,
+		failed:     failed,
+		now:        time.Now,
+	}, nil
+}
+
+// Sensitive returns true when request matches any of the sensitive routes. It's safe to call it on nil Guard.
+func (g *Guard) Sensitive(method, host, uri string) bool {
+	if g == nil {
+		return false
+	}
+	for _, route := range g.routes {
+		if route.Match(method, host, uri) {
+			return true
+		}
+	}
+	return false
+}
+
+// Thresholds returns thresholds for sensitive routes.
+func (g *Guard) Thresholds() scoring.Thresholds {
+	return g.thresholds
+}
+
+// Check counts username (when hash is provided) and returns signals for the request to sensitive route.
+func (g *Guard) Check(r Request) scoring.Signals {
+	var signals scoring.Signals
+	if g == nil {
+		return signals
+	}
+
+	if r.UsernameHash != "" && g.limits.UsernamesPerIP > 0 && g.observeUsername(r.IP, r.UsernameHash) > g.limits.UsernamesPerIP {
+		signals.Add(SignalDistinctUsernames, distinctUsernamesWeight)
+	}
+	if g.limits.FailedLoginsPerIP > 0 && g.failed.Counts(r.IP.String()).Hour > g.limits.FailedLoginsPerIP {
+		signals.Add(SignalFailedLogins, failedLoginsWeight)
+	}
+	if r.Script {
+		signals.Add(SignalSensitiveScript, sensitiveScriptWeight)
+	}
+	if r.Datacenter {
+		signals.Add(SignalSensitiveDC, sensitiveDCWeight)
+	}
+	return signals
+}
+
+// Failed records failed login from the IP address, username hash (optional) is counted as distinct username.
+// It's safe to call it on nil Guard.
+func (g *Guard) Failed(ip net.IP, usernameHash string) {
+	if g == nil {
+		return
+	}
+	g.failed.Observe(ip.String())
+	if usernameHash != "" {
+		g.observeUsername(ip, usernameHash)
+	}
+}
+
+// observeUsername records username hash and returns number of distinct usernames seen from the IP address.
+// Number of stored hashes is limited, so a single address cannot use unbounded memory.
+func (g *Guard) observeUsername(ip net.IP, usernameHash string) int {
+	now := g.now()
+	key := ip.String()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var seen map[string]time.Time
+	if v, ok := g.usernames.Get(key); ok {
+		seen = v.(map[string]time.Time)
+	} else {
+		seen = map[string]time.Time{}
+		g.usernames.Add(key, seen)
+	}
+
+	for hash, t := range seen {
+		if now.Sub(t) > usernamesWindow {
+			delete(seen, hash)
+		}
+	}
+	if _, ok := seen[usernameHash]; ok || len(seen) <= g.limits.UsernamesPerIP {
+		seen[usernameHash] = now
+	}
+	return len(seen)
+}
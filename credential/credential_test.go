@@ -0,0 +1,75 @@
+package credential
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/optimatiq/threatbite/scoring"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		route  Route
+		method string
+		host   string
+		uri    string
+		want   bool
+	}{
+		{"exact", Route{"POST", "example.com", "/login"}, "POST", "example.com", "/login", true},
+		{"query", Route{"POST", "example.com", "/login"}, "post", "Example.com", "/login?next=/", true},
+		{"method", Route{"POST", "example.com", "/login"}, "GET", "example.com", "/login", false},
+		{"any method", Route{"*", "example.com", "/login"}, "GET", "example.com", "/login", true},
+		{"host glob", Route{"POST", "*.example.com", "/signup/*"}, "POST", "shop.example.com", "/signup/confirm", true},
+		{"other host", Route{"POST", "*.example.com", "/login"}, "POST", "example.org", "/login", false},
+		{"other path", Route{"POST", "example.com", "/login"}, "POST", "example.com", "/logout", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.route.Match(tt.method, tt.host, tt.uri))
+		})
+	}
+}
+
+func TestGuard(t *testing.T) {
+	g, err := NewGuard([]Route{{"POST", "example.com", "/login"}}, Limits{UsernamesPerIP: 2, FailedLoginsPerIP: 2},
+		scoring.Thresholds{BlockBelow: 40, ChallengeBelow: 70}, 16)
+	assert.NoError(t, err)
+	now := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+
+	assert.Equal(t, uint8(70), g.Thresholds().ChallengeBelow)
+	assert.True(t, g.Sensitive("POST", "example.com", "/login"))
+	assert.False(t, g.Sensitive("GET", "example.com", "/"))
+
+	ip := net.ParseIP("192.0.2.1")
+	check := func(hash string) []string {
+		return g.Check(Request{IP: ip, UsernameHash: hash}).Names()
+	}
+
+	assert.Empty(t, check("a"))
+	assert.Empty(t, check("b"))
+	assert.Empty(t, check("a"))
+	assert.Equal(t, []string{SignalDistinctUsernames}, check("c"))
+	assert.Empty(t, g.Check(Request{IP: net.ParseIP("192.0.2.2"), UsernameHash: "c"}).Names())
+
+	// usernames expire after 1 hour
+	now = now.Add(2 * time.Hour)
+	assert.Empty(t, check("d"))
+
+	g.Failed(ip, "")
+	g.Failed(ip, "")
+	assert.Empty(t, check(""))
+	g.Failed(ip, "")
+	assert.Equal(t, []string{SignalFailedLogins}, check(""))
+
+	assert.Equal(t, []string{SignalSensitiveScript, SignalSensitiveDC},
+		g.Check(Request{IP: net.ParseIP("192.0.2.3"), Script: true, Datacenter: true}).Names())
+
+	var disabled *Guard
+	assert.False(t, disabled.Sensitive("POST", "example.com", "/login"))
+	assert.Empty(t, disabled.Check(Request{IP: ip}))
+	disabled.Failed(ip, "a")
+}
@@ -18,3 +18,17 @@ type DataSource interface {
 	Next() (string, error)
 	Reset() error
 }
+
+// WatchedDataSource is a DataSource, which is able to detect changes of its local files.
+type WatchedDataSource interface {
+	DataSource
+	// Changed returns true when files were modified since the last Reset.
+	Changed() bool
+}
+
+// SourcedDataSource is a DataSource, which combines several sources (e.g. URLs), so their sizes can be checked separately.
+type SourcedDataSource interface {
+	DataSource
+	// Source returns the source of the entry returned by the last call of Next.
+	Source() string
+}
@@ -1,7 +1,10 @@
 package datasource
 
 import (
+	"io/ioutil"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -20,7 +23,7 @@ func (suite *DatasourceSuite) SetupTest() {
 func (suite *DatasourceSuite) Test_NewURLDataSource() {
 	ds := NewURLDataSource([]string{
 		"https://iplists.firehol.org/files/proxz_1d.ipset",
-	})
+	}, nil, time.Second)
 
 	ip, err := ds.Next()
 	suite.NoError(err)
@@ -28,13 +31,46 @@ func (suite *DatasourceSuite) Test_NewURLDataSource() {
 
 	ds = NewURLDataSource([]string{
 		"invalid",
-	})
+	}, nil, time.Second)
 
 	ip, err = ds.Next()
 	suite.Error(err)
 	suite.Empty(ip)
 }
 
+func (suite *DatasourceSuite) Test_DomainReload() {
+	ds := NewListDataSource([]string{"example.com", "Example.org"})
+	domain := NewDomain(ds, "test")
+	suite.NoError(domain.Load())
+	suite.True(domain.Check("example.com"))
+	suite.True(domain.Check("example.org"))
+
+	ds.list = []string{"example.org"}
+	suite.NoError(domain.Load())
+	suite.False(domain.Check("example.com"))
+	suite.True(domain.Check("example.org"))
+}
+
+func (suite *DatasourceSuite) Test_URLDataSourceLocal() {
+	dir, err := ioutil.TempDir("", "datasource")
+	suite.NoError(err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "disposal.txt")
+	suite.NoError(ioutil.WriteFile(file, []byte("# comment\nmaildrop.cc\n"), 0600))
+
+	domain := NewDomain(NewURLDataSource([]string{dir}, nil, time.Second), "disposal")
+	suite.NoError(domain.Load())
+	suite.True(domain.Check("maildrop.cc"))
+	suite.False(domain.Changed())
+
+	suite.NoError(ioutil.WriteFile(file, []byte("mailinator.com\n"), 0600))
+	suite.True(domain.Changed())
+	suite.NoError(domain.Load())
+	suite.False(domain.Check("maildrop.cc"))
+	suite.True(domain.Check("mailinator.com"))
+}
+
 func TestDatasourceSuite(t *testing.T) {
 	suite.Run(t, new(DatasourceSuite))
 }
@@ -3,78 +3,85 @@ package datasource
 import (
 	"bufio"
 	"bytes"
-	"io/ioutil"
-	"net"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/fetch"
 )
 
 // URLDataSource stores current state (counters, URLs, scanners) of this source.
 type URLDataSource struct {
+	sources []string
 	urls    []string
 	u       int
 	scanner *bufio.Scanner
 	client  *http.Client
+	keys    fetch.Keys
+	watcher *fetch.Watcher
 }
 
 // NewURLDataSource returns iterator, which downloads lists from provided URLs and extract addresses.
+// URLs can also point to local files and directories (file:// URLs or paths), all files of the directory are read.
 // Comments are allowed and ignored. Comments start with # at the beginning of the line.
 // Some lists have comments after their address, they are also ignored
-func NewURLDataSource(urls []string) *URLDataSource {
+// Signed URLs are verified with keys, local files are checked for changes every poll interval (0 disables it).
+func NewURLDataSource(urls []string, keys fetch.Keys, poll time.Duration) *URLDataSource {
 	dataSource := &URLDataSource{
-		client: &http.Client{
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   60 * time.Second,
-					KeepAlive: 15 * time.Second,
-				}).DialContext,
-				TLSHandshakeTimeout:   60 * time.Second,
-				ExpectContinueTimeout: 10 * time.Second,
-				ResponseHeaderTimeout: 10 * time.Second,
-			},
-			Timeout: 120 * time.Second,
-		},
-		urls: urls,
+		client:  fetch.NewHTTPClient(),
+		keys:    keys,
+		sources: urls,
+		watcher: fetch.NewWatcher(urls, poll),
 	}
 
 	return dataSource
 }
 
-// Reset rewinds source to the beginning.
+// Reset rewinds source to the beginning, local directories are listed again.
 func (s *URLDataSource) Reset() error {
+	s.watcher.Update()
+	s.urls = nil
 	s.u = 0
 	s.scanner = nil
 	return nil
 }
 
+// Changed returns true when local files of the source were modified since the last Reset.
+func (s *URLDataSource) Changed() bool {
+	return s.watcher.Changed()
+}
+
+// Source returns URL (or file of the directory) of the domain returned by the last call of Next.
+func (s *URLDataSource) Source() string {
+	if s.u >= len(s.urls) {
+		return ""
+	}
+	return s.urls[s.u]
+}
+
 func (s *URLDataSource) Next() (string, error) {
+	if s.urls == nil {
+		s.urls = fetch.Expand(s.sources)
+	}
 	if s.u >= len(s.urls) || len(s.urls) <= 0 {
 		return "", ErrNoData
 	}
 	url := s.urls[s.u]
 
 	if s.scanner == nil {
-		response, err := s.client.Get(url)
+		body, err := fetch.Get(s.client, url, s.keys)
 		if err != nil {
-			log.Errorf("[datasource] cannot download list from: %s, error: %s", url, err)
-			s.u++
-			return "", ErrInvalidData
-		}
-
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			log.Errorf("[datasource] cannot read from: %s, error: %s", url, err)
 			s.u++
+			// unverified signed source is returned, so the update can be rejected and the current list kept
+			if errors.Is(err, fetch.ErrUnverified) {
+				return "", err
+			}
+			log.Errorf("[datasource] cannot download list from: %s, error: %s", url, err)
 			return "", ErrInvalidData
 		}
 
-		if err := response.Body.Close(); err != nil {
-			log.Errorf("[datasource] cannot close response body from: %s, error: %s", url, err)
-		}
-
 		s.scanner = bufio.NewScanner(bytes.NewReader(body))
 	}
 
@@ -1,16 +1,25 @@
 package datasource
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/fetch"
+	"github.com/optimatiq/threatbite/guard"
 )
 
 type Domain struct {
 	domains map[string]bool
+	sources map[string]int
+	lock    sync.RWMutex
 	ds      DataSource
 	name    string
+
+	guard      *guard.Guard
+	quarantine *guard.Quarantine
 }
 
 // NewDomain returns a new domain list build on top of map.
@@ -23,34 +32,96 @@ func NewDomain(ds DataSource, name string) *Domain {
 	}
 }
 
+// Name returns name of the list.
+func (d *Domain) Name() string {
+	return d.name
+}
+
+// SetGuard sets limits of updates, updates, which violate them, are put into the quarantine (optional) instead of applied.
+// Only the number of entries (in total and per source) is checked for domain lists.
+func (d *Domain) SetGuard(g *guard.Guard, quarantine *guard.Quarantine) {
+	d.guard = g
+	d.quarantine = quarantine
+}
+
 // Check if lists contains domain from request
 func (d *Domain) Check(domain string) bool {
+	d.lock.RLock()
 	_, found := d.domains[domain]
+	d.lock.RUnlock()
 	return found
 }
 
+// Changed returns true when the data source detected changes of its local files, so the list should be loaded again.
+func (d *Domain) Changed() bool {
+	if ds, ok := d.ds.(WatchedDataSource); ok {
+		return ds.Changed()
+	}
+	return false
+}
+
+// Load replaces domains with the current content of the data source, so entries removed from the source disappear.
+// The update is applied only when the data source was read completely, on errors the current list is kept.
+// When the guard rejects the update, the current list is kept and the update is quarantined.
+// Sizes of sources of SourcedDataSource are checked separately, so a broken source is not hidden by other sources.
+// Signed sources, which cannot be verified, reject the update as well.
 func (d *Domain) Load() error {
-	log.Debugf("[list] loading %s list start", d.name)
-	defer func() {
-		log.Debugf("[list] loading %s stop; stats domains: %d", d.name, len(d.domains))
-	}()
+	domainsTemp := make(map[string]bool)
+	sourcesTemp := map[string]int{}
+	var unverified error
 
+	log.Debugf("[list] loading %s list start", d.name)
 	if err := d.ds.Reset(); err != nil {
 		return fmt.Errorf("could not reset data source, error: %w", err)
 	}
 
+	sourced, withSources := d.ds.(SourcedDataSource)
+
 	for {
 		domain, err := d.ds.Next()
 		if err != nil {
 			if err == ErrNoData {
-				return nil
+				break
 			} else if err == ErrInvalidData {
 				continue
+			} else if errors.Is(err, fetch.ErrUnverified) {
+				if unverified == nil {
+					unverified = fmt.Errorf("%w: %s", guard.ErrRejected, err)
+				}
+				continue
 			} else {
 				return fmt.Errorf("could not iterate over data source, error: %w", err)
 			}
 		}
 
-		d.domains[strings.ToLower(domain)] = true
+		domainsTemp[strings.ToLower(domain)] = true
+		if withSources {
+			sourcesTemp[sourced.Source()]++
+		}
+	}
+
+	d.lock.RLock()
+	previous, previousSources := len(d.domains), d.sources
+	d.lock.RUnlock()
+
+	err := d.guard.Apply(d.quarantine, guard.Update{
+		List:            d.name,
+		Entries:         len(domainsTemp),
+		Previous:        previous,
+		Sources:         sourcesTemp,
+		PreviousSources: previousSources,
+		Rejected:        unverified,
+		Apply: func() {
+			d.lock.Lock()
+			d.domains = domainsTemp
+			d.sources = sourcesTemp
+			d.lock.Unlock()
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not load %s list, error: %w", d.name, err)
 	}
+
+	log.Debugf("[list] loading %s stop; stats domains: %d", d.name, len(domainsTemp))
+	return nil
 }
@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"github.com/optimatiq/threatbite/email/datasource"
+	"github.com/optimatiq/threatbite/fetch"
+	"github.com/optimatiq/threatbite/guard"
 
 	isd "github.com/jbenet/go-is-domain"
 	"github.com/labstack/gommon/log"
@@ -31,6 +33,17 @@ type Info struct {
 	IsCatchAll        bool
 	IsExistingAccount bool
 	IsLeaked          bool
+
+	// IsThreat is true when the address or its domain is published as a threat indicator.
+	IsThreat bool
+
+	// LocalHistory is reputation of the address built from outcome feedback (-100 - 100), 0 means no history.
+	LocalHistory int
+}
+
+// History returns local reputation of the email address (-100 - 100), 0 means no history.
+type History interface {
+	Email(address string) int
 }
 
 // Email container for email service.
@@ -40,6 +53,8 @@ type Email struct {
 	smtpFrom  string
 	disposal  *disposal
 	free      *free
+	threat    *threat
+	history   History
 }
 
 // NewEmail returns email service, which is used to get detailed information about email address.
@@ -61,6 +76,29 @@ func NewEmail(pwnedKey, smtpHello, smtpFrom string, disposalSources, freeSources
 	}
 }
 
+// SetHistory sets source of local reputation, which adjusts the scoring.
+func (e *Email) SetHistory(history History) {
+	e.history = history
+}
+
+// SetThreats sets list of email addresses and domains published as threat indicators, reloaded with given interval.
+// It has to be called before RunUpdates.
+func (e *Email) SetThreats(source datasource.DataSource, interval time.Duration) {
+	e.threat = newThreat(source, interval)
+}
+
+// SetGuards sets guards of lists by their names, updates rejected by a guard are put into the quarantine.
+// Lists without a guard accept every update. It has to be called after SetThreats and before RunUpdates.
+func (e *Email) SetGuards(guards map[string]*guard.Guard, quarantine *guard.Quarantine) {
+	lists := []*datasource.Domain{e.disposal.domain, e.free.domain}
+	if e.threat != nil {
+		lists = append(lists, e.threat.domain)
+	}
+	for _, list := range lists {
+		list.SetGuard(guards[list.Name()], quarantine)
+	}
+}
+
 // GetInfo returns computed information (Info struct) for given email address.
 func (e *Email) GetInfo(email string) Info {
 	var g errgroup.Group
@@ -115,6 +153,12 @@ func (e *Email) GetInfo(email string) Info {
 		return
 	})
 
+	var isThreat bool
+	g.Go(func() (err error) {
+		isThreat = e.threat.isThreat(email)
+		return
+	})
+
 	_ = g.Wait() // none of the goroutines return error, so we don't need to check it.
 
 	var isValid bool
@@ -190,6 +234,31 @@ func (e *Email) GetInfo(email string) Info {
 		scoring = 100
 	}
 
+	// Threat indicators have very low trust
+	if isThreat {
+		if scoring > 50 {
+			scoring -= 50
+		} else {
+			scoring = 0
+		}
+	}
+
+	// Local history adjusts scoring of valid addresses only
+	var history int
+	if e.history != nil {
+		history = e.history.Email(email)
+		if scoring > 0 {
+			adjusted := int(scoring) + history/2
+			if adjusted < 0 {
+				adjusted = 0
+			}
+			if adjusted > 100 {
+				adjusted = 100
+			}
+			scoring = uint8(adjusted)
+		}
+	}
+
 	return Info{
 		EmailScoring:      scoring,
 		IsDisposal:        isDisposal,
@@ -198,7 +267,9 @@ func (e *Email) GetInfo(email string) Info {
 		IsValid:           isValid,
 		IsCatchAll:        isCatchAll,
 		IsExistingAccount: isExisting,
+		LocalHistory:      history,
 		IsLeaked:          isPwned,
+		IsThreat:          isThreat,
 	}
 }
 
@@ -228,7 +299,7 @@ func (e *Email) isUserDefault(email string) bool {
 // checkDomainMX checks if domain have configured MX record and returns IP with the highest priority
 func (e *Email) getDomainMX(email string) (string, error) {
 	mxRecords, err := lookupMXWithTimeout(strings.Split(email, "@")[1], 1*time.Second)
-	log.Debugf("[checkDomainMX] email: %s mxRecords: %s, error: %s", email, mxRecords, err)
+	log.Debugf("[checkDomainMX] email: %s mxRecords: %v, error: %s", email, mxRecords, err)
 	if err != nil {
 		return "", err
 	}
@@ -417,34 +488,27 @@ func (e *Email) isPwned(email string) bool {
 }
 
 // RunUpdates schedules and runs updates.
-// Update interval is defined for each source individually.
-func (e *Email) RunUpdates() {
+// Update interval is defined for each source individually, local sources are checked for changes every poll interval.
+func (e *Email) RunUpdates(poll time.Duration) {
 	ctx := context.Background()
 
-	runAndSchedule(ctx, 24*time.Hour, func() {
+	fetch.Schedule(ctx, 24*time.Hour, poll, e.disposal.domain.Changed, func() {
 		if err := e.disposal.domain.Load(); err != nil {
 			log.Error(err)
 		}
 	})
 
-	runAndSchedule(ctx, 24*time.Hour, func() {
+	fetch.Schedule(ctx, 24*time.Hour, poll, e.free.domain.Changed, func() {
 		if err := e.free.domain.Load(); err != nil {
 			log.Error(err)
 		}
 	})
-}
 
-func runAndSchedule(ctx context.Context, interval time.Duration, f func()) {
-	go func() {
-		t := time.NewTimer(0) // first run - immediately
-		for {
-			select {
-			case <-t.C:
-				f()
-				t = time.NewTimer(interval) // next runs according to the schedule
-			case <-ctx.Done():
-				return
+	if e.threat != nil {
+		fetch.Schedule(ctx, e.threat.interval, poll, e.threat.domain.Changed, func() {
+			if err := e.threat.domain.Load(); err != nil {
+				log.Error(err)
 			}
-		}
-	}()
+		})
+	}
 }
@@ -13,7 +13,7 @@ type free struct {
 }
 
 func newFree(source datasource.DataSource) *free {
-	return &free{domain: datasource.NewDomain(source, "disposal")}
+	return &free{domain: datasource.NewDomain(source, "free")}
 }
 
 var reFreeSubDomains = regexp.MustCompile(".hub.pl$|.int.pl$")
@@ -0,0 +1,39 @@
+package email
+
+import (
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/email/datasource"
+)
+
+// threat is a list of email addresses and domains published as threat indicators.
+type threat struct {
+	domain   *datasource.Domain
+	interval time.Duration
+}
+
+func newThreat(source datasource.DataSource, interval time.Duration) *threat {
+	return &threat{domain: datasource.NewDomain(source, "threat"), interval: interval}
+}
+
+// isThreat checks the address, its domain and parent domains of the domain, nil list doesn't contain any address.
+func (t *threat) isThreat(email string) bool {
+	if t == nil {
+		return false
+	}
+
+	email = strings.ToLower(email)
+	isThreat := t.domain.Check(email)
+	for domain := strings.Split(email, "@")[1]; !isThreat && domain != ""; {
+		isThreat = t.domain.Check(domain)
+		i := strings.Index(domain, ".")
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+	log.Debugf("[isThreat] email: %s threat: %t", email, isThreat)
+	return isThreat
+}
@@ -0,0 +1,29 @@
+package email
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/optimatiq/threatbite/email/datasource"
+)
+
+func Test_threat_isThreat(t *testing.T) {
+	th := newThreat(datasource.NewListDataSource([]string{"evil.example", "ceo@phish.example"}), 0)
+	assert.NoError(t, th.domain.Load())
+
+	tests := map[string]bool{
+		"xxx@evil.example":      true,
+		"xxx@mail.Evil.Example": true,
+		"CEO@phish.example":     true,
+		"cfo@phish.example":     false,
+		"xxx@example":           false,
+		"xxx@notevil.example":   false,
+	}
+	for email, want := range tests {
+		assert.Equal(t, want, th.isThreat(email), email)
+	}
+
+	var empty *threat
+	assert.False(t, empty.isThreat("xxx@evil.example"))
+}
@@ -0,0 +1,183 @@
+// Package fetch reads lists from remote (http, https) and local (file:// URLs, paths of files and directories) sources.
+// Local sources are polled for changes, so lists are reloaded as soon as their files are modified.
+// Signed sources are verified with detached minisign or ed25519 signatures before they are parsed.
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSource is returned for sources, which are neither http(s) URLs nor local files.
+var ErrInvalidSource = errors.New("invalid source, expected http(s) URL, file:// URL or path")
+
+// NewHTTPClient returns client with timeouts used to download lists.
+func NewHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout:   60 * time.Second,
+				KeepAlive: 15 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   60 * time.Second,
+			ExpectContinueTimeout: 10 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+		},
+		Timeout: 120 * time.Second,
+	}
+}
+
+// Validate returns nil for http(s) URLs with host, file:// URLs and absolute paths, location of the signature
+// (sig option in the fragment) has to be valid as well.
+// Relative paths are rejected, because they are easily confused with misspelled URLs.
+func Validate(source string) error {
+	_, signature, err := Signed(source)
+	if err != nil {
+		return err
+	}
+	if signature != "" && signature != source {
+		if err := Validate(signature); err != nil {
+			return fmt.Errorf("invalid signature location, error: %w", err)
+		}
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return fmt.Errorf("%w: %s, error: %s", ErrInvalidSource, source, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return fmt.Errorf("%w: %s", ErrInvalidSource, source)
+		}
+	case "file", "":
+		if !filepath.IsAbs(localPath(u)) {
+			return fmt.Errorf("%w: %s", ErrInvalidSource, source)
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidSource, source)
+	}
+	return nil
+}
+
+// Path returns path of the local source, empty string is returned for remote sources.
+// The fragment (e.g. format options) is not a part of the path.
+func Path(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "file" && u.Scheme != "") {
+		return ""
+	}
+	return localPath(u)
+}
+
+// localPath returns path of file:// URL or path without scheme, file:// URLs with host other than localhost are not local.
+func localPath(u *url.URL) string {
+	if u.Scheme == "file" && u.Host != "" && u.Host != "localhost" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// Expand replaces local directories with files, which they contain. Files are sorted by name,
+// hidden files and subdirectories are skipped. The fragment of the directory is kept in each file.
+// Other sources, including directories, which cannot be read, are returned unchanged.
+func Expand(sources []string) []string {
+	expanded := make([]string, 0, len(sources))
+	for _, source := range sources {
+		files, err := directory(Path(source))
+		if err != nil {
+			expanded = append(expanded, source)
+			continue
+		}
+
+		var fragment string
+		if u, err := url.Parse(source); err == nil {
+			fragment = u.Fragment
+		}
+		for _, file := range files {
+			u := url.URL{Scheme: "file", Path: filepath.ToSlash(file), Fragment: fragment}
+			expanded = append(expanded, u.String())
+		}
+	}
+	return expanded
+}
+
+// directory returns files of the directory, error is returned when path is not a directory.
+// Signatures of files are not files of the directory.
+func directory(path string) ([]string, error) {
+	if path == "" {
+		return nil, ErrInvalidSource
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", path)
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || strings.HasSuffix(entry.Name(), SignatureSuffix) {
+			continue
+		}
+		files = append(files, filepath.Join(path, entry.Name()))
+	}
+	return files, nil
+}
+
+// Get returns content of the source, the fragment is not sent to the server.
+// Directories are not supported, they have to be expanded first.
+// Sources with key option are verified with the detached signature and the key of that name,
+// ErrUnverified is returned, when the source or its signature cannot be downloaded or verified.
+func Get(client *http.Client, source string, keys Keys) ([]byte, error) {
+	name, signature, err := Signed(source)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		return getSigned(client, source, name, signature, keys)
+	}
+	return get(client, source)
+}
+
+func get(client *http.Client, source string) ([]byte, error) {
+	if path := Path(source); path != "" {
+		return ioutil.ReadFile(path) // #nosec G304
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSource, source)
+	}
+	u.Fragment = ""
+
+	response, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid status code %d", response.StatusCode)
+	}
+
+	return ioutil.ReadAll(response.Body)
+}
@@ -0,0 +1,75 @@
+package fetch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	for _, source := range []string{
+		"https://example.com/list.txt",
+		"http://example.com/list.csv#format=csv&ip=2",
+		"file:///var/lib/threatbite/list.txt",
+		"file://localhost/var/lib/threatbite/",
+		"/var/lib/threatbite/lists#format=drop",
+	} {
+		assert.NoError(t, Validate(source), source)
+	}
+
+	for _, source := range []string{"", "list.txt", "https:///list.txt", "ftp://example.com/list.txt", "file://example.com/list.txt", "%zz"} {
+		assert.Error(t, Validate(source), source)
+	}
+}
+
+func TestPath(t *testing.T) {
+	assert.Equal(t, "/var/lib/list.txt", Path("file:///var/lib/list.txt#format=drop"))
+	assert.Equal(t, "/var/lib/list.txt", Path("/var/lib/list.txt"))
+	assert.Equal(t, "", Path("https://example.com/list.txt"))
+	assert.Equal(t, "", Path("file://example.com/list.txt"))
+}
+
+func TestExpandAndGet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.txt"), []byte("192.0.2.2\n"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("192.0.2.1\n"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".hidden"), []byte("192.0.2.3\n"), 0600))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0700))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/list.txt" && r.URL.Fragment == "" {
+			_, _ = w.Write([]byte("198.51.100.1\n"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	sources := Expand([]string{dir + "#format=drop", server.URL + "/list.txt#format=drop", filepath.Join(dir, "missing")})
+	assert.Equal(t, []string{
+		"file://" + filepath.ToSlash(filepath.Join(dir, "a.txt")) + "#format=drop",
+		"file://" + filepath.ToSlash(filepath.Join(dir, "b.txt")) + "#format=drop",
+		server.URL + "/list.txt#format=drop",
+		filepath.Join(dir, "missing"),
+	}, sources)
+
+	client := NewHTTPClient()
+	for source, want := range map[string]string{sources[0]: "192.0.2.1\n", sources[1]: "192.0.2.2\n", sources[2]: "198.51.100.1\n"} {
+		body, err := Get(client, source, nil)
+		assert.NoError(t, err, source)
+		assert.Equal(t, want, string(body), source)
+	}
+
+	for _, source := range []string{sources[3], server.URL + "/missing.txt", "ftp://example.com/list.txt"} {
+		_, err := Get(client, source, nil)
+		assert.Error(t, err, source)
+	}
+}
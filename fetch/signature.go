@@ -0,0 +1,182 @@
+package fetch
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// SignatureSuffix is appended to the signed source to get location of its detached signature, unless sig option is given.
+const SignatureSuffix = ".minisig"
+
+// ErrInvalidKey is returned for public keys, which are neither minisign nor ed25519 keys.
+var ErrInvalidKey = errors.New("invalid public key, expected minisign or base64 encoded ed25519 key")
+
+// ErrUnknownKey is returned when the source refers to a key, which is not defined in the keys given to Get.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// ErrInvalidSignature is returned when the signature is malformed or doesn't match the content.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// ErrMissingKey is returned for sources with sig option, but without key option, they would be downloaded unverified.
+var ErrMissingKey = errors.New("signature location without signing key")
+
+// ErrUnverified is returned by Get for signed sources, which cannot be verified (e.g. invalid signature, unknown key
+// or the signature cannot be downloaded).
+var ErrUnverified = errors.New("cannot verify signed source")
+
+// Keys are public keys by name. Signed sources select the key with key option in the fragment,
+// e.g. https://example.com/list.txt#key=vendor.
+type Keys map[string]*PublicKey
+
+// PublicKey verifies detached minisign or raw ed25519 signatures.
+type PublicKey struct {
+	// id is known only for minisign keys
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// ParsePublicKey parses minisign public key (the second line of minisign.pub) or base64 encoded raw ed25519 key.
+func ParsePublicKey(s string) (*PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	switch {
+	case err != nil:
+	case len(raw) == 10+ed25519.PublicKeySize && string(raw[:2]) == "Ed":
+		return &PublicKey{id: raw[2:10], key: raw[10:]}, nil
+	case len(raw) == ed25519.PublicKeySize:
+		return &PublicKey{key: raw}, nil
+	}
+	return nil, ErrInvalidKey
+}
+
+// Verify returns nil when the signature of data is valid. Minisign signatures (legacy and prehashed) are recognized
+// by their untrusted comment, other signatures are raw or base64 encoded ed25519 signatures.
+func (k *PublicKey) Verify(data, signature []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+	if strings.HasPrefix(lines[0], "untrusted comment:") {
+		return k.verifyMinisign(data, lines)
+	}
+
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil {
+			return fmt.Errorf("%w: expected minisign, raw or base64 encoded ed25519 signature", ErrInvalidSignature)
+		}
+		signature = decoded
+	}
+	if !ed25519.Verify(k.key, data, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// verifyMinisign verifies signature of data and the global signature of the trusted comment.
+func (k *PublicKey) verifyMinisign(data []byte, lines []string) error {
+	const trustedComment = "trusted comment: "
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], trustedComment) {
+		return fmt.Errorf("%w: malformed minisign signature", ErrInvalidSignature)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 10+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed minisign signature", ErrInvalidSignature)
+	}
+
+	message := data
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		hash := blake2b.Sum512(data)
+		message = hash[:]
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, sig[:2])
+	}
+	if k.id != nil && !bytes.Equal(k.id, sig[2:10]) {
+		return fmt.Errorf("%w: signed by other key %X", ErrInvalidSignature, sig[2:10])
+	}
+	if !ed25519.Verify(k.key, message, sig[10:]) {
+		return ErrInvalidSignature
+	}
+
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil {
+		return fmt.Errorf("%w: malformed global signature", ErrInvalidSignature)
+	}
+	comment := append(append([]byte{}, sig[10:]...), strings.TrimPrefix(lines[2], trustedComment)...)
+	if !ed25519.Verify(k.key, comment, global) {
+		return fmt.Errorf("%w: trusted comment was modified", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// Signed returns name of the key (key option) and location of the signature (sig option or the source
+// with SignatureSuffix) of the source, empty name is returned for sources, which are not signed.
+// ErrMissingKey is returned, when the signature location is given without the key.
+func Signed(source string) (string, string, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %s, error: %s", ErrInvalidSource, source, err)
+	}
+	options, err := url.ParseQuery(u.Fragment)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid options: %s, error: %w", u.Fragment, err)
+	}
+
+	name, signature := options.Get("key"), options.Get("sig")
+	if name == "" && signature != "" {
+		return "", "", fmt.Errorf("%w: %s, key option is required", ErrMissingKey, source)
+	}
+	if name == "" || signature != "" {
+		return name, signature, nil
+	}
+
+	if path := Path(source); path != "" {
+		return name, path + SignatureSuffix, nil
+	}
+	u.Fragment = ""
+	u.Path += SignatureSuffix
+	return name, u.String(), nil
+}
+
+// getSigned returns content of the source verified with its detached signature.
+// ErrUnverified is returned, when the source or signature cannot be downloaded or verification fails,
+// so the caller keeps its current content instead of using the unverified one.
+func getSigned(client *http.Client, source, name, signature string, keys Keys) ([]byte, error) {
+	body, err := verify(client, source, name, signature, keys)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s, error: %s", ErrUnverified, source, err)
+	}
+	return body, nil
+}
+
+func verify(client *http.Client, source, name, signature string, keys Keys) ([]byte, error) {
+	key, ok := keys[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, name)
+	}
+
+	body, err := get(client, source)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := get(client, signature)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get signature: %s, error: %w", signature, err)
+	}
+
+	if err := key.Verify(body, sig); err != nil {
+		return nil, fmt.Errorf("signature: %s, error: %w", signature, err)
+	}
+	return body, nil
+}
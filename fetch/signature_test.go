@@ -0,0 +1,170 @@
+package fetch
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+var keyID = []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+// minisign returns public key and signature of data in minisign format, algorithm is Ed (legacy) or ED (prehashed).
+func minisign(t *testing.T, data []byte, algorithm string) (string, []byte) {
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	message := data
+	if algorithm == "ED" {
+		hash := blake2b.Sum512(data)
+		message = hash[:]
+	}
+	sig := append(append([]byte(algorithm), keyID...), ed25519.Sign(private, message)...)
+	comment := "timestamp:1600000000\tfile:list.txt"
+	global := ed25519.Sign(private, append(append([]byte{}, sig[2+len(keyID):]...), comment...))
+
+	key := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), public...))
+	signature := "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(sig) + "\n" +
+		"trusted comment: " + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n"
+	return key, []byte(signature)
+}
+
+func TestParsePublicKey(t *testing.T) {
+	data := []byte("192.0.2.1\n")
+	key, _ := minisign(t, data, "Ed")
+	k, err := ParsePublicKey(key)
+	assert.NoError(t, err)
+	assert.Equal(t, keyID, k.id)
+
+	public, _, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	k, err = ParsePublicKey(base64.StdEncoding.EncodeToString(public))
+	assert.NoError(t, err)
+	assert.Nil(t, k.id)
+
+	for _, key := range []string{"", "not base64", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		_, err := ParsePublicKey(key)
+		assert.Equal(t, ErrInvalidKey, err, key)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	data := []byte("192.0.2.1\n")
+
+	for _, algorithm := range []string{"Ed", "ED"} {
+		key, signature := minisign(t, data, algorithm)
+		k, err := ParsePublicKey(key)
+		assert.NoError(t, err)
+		assert.NoError(t, k.Verify(data, signature), algorithm)
+		assert.NoError(t, k.Verify(data, []byte(strings.Replace(string(signature), "\n", "\r\n", -1))), algorithm)
+
+		assert.True(t, errors.Is(k.Verify([]byte("0.0.0.0/0\n"), signature), ErrInvalidSignature), algorithm)
+		modified := strings.Replace(string(signature), "file:list.txt", "file:other.txt", 1)
+		assert.True(t, errors.Is(k.Verify(data, []byte(modified)), ErrInvalidSignature), algorithm)
+
+		other, _ := minisign(t, data, algorithm)
+		k, err = ParsePublicKey(other)
+		assert.NoError(t, err)
+		assert.True(t, errors.Is(k.Verify(data, signature), ErrInvalidSignature), algorithm)
+	}
+
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	k := &PublicKey{key: public}
+	raw := ed25519.Sign(private, data)
+	assert.NoError(t, k.Verify(data, raw))
+	assert.NoError(t, k.Verify(data, []byte(base64.StdEncoding.EncodeToString(raw)+"\n")))
+	assert.True(t, errors.Is(k.Verify(data, []byte("untrusted comment: truncated\n")), ErrInvalidSignature))
+	assert.True(t, errors.Is(k.Verify(data, []byte("not a signature")), ErrInvalidSignature))
+}
+
+func TestSigned(t *testing.T) {
+	for source, want := range map[string][2]string{
+		"https://example.com/list.txt":                                             {"", ""},
+		"https://example.com/list.txt#format=drop&key=vendor":                      {"vendor", "https://example.com/list.txt.minisig"},
+		"https://example.com/list?id=1#key=vendor":                                 {"vendor", "https://example.com/list.minisig?id=1"},
+		"https://example.com/list.txt#key=vendor&sig=https://example.com/list.sig": {"vendor", "https://example.com/list.sig"},
+		"/var/lib/list.txt#key=vendor":                                             {"vendor", "/var/lib/list.txt.minisig"},
+	} {
+		name, signature, err := Signed(source)
+		assert.NoError(t, err, source)
+		assert.Equal(t, want, [2]string{name, signature}, source)
+	}
+
+	_, _, err := Signed("https://example.com/list.txt#sig=https://example.com/list.sig")
+	assert.True(t, errors.Is(err, ErrMissingKey))
+	assert.True(t, errors.Is(Validate("https://example.com/list.txt#sig=https://example.com/list.sig"), ErrMissingKey))
+	_, err = Get(http.DefaultClient, "https://example.com/list.txt#sig=https://example.com/list.sig", nil)
+	assert.True(t, errors.Is(err, ErrMissingKey))
+
+	assert.Error(t, Validate("https://example.com/list.txt#key=vendor&sig=list.sig"))
+	assert.NoError(t, Validate("https://example.com/list.txt#key=vendor&sig=/var/lib/list.sig"))
+}
+
+func TestGetSigned(t *testing.T) {
+	data := []byte("192.0.2.1\n")
+	key, signature := minisign(t, data, "ED")
+	k, err := ParsePublicKey(key)
+	assert.NoError(t, err)
+	keys := Keys{"test": k}
+
+	content := map[string][]byte{"/list.txt": data, "/list.txt.minisig": signature, "/unsigned.txt": data}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := content[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+	client := NewHTTPClient()
+
+	body, err := Get(client, server.URL+"/list.txt#key=test", keys)
+	assert.NoError(t, err)
+	assert.Equal(t, data, body)
+
+	// tampered list
+	content["/list.txt"] = []byte("0.0.0.0/0\n")
+	_, err = Get(client, server.URL+"/list.txt#key=test", keys)
+	assert.True(t, errors.Is(err, ErrUnverified))
+	content["/list.txt"] = data
+
+	// missing signature and unknown key
+	_, err = Get(client, server.URL+"/unsigned.txt#key=test", keys)
+	assert.True(t, errors.Is(err, ErrUnverified))
+	_, err = Get(client, server.URL+"/list.txt#key=unknown", keys)
+	assert.True(t, errors.Is(err, ErrUnverified))
+	assert.Contains(t, err.Error(), ErrUnknownKey.Error())
+
+	// local files and signatures in directories
+	dir, err := ioutil.TempDir("", "signature")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "list.txt"), data, 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "list.txt.minisig"), signature, 0600))
+
+	sources := Expand([]string{dir + "#key=test"})
+	assert.Len(t, sources, 1)
+	body, err = Get(client, sources[0], keys)
+	assert.NoError(t, err)
+	assert.Equal(t, data, body)
+
+	w := NewWatcher([]string{dir + "#key=test"}, time.Second)
+	w.Update()
+	_, other := minisign(t, data, "Ed")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "list.txt.minisig"), other, 0600))
+	assert.True(t, w.Changed(), "signature replaced")
+}
@@ -0,0 +1,175 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+// fileState describes the file at the time of the last check.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+// Watcher detects changes of local sources, remote sources are ignored.
+// Files are compared by modification time and size first, content hash is computed only when they differ,
+// so touching the file without changing its content doesn't trigger a reload.
+// Added and removed files of directories and glob patterns are also detected. Signatures of local files are watched as well,
+// so the list is reloaded, when the signature is replaced after the file.
+type Watcher struct {
+	paths []string
+	lock  sync.Mutex
+	state map[string]fileState
+}
+
+// NewWatcher returns watcher of local sources, which are checked for changes every poll interval.
+// Nil is returned when all sources are remote or poll is 0, which disables change detection.
+func NewWatcher(sources []string, poll time.Duration) *Watcher {
+	if poll <= 0 {
+		return nil
+	}
+
+	var paths []string
+	for _, source := range sources {
+		if path := Path(source); path != "" {
+			paths = append(paths, path)
+		}
+		if _, signature, err := Signed(source); err == nil && signature != "" {
+			if path := Path(signature); path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	return &Watcher{paths: paths}
+}
+
+// Update remembers current state of files, it should be called before files are read.
+func (w *Watcher) Update() {
+	if w == nil {
+		return
+	}
+
+	state := map[string]fileState{}
+	for path, info := range w.files() {
+		s := fileState{modTime: info.ModTime(), size: info.Size()}
+		s.hash, _ = hash(path)
+		state[path] = s
+	}
+
+	w.lock.Lock()
+	w.state = state
+	w.lock.Unlock()
+}
+
+// Changed returns true when files were modified, added or removed since the last Update.
+func (w *Watcher) Changed() bool {
+	if w == nil {
+		return false
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.state == nil {
+		return false
+	}
+
+	files := w.files()
+	if len(files) != len(w.state) {
+		return true
+	}
+
+	for path, info := range files {
+		s, ok := w.state[path]
+		if !ok {
+			return true
+		}
+		if s.modTime.Equal(info.ModTime()) && s.size == info.Size() {
+			continue
+		}
+
+		h, err := hash(path)
+		if err != nil || h != s.hash {
+			return true
+		}
+		// content is the same, the new modification time saves hashing during the next check
+		s.modTime = info.ModTime()
+		w.state[path] = s
+	}
+	return false
+}
+
+// files returns existing files of watched paths, directories are replaced by files, which they contain,
+// and glob patterns (e.g. /lists/*.txt) by files, which match them.
+func (w *Watcher) files() map[string]os.FileInfo {
+	files := map[string]os.FileInfo{}
+	for _, path := range w.paths {
+		paths, err := directory(path)
+		if err != nil {
+			paths, err = filepath.Glob(path)
+		}
+		if err != nil {
+			paths = []string{path}
+		}
+		for _, p := range paths {
+			for _, file := range []string{p, p + SignatureSuffix} {
+				if info, err := os.Stat(file); err == nil && !info.IsDir() {
+					files[file] = info
+				}
+			}
+		}
+	}
+	return files
+}
+
+func hash(path string) ([sha256.Size]byte, error) {
+	content, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(content), nil
+}
+
+// Schedule runs f immediately and then with given interval. When changed function is provided,
+// it's checked every poll interval (0 disables it) and f is run as soon as it returns true.
+// Both functions are run in the same goroutine, so they don't need to be synchronized with each other.
+func Schedule(ctx context.Context, interval, poll time.Duration, changed func() bool, f func()) {
+	go func() {
+		var ticks <-chan time.Time
+		if changed != nil && poll > 0 {
+			ticker := time.NewTicker(poll)
+			defer ticker.Stop()
+			ticks = ticker.C
+		}
+
+		t := time.NewTimer(0) // first run - immediately
+		for {
+			select {
+			case <-t.C:
+				f()
+				t = time.NewTimer(interval) // next runs according to the schedule
+			case <-ticks:
+				if changed() {
+					log.Debugf("[fetch] change of local sources detected, reloading")
+					t.Stop()
+					f()
+					t = time.NewTimer(interval)
+				}
+			case <-ctx.Done():
+				t.Stop()
+				return
+			}
+		}
+	}()
+}
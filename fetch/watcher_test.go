@@ -0,0 +1,91 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcher(t *testing.T) {
+	assert.Nil(t, NewWatcher([]string{"https://example.com/list.txt"}, time.Second))
+	var remote *Watcher
+	remote.Update()
+	assert.False(t, remote.Changed())
+
+	dir, err := ioutil.TempDir("", "watcher")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "a.txt")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("192.0.2.1\n"), 0600))
+
+	assert.Nil(t, NewWatcher([]string{dir}, 0), "disabled")
+	w := NewWatcher([]string{"https://example.com/list.txt", dir}, time.Second)
+	assert.False(t, w.Changed(), "not updated yet")
+	w.Update()
+	assert.False(t, w.Changed())
+
+	// modification time changed, but content is the same
+	future := time.Now().Add(time.Hour)
+	assert.NoError(t, os.Chtimes(file, future, future))
+	assert.False(t, w.Changed())
+
+	// content changed
+	assert.NoError(t, ioutil.WriteFile(file, []byte("192.0.2.2\n"), 0600))
+	assert.True(t, w.Changed())
+	w.Update()
+	assert.False(t, w.Changed())
+
+	// file added
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.txt"), []byte("192.0.2.3\n"), 0600))
+	assert.True(t, w.Changed())
+	w.Update()
+
+	// file removed
+	assert.NoError(t, os.Remove(file))
+	assert.True(t, w.Changed())
+
+	// only files matching the pattern are watched
+	w = NewWatcher([]string{filepath.Join(dir, "*.txt")}, time.Second)
+	w.Update()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("lists\n"), 0600))
+	assert.False(t, w.Changed())
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c.txt"), []byte("192.0.2.4\n"), 0600))
+	assert.True(t, w.Changed())
+}
+
+func TestSchedule(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan bool, 1)
+	runs := make(chan struct{}, 10)
+	Schedule(ctx, time.Hour, 10*time.Millisecond, func() bool {
+		select {
+		case c := <-changed:
+			return c
+		default:
+			return false
+		}
+	}, func() {
+		runs <- struct{}{}
+	})
+
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("first run is expected immediately")
+	}
+
+	changed <- true
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("run is expected after the change")
+	}
+}
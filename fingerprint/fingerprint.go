@@ -0,0 +1,217 @@
+// Package fingerprint recognizes clients by TLS (JA3, JA4) and HTTP/2 (Akamai format) fingerprints
+// computed by edge proxies and checks, if they match the client family claimed in User-Agent.
+package fingerprint
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/browser"
+	"github.com/optimatiq/threatbite/fetch"
+)
+
+// Kinds of fingerprints.
+const (
+	KindJA3   = "ja3"
+	KindJA4   = "ja4"
+	KindHTTP2 = "http2"
+)
+
+// Classes of clients.
+const (
+	ClassBrowser = "browser"
+	ClassTool    = "tool"
+)
+
+// Client families.
+const (
+	FamilyChrome         = "chrome"
+	FamilyFirefox        = "firefox"
+	FamilySafari         = "safari"
+	FamilyHeadlessChrome = "headless-chrome"
+	FamilyCurl           = "curl"
+	FamilyPythonRequests = "python-requests"
+	FamilyGo             = "go"
+)
+
+// Client is a client recognized by the fingerprint.
+type Client struct {
+	Family string
+	Class  string
+}
+
+// builtin contains fingerprints of popular browsers, which are stable across versions, and of popular tools
+// with their default TLS settings. Tools change fingerprints with TLS libraries, so lists with fingerprints
+// of other builds should be configured as sources. Headless Chrome uses the network stack of Chrome,
+// so it has the same fingerprints and it's recognized by User-Agent.
+// Format of the list: kind fingerprint family class, fields are separated by whitespace, # starts a comment.
+var builtin = []string{
+	"ja4 t13d1516h2_8daaf6152771_02713d6af862 chrome browser",
+	"http2 1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p chrome browser",
+	"http2 1:65536;4:131072;5:16384|12517377|3:0:0:201,5:0:0:101,7:0:0:1,9:0:7:1,11:0:3:1,13:0:0:241|m,p,a,s firefox browser",
+
+	// curl 7.88 with OpenSSL 3.0
+	"ja3 0149f47eabf9a20d0893e2a44e5a6323 curl tool",
+	"ja4 t13d3112h2_e8f1e7e78f70_b26ce05bbdd6 curl tool",
+	// python-requests with urllib3 2 and OpenSSL 3.0
+	"ja3 a48c0d5f95b1ef98f560f324fd275da1 python-requests tool",
+	"ja4 t13d1812h1_85036bcba153_b26ce05bbdd6 python-requests tool",
+	// Go net/http with default crypto/tls settings
+	"ja3 95b6f6d62c2c0f5258859e829e0055f5 go tool",
+	"ja4 t13d1312h2_f57a46bbacb6_a089bac06eae go tool",
+}
+
+// Database contains known fingerprints, built-in fingerprints are extended by the lists from sources.
+type Database struct {
+	sources []string
+	client  *http.Client
+	keys    fetch.Keys
+	poll    time.Duration
+	watcher *fetch.Watcher
+	clients atomic.Value // map[string]Client, key is kind:fingerprint
+}
+
+// NewDatabase returns database with built-in fingerprints, sources are loaded by Load.
+// Sources can be URLs, local files or directories (file:// URLs or paths), signed sources are verified with keys.
+// Local files are checked for changes every poll interval (0 disables it).
+func NewDatabase(sources []string, keys fetch.Keys, poll time.Duration) *Database {
+	d := &Database{
+		sources: sources,
+		client:  fetch.NewHTTPClient(),
+		keys:    keys,
+		poll:    poll,
+		watcher: fetch.NewWatcher(sources, poll),
+	}
+
+	clients := map[string]Client{}
+	parseLines(builtin, clients)
+	d.clients.Store(clients)
+	return d
+}
+
+// Load downloads lists from sources and replaces the database atomically, built-in fingerprints are always kept.
+// The current database is kept, when a signed source cannot be verified.
+func (d *Database) Load() error {
+	if len(d.sources) == 0 {
+		return nil
+	}
+
+	log.Debugf("[fingerprint] loading start")
+	d.watcher.Update()
+
+	clients := map[string]Client{}
+	parseLines(builtin, clients)
+	for _, url := range fetch.Expand(d.sources) {
+		lines, err := d.download(url)
+		if errors.Is(err, fetch.ErrUnverified) {
+			return fmt.Errorf("fingerprints: %w, current fingerprints are kept", err)
+		}
+		if err != nil {
+			log.Errorf("[fingerprint] cannot download fingerprints from: %s, error: %s", url, err)
+			continue
+		}
+		parseLines(lines, clients)
+	}
+
+	d.clients.Store(clients)
+	log.Debugf("[fingerprint] loading stop; stats fingerprints: %d", len(clients))
+	return nil
+}
+
+// RunUpdates schedules and runs updates of the database, changes of local sources are loaded immediately.
+func (d *Database) RunUpdates() {
+	fetch.Schedule(context.Background(), 12*time.Hour, d.poll, d.watcher.Changed, func() {
+		if err := d.Load(); err != nil {
+			log.Error(err)
+		}
+	})
+}
+
+// Lookup returns client recognized by the fingerprint of given kind.
+func (d *Database) Lookup(kind, fingerprint string) (Client, bool) {
+	if d == nil || fingerprint == "" {
+		return Client{}, false
+	}
+	clients := d.clients.Load().(map[string]Client)
+	client, ok := clients[kind+":"+strings.TrimSpace(fingerprint)]
+	return client, ok
+}
+
+// Family returns client family claimed by User-Agent, empty string is returned for unknown clients.
+// Chromium based browsers share TLS and HTTP/2 implementation, so they belong to chrome family.
+func Family(ua *browser.UserAgent) string {
+	switch {
+	case strings.Contains(ua.Lowercase, "headlesschrome"):
+		return FamilyHeadlessChrome
+	case strings.HasPrefix(ua.Lowercase, "curl/"):
+		return FamilyCurl
+	case strings.HasPrefix(ua.Lowercase, "python-requests/"):
+		return FamilyPythonRequests
+	case strings.HasPrefix(ua.Lowercase, "go-http-client/"):
+		return FamilyGo
+	}
+
+	switch ua.Browser.Name {
+	case browser.BrowserChrome, browser.BrowserOpera, "Samsung", "Yandex", "CocCoc":
+		return FamilyChrome
+	case browser.BrowserFirefox:
+		return FamilyFirefox
+	case browser.BrowserSafari:
+		return FamilySafari
+	}
+	return ""
+}
+
+// Matches returns true when fingerprint family is consistent with the family claimed by User-Agent.
+// Headless Chrome uses the same fingerprints as Chrome, so both families are consistent.
+func Matches(fingerprintFamily, claimedFamily string) bool {
+	if fingerprintFamily == claimedFamily {
+		return true
+	}
+	chromes := map[string]bool{FamilyChrome: true, FamilyHeadlessChrome: true}
+	return chromes[fingerprintFamily] && chromes[claimedFamily]
+}
+
+func parseLines(lines []string, clients map[string]Client) {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			log.Debugf("[fingerprint] invalid line: %s", line)
+			continue
+		}
+		switch fields[0] {
+		case KindJA3, KindJA4, KindHTTP2:
+		default:
+			log.Debugf("[fingerprint] invalid kind: %s", line)
+			continue
+		}
+		clients[fields[0]+":"+fields[1]] = Client{Family: fields[2], Class: fields[3]}
+	}
+}
+
+func (d *Database) download(url string) ([]string, error) {
+	body, err := fetch.Get(d.client, url, d.keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
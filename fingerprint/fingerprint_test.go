@@ -0,0 +1,86 @@
+package fingerprint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/optimatiq/threatbite/browser"
+	"github.com/stretchr/testify/assert"
+)
+
+const chromeHTTP2 = "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p"
+
+func TestDatabase(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("# tools\nja3 0a1b2c3d4e5f curl tool\nja4 t13d1011h1_0a1b2c3d4e5f_0a1b2c3d4e5f python-requests tool\ninvalid line\nmd5 abc curl tool\n"))
+	}))
+	defer server.Close()
+
+	d := NewDatabase([]string{server.URL, server.URL + "/missing"}, nil, time.Second)
+
+	client, ok := d.Lookup(KindHTTP2, chromeHTTP2)
+	assert.True(t, ok)
+	assert.Equal(t, Client{Family: FamilyChrome, Class: ClassBrowser}, client)
+
+	_, ok = d.Lookup(KindJA3, "0a1b2c3d4e5f")
+	assert.False(t, ok)
+
+	assert.NoError(t, d.Load())
+
+	client, ok = d.Lookup(KindJA3, "0a1b2c3d4e5f")
+	assert.True(t, ok)
+	assert.Equal(t, Client{Family: FamilyCurl, Class: ClassTool}, client)
+
+	client, ok = d.Lookup(KindJA4, "t13d1011h1_0a1b2c3d4e5f_0a1b2c3d4e5f")
+	assert.True(t, ok)
+	assert.Equal(t, FamilyPythonRequests, client.Family)
+
+	_, ok = d.Lookup(KindHTTP2, chromeHTTP2)
+	assert.True(t, ok, "built-in fingerprints are kept")
+
+	_, ok = d.Lookup(KindJA3, "abc")
+	assert.False(t, ok)
+	_, ok = d.Lookup(KindJA3, "")
+	assert.False(t, ok)
+}
+
+func TestBuiltinTools(t *testing.T) {
+	d := NewDatabase(nil, nil, 0)
+	for _, f := range []struct{ kind, value, family string }{
+		{KindJA3, "0149f47eabf9a20d0893e2a44e5a6323", FamilyCurl},
+		{KindJA4, "t13d3112h2_e8f1e7e78f70_b26ce05bbdd6", FamilyCurl},
+		{KindJA4, "t13d1812h1_85036bcba153_b26ce05bbdd6", FamilyPythonRequests},
+		{KindJA3, "95b6f6d62c2c0f5258859e829e0055f5", FamilyGo},
+		{KindJA4, "t13d1312h2_f57a46bbacb6_a089bac06eae", FamilyGo},
+	} {
+		client, ok := d.Lookup(f.kind, f.value)
+		assert.True(t, ok, f.value)
+		assert.Equal(t, Client{Family: f.family, Class: ClassTool}, client, f.value)
+	}
+}
+
+func TestFamily(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Safari/537.36":       FamilyChrome,
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/112.0.5615.49 Safari/537.36":     FamilyHeadlessChrome,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/112.0":                                      FamilyFirefox,
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.4 Safari/605.1.15": FamilySafari,
+		"curl/7.88.1":            FamilyCurl,
+		"python-requests/2.28.2": FamilyPythonRequests,
+		"Go-http-client/2.0":     FamilyGo,
+		"SomethingUnknown/1.0":   "",
+	}
+
+	for agent, want := range tests {
+		assert.Equal(t, want, Family(browser.GetUserAgent(agent)), agent)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	assert.True(t, Matches(FamilyChrome, FamilyChrome))
+	assert.True(t, Matches(FamilyChrome, FamilyHeadlessChrome))
+	assert.False(t, Matches(FamilyCurl, FamilyChrome))
+	assert.False(t, Matches(FamilyFirefox, FamilyChrome))
+}
@@ -0,0 +1,258 @@
+// Package guard rejects suspicious updates of lists, e.g. a broken feed with 0.0.0.0/0, own address ranges
+// or a feed, which shrank by 95% since the previous load. Rejected updates are quarantined, so they can be
+// inspected and released or discarded manually.
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+// ErrRejected is returned when the update violates the guard, the update is quarantined instead of applied.
+var ErrRejected = errors.New("update rejected by guard")
+
+// ErrNotQuarantined is returned when there is no quarantined update of the list.
+var ErrNotQuarantined = errors.New("no quarantined update")
+
+// Guard contains limits of the list update, zero values disable the checks.
+// A single guard protects the whole list, its limits are shared by all sources of the list.
+type Guard struct {
+	// MaxPrefix4 and MaxPrefix6 are the shortest accepted prefix lengths, e.g. 10 rejects /9, /8 and larger IPv4 networks.
+	MaxPrefix4 int
+	MaxPrefix6 int
+
+	// Protected contains ranges (e.g. own networks), which cannot overlap with any entry of the list.
+	Protected []*net.IPNet
+
+	// MinEntries is the minimal number of entries.
+	MinEntries int
+
+	// MaxChange is the maximal change of the number of entries versus the previous load in percents (1-99).
+	// Change is relative to the larger of both loads, so 90 rejects lists, which shrank more than 90% or grew more than 10 times.
+	MaxChange int
+}
+
+// CheckNetwork returns error, when the network is too large or overlaps with protected ranges. Nil guard accepts everything.
+func (g *Guard) CheckNetwork(network *net.IPNet) error {
+	if g == nil {
+		return nil
+	}
+
+	ones, bits := network.Mask.Size()
+	maxPrefix := g.MaxPrefix6
+	if bits == 8*net.IPv4len {
+		maxPrefix = g.MaxPrefix4
+	}
+	if maxPrefix > 0 && ones < maxPrefix {
+		return fmt.Errorf("%w: network %s is larger than /%d", ErrRejected, network, maxPrefix)
+	}
+
+	for _, protected := range g.Protected {
+		if protected.Contains(network.IP) || network.Contains(protected.IP) {
+			return fmt.Errorf("%w: network %s overlaps protected range %s", ErrRejected, network, protected)
+		}
+	}
+	return nil
+}
+
+// CheckCount returns error, when the update has too few entries or its size changed too much since the previous load.
+// Change is not checked for the first load (previous is 0). Nil guard accepts everything.
+func (g *Guard) CheckCount(previous, current int) error {
+	if g == nil {
+		return nil
+	}
+
+	if current < g.MinEntries {
+		return fmt.Errorf("%w: %d entries, expected at least %d", ErrRejected, current, g.MinEntries)
+	}
+
+	return g.checkChange(previous, current)
+}
+
+// CheckSources returns error, when the number of entries of any source changed too much since the previous load,
+// so a broken source is detected even when it's combined with larger sources into one list.
+// Sources, which were not loaded previously, are not checked. Nil guard accepts everything.
+func (g *Guard) CheckSources(previous, current map[string]int) error {
+	if g == nil {
+		return nil
+	}
+
+	sources := make([]string, 0, len(previous))
+	for source := range previous {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		if err := g.checkChange(previous[source], current[source]); err != nil {
+			return fmt.Errorf("%w, source: %s", err, source)
+		}
+	}
+	return nil
+}
+
+// checkChange returns error, when the number of entries changed more than MaxChange, 0 previous entries are accepted.
+func (g *Guard) checkChange(previous, current int) error {
+	if g.MaxChange <= 0 || previous <= 0 {
+		return nil
+	}
+
+	larger, diff := previous, previous-current
+	if current > previous {
+		larger, diff = current, current-previous
+	}
+	if change := diff * 100 / larger; change > g.MaxChange {
+		return fmt.Errorf("%w: number of entries changed by %d%% from %d to %d, expected at most %d%%",
+			ErrRejected, change, previous, current, g.MaxChange)
+	}
+	return nil
+}
+
+// Update is a completely loaded update of the list, which is checked before it's applied.
+type Update struct {
+	List     string
+	Entries  int
+	Previous int
+
+	// Sources and PreviousSources contain number of entries per source, they are nil for lists without sources.
+	Sources         map[string]int
+	PreviousSources map[string]int
+
+	// Rejected is the first violation found while the update was loaded, e.g. too large network or unverified source.
+	Rejected error
+
+	// Apply replaces the current list with the update.
+	Apply func()
+}
+
+// Apply applies the update, when it passes all checks, otherwise the update is quarantined and the error is returned.
+// Nil guard accepts everything.
+func (g *Guard) Apply(q *Quarantine, update Update) error {
+	rejected := update.Rejected
+	if rejected == nil {
+		rejected = g.CheckCount(update.Previous, update.Entries)
+	}
+	if rejected == nil {
+		rejected = g.CheckSources(update.PreviousSources, update.Sources)
+	}
+	if rejected != nil {
+		q.Add(Report{
+			List:     update.List,
+			Time:     time.Now(),
+			Reason:   rejected.Error(),
+			Entries:  update.Entries,
+			Previous: update.Previous,
+		}, update.Apply)
+		return rejected
+	}
+
+	update.Apply()
+	q.Accepted(update.List)
+	return nil
+}
+
+// Report describes quarantined update of the list.
+type Report struct {
+	List     string    `json:"list"`
+	Time     time.Time `json:"time"`
+	Reason   string    `json:"reason"`
+	Entries  int       `json:"entries"`
+	Previous int       `json:"previous"`
+}
+
+type quarantined struct {
+	report Report
+	apply  func()
+}
+
+// Quarantine keeps the last rejected update of each list, until it's released, discarded or a newer update is accepted.
+type Quarantine struct {
+	lock    sync.Mutex
+	updates map[string]quarantined
+}
+
+// NewQuarantine creates empty quarantine.
+func NewQuarantine() *Quarantine {
+	return &Quarantine{updates: map[string]quarantined{}}
+}
+
+// Add quarantines the update, apply function is called, when the update is released.
+// Nil quarantine only reports the update.
+func (q *Quarantine) Add(report Report, apply func()) {
+	log.Errorf("[guard] update of %s list quarantined, entries: %d, previous: %d, reason: %s",
+		report.List, report.Entries, report.Previous, report.Reason)
+	if q == nil {
+		return
+	}
+
+	q.lock.Lock()
+	q.updates[report.List] = quarantined{report: report, apply: apply}
+	q.lock.Unlock()
+}
+
+// Accepted removes quarantined update of the list, because a newer update was applied.
+func (q *Quarantine) Accepted(list string) {
+	if q == nil {
+		return
+	}
+
+	q.lock.Lock()
+	delete(q.updates, list)
+	q.lock.Unlock()
+}
+
+// Reports returns quarantined updates sorted by list name.
+func (q *Quarantine) Reports() []Report {
+	reports := []Report{}
+	if q == nil {
+		return reports
+	}
+
+	q.lock.Lock()
+	for _, update := range q.updates {
+		reports = append(reports, update.report)
+	}
+	q.lock.Unlock()
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].List < reports[j].List })
+	return reports
+}
+
+// Release applies quarantined update of the list.
+func (q *Quarantine) Release(list string) error {
+	update, err := q.remove(list)
+	if err != nil {
+		return err
+	}
+	update.apply()
+	log.Infof("[guard] quarantined update of %s list released", list)
+	return nil
+}
+
+// Discard drops quarantined update of the list, the current list is kept.
+func (q *Quarantine) Discard(list string) error {
+	_, err := q.remove(list)
+	return err
+}
+
+func (q *Quarantine) remove(list string) (quarantined, error) {
+	if q == nil {
+		return quarantined{}, ErrNotQuarantined
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	update, ok := q.updates[list]
+	if !ok {
+		return quarantined{}, ErrNotQuarantined
+	}
+	delete(q.updates, list)
+	return update, nil
+}
@@ -0,0 +1,113 @@
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func network(cidr string) *net.IPNet {
+	_, n, _ := net.ParseCIDR(cidr)
+	return n
+}
+
+func TestCheckNetwork(t *testing.T) {
+	var disabled *Guard
+	assert.NoError(t, disabled.CheckNetwork(network("0.0.0.0/0")))
+
+	g := &Guard{MaxPrefix4: 10, MaxPrefix6: 24, Protected: []*net.IPNet{network("198.51.100.0/24")}}
+	for _, cidr := range []string{"192.0.2.0/24", "10.0.0.0/10", "2001:db8::/32", "198.51.101.1/32"} {
+		assert.NoError(t, g.CheckNetwork(network(cidr)), cidr)
+	}
+	for _, cidr := range []string{"0.0.0.0/0", "10.0.0.0/8", "::/0", "2001::/16", "198.51.100.7/32", "198.51.0.0/16"} {
+		err := g.CheckNetwork(network(cidr))
+		assert.True(t, errors.Is(err, ErrRejected), cidr)
+	}
+}
+
+func TestCheckCount(t *testing.T) {
+	var disabled *Guard
+	assert.NoError(t, disabled.CheckCount(100, 0))
+
+	g := &Guard{MinEntries: 1, MaxChange: 90}
+	assert.NoError(t, g.CheckCount(0, 1), "first load")
+	assert.NoError(t, g.CheckCount(100, 10))
+	assert.NoError(t, g.CheckCount(10, 100))
+	assert.True(t, errors.Is(g.CheckCount(0, 0), ErrRejected), "empty")
+	assert.True(t, errors.Is(g.CheckCount(100, 5), ErrRejected), "shrank")
+	assert.True(t, errors.Is(g.CheckCount(5, 100), ErrRejected), "grew")
+}
+
+func TestCheckSources(t *testing.T) {
+	var disabled *Guard
+	assert.NoError(t, disabled.CheckSources(map[string]int{"a": 100}, nil))
+
+	g := &Guard{MaxChange: 50}
+	assert.NoError(t, g.CheckSources(nil, map[string]int{"a": 100}), "first load")
+	assert.NoError(t, g.CheckSources(map[string]int{"a": 1000, "b": 10}, map[string]int{"a": 1000, "b": 8, "c": 1}))
+
+	err := g.CheckSources(map[string]int{"a": 1000, "b": 10}, map[string]int{"a": 1000})
+	assert.True(t, errors.Is(err, ErrRejected), "broken source combined with larger one")
+	assert.Contains(t, err.Error(), "source: b")
+}
+
+func TestQuarantine(t *testing.T) {
+	var disabled *Quarantine
+	disabled.Add(Report{List: "proxy"}, func() {})
+	disabled.Accepted("proxy")
+	assert.Empty(t, disabled.Reports())
+	assert.Equal(t, ErrNotQuarantined, disabled.Release("proxy"))
+
+	q := NewQuarantine()
+	assert.Empty(t, q.Reports())
+
+	applied := 0
+	q.Add(Report{List: "spam", Entries: 1}, func() { applied++ })
+	q.Add(Report{List: "proxy", Entries: 2}, func() { applied++ })
+	assert.Equal(t, []Report{{List: "proxy", Entries: 2}, {List: "spam", Entries: 1}}, q.Reports())
+
+	assert.NoError(t, q.Release("proxy"))
+	assert.Equal(t, 1, applied)
+	assert.Equal(t, ErrNotQuarantined, q.Release("proxy"))
+
+	assert.NoError(t, q.Discard("spam"))
+	assert.Equal(t, 1, applied)
+	assert.Empty(t, q.Reports())
+
+	q.Add(Report{List: "vpn"}, func() { applied++ })
+	q.Accepted("vpn")
+	assert.Equal(t, ErrNotQuarantined, q.Discard("vpn"))
+}
+
+func TestApply(t *testing.T) {
+	q := NewQuarantine()
+	applied := 0
+	update := Update{List: "proxy", Entries: 1, Previous: 10, Apply: func() { applied++ }}
+
+	var disabled *Guard
+	assert.NoError(t, disabled.Apply(q, update))
+	assert.Equal(t, 1, applied)
+
+	g := &Guard{MaxChange: 50}
+	err := g.Apply(q, update)
+	assert.True(t, errors.Is(err, ErrRejected))
+	assert.Equal(t, 1, applied)
+	reports := q.Reports()
+	assert.Len(t, reports, 1)
+	assert.Equal(t, 1, reports[0].Entries)
+	assert.Equal(t, 10, reports[0].Previous)
+
+	// violation found while loading rejects the update, which passes other checks
+	update.Entries = 10
+	update.Rejected = fmt.Errorf("%w: unverified", ErrRejected)
+	assert.Equal(t, update.Rejected, g.Apply(q, update))
+	assert.Equal(t, 1, applied)
+
+	update.Rejected = nil
+	assert.NoError(t, g.Apply(q, update))
+	assert.Equal(t, 2, applied)
+	assert.Empty(t, q.Reports())
+}
@@ -0,0 +1,159 @@
+package header
+
+import (
+	"net"
+	"strings"
+)
+
+// proxyHeaders are headers added by proxies, which reveal that the request was forwarded.
+var proxyHeaders = []string{"Forwarded", "X-Forwarded-For", "Via", "X-Real-IP", "Client-IP"}
+
+// Chain contains addresses found in forwarding headers.
+type Chain struct {
+	// Headers contains names of proxy revealing headers present in the request.
+	Headers []string
+
+	// Hops contains addresses of the client and proxies in order, in which they were appended (client first).
+	Hops []net.IP
+
+	// Inconsistent is set when an address cannot be parsed or headers contain different addresses.
+	Inconsistent bool
+}
+
+// ForwardedChain parses Forwarded, X-Forwarded-For, X-Real-IP and Client-IP headers.
+// Forwarded takes precedence over X-Forwarded-For, both should contain the same addresses when they are sent.
+// Via contains only names of proxies, so it's recorded as proxy revealing header.
+// Obfuscated identifiers and "unknown" allowed by RFC 7239 are skipped.
+func ForwardedChain(h Headers) Chain {
+	var chain Chain
+	for _, name := range proxyHeaders {
+		if h.Has(name) {
+			chain.Headers = append(chain.Headers, name)
+		}
+	}
+
+	forwarded, ok := parseForwarded(h.Get("Forwarded"))
+	if !ok {
+		chain.Inconsistent = true
+	}
+	xff, ok := parseList(h.Get("X-Forwarded-For"))
+	if !ok {
+		chain.Inconsistent = true
+	}
+
+	switch {
+	case len(forwarded) > 0:
+		chain.Hops = forwarded
+		if len(xff) > 0 && !equalHops(forwarded, xff) {
+			chain.Inconsistent = true
+		}
+	default:
+		chain.Hops = xff
+	}
+
+	for _, name := range []string{"X-Real-IP", "Client-IP"} {
+		if !h.Has(name) {
+			continue
+		}
+		addr := parseHop(h.Get(name))
+		switch {
+		case addr == nil:
+			chain.Inconsistent = true
+		case len(chain.Hops) == 0:
+			chain.Hops = []net.IP{addr}
+		case !containsHop(chain.Hops, addr):
+			chain.Inconsistent = true
+		}
+	}
+
+	return chain
+}
+
+// parseForwarded returns addresses from "for" parameters of the Forwarded header (RFC 7239),
+// e.g. for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711".
+func parseForwarded(value string) ([]net.IP, bool) {
+	var hops []net.IP
+	valid := true
+	for _, element := range splitList(value) {
+		for _, pair := range strings.Split(element, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 || !strings.EqualFold(kv[0], "for") {
+				continue
+			}
+			node := strings.Trim(kv[1], `"`)
+			if isObfuscated(node) {
+				continue
+			}
+			if addr := parseHop(node); addr != nil {
+				hops = append(hops, addr)
+			} else {
+				valid = false
+			}
+		}
+	}
+	return hops, valid
+}
+
+// parseList returns addresses from comma separated list of the X-Forwarded-For header.
+func parseList(value string) ([]net.IP, bool) {
+	var hops []net.IP
+	valid := true
+	for _, node := range splitList(value) {
+		if isObfuscated(node) {
+			continue
+		}
+		if addr := parseHop(node); addr != nil {
+			hops = append(hops, addr)
+		} else {
+			valid = false
+		}
+	}
+	return hops, valid
+}
+
+func splitList(value string) []string {
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func isObfuscated(node string) bool {
+	return strings.EqualFold(node, "unknown") || strings.HasPrefix(node, "_")
+}
+
+// parseHop parses address, which may contain port (192.0.2.1:80, [2001:db8::1]:80) or brackets around IPv6.
+func parseHop(node string) net.IP {
+	node = strings.TrimSpace(node)
+	if addr := net.ParseIP(node); addr != nil {
+		return addr
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(node, "["), "]"))
+}
+
+func equalHops(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsHop(hops []net.IP, addr net.IP) bool {
+	for _, hop := range hops {
+		if hop.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
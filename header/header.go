@@ -0,0 +1,245 @@
+// Package header analyses HTTP headers of the scored request and returns signals found in them.
+package header
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/optimatiq/threatbite/scoring"
+)
+
+// Signals raised for HTTP headers.
+const (
+	SignalMissingAccept         = "missing_accept"
+	SignalMissingAcceptLanguage = "missing_accept_language"
+	SignalMissingAcceptEncoding = "missing_accept_encoding"
+	SignalInconsistentHeaders   = "inconsistent_headers"
+	SignalDuplicateHeader       = "duplicate_header"
+	SignalOversizedHeader       = "oversized_header"
+	SignalConnectionMismatch    = "connection_mismatch"
+	SignalLanguageCountry       = "language_country_mismatch"
+	SignalMissingCookie         = "missing_cookie"
+)
+
+// Weights of the header signals.
+const (
+	missingAcceptWeight         = -5
+	missingAcceptLanguageWeight = -10
+	missingAcceptEncodingWeight = -10
+	inconsistentHeadersWeight   = -20
+	duplicateHeaderWeight       = -15
+	oversizedHeaderWeight       = -15
+	connectionMismatchWeight    = -20
+	languageCountryWeight       = -5
+	missingCookieWeight         = -10
+)
+
+// Limits of header sizes, values above them are not sent by browsers.
+const (
+	maxHeaderSize  = 8 * 1024
+	maxHeadersSize = 32 * 1024
+)
+
+// singletons are headers, which cannot be repeated and their values never contain comma,
+// so comma means that repeated values were joined.
+var singletons = []string{"Host", "Content-Length"}
+
+// hopByHop are connection-specific headers, which are forbidden in HTTP/2 and HTTP/3.
+var hopByHop = []string{"Connection", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding", "Upgrade"}
+
+// Headers is a set of headers with canonical names.
+type Headers struct {
+	values     map[string]string
+	duplicates []string
+	size       int
+	oversized  bool
+}
+
+// Parse returns headers with canonical names, names, which differ only by case, are recorded as duplicates.
+func Parse(raw map[string]string) Headers {
+	h := Headers{values: make(map[string]string, len(raw))}
+	for name, value := range raw {
+		canonical := http.CanonicalHeaderKey(name)
+		if _, ok := h.values[canonical]; ok {
+			h.duplicates = append(h.duplicates, canonical)
+			h.values[canonical] += ", " + value
+		} else {
+			h.values[canonical] = value
+		}
+
+		size := len(name) + len(value)
+		h.size += size
+		if size > maxHeaderSize {
+			h.oversized = true
+		}
+	}
+	if h.size > maxHeadersSize {
+		h.oversized = true
+	}
+	return h
+}
+
+// Get returns value of the header, name is case-insensitive.
+func (h Headers) Get(name string) string {
+	return h.values[http.CanonicalHeaderKey(name)]
+}
+
+// Has returns true when header is present, name is case-insensitive.
+func (h Headers) Has(name string) bool {
+	_, ok := h.values[http.CanonicalHeaderKey(name)]
+	return ok
+}
+
+// Len returns number of headers.
+func (h Headers) Len() int {
+	return len(h.values)
+}
+
+// Names returns canonical names of all headers.
+func (h Headers) Names() []string {
+	names := make([]string, 0, len(h.values))
+	for name := range h.values {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Request contains information about the request, which is needed to analyse its headers.
+type Request struct {
+	Host     string
+	URI      string
+	Protocol string
+	Scheme   string
+	Headers  Headers
+
+	// Browser and BrowserMajor are parsed from User-Agent, Automated is set for bots and scripts,
+	// which are not expected to send browser headers.
+	Browser      string
+	BrowserMajor int
+	Automated    bool
+
+	// Country of the IP address.
+	Country string
+}
+
+// Analyze returns signals found in headers. Headers are optional, so nothing is checked when they are missing.
+func Analyze(r Request) scoring.Signals {
+	var signals scoring.Signals
+	if r.Headers.Len() == 0 {
+		return signals
+	}
+
+	if len(r.Headers.duplicates) > 0 || hasRepeatedSingleton(r.Headers) {
+		signals.Add(SignalDuplicateHeader, duplicateHeaderWeight)
+	}
+	if r.Headers.oversized {
+		signals.Add(SignalOversizedHeader, oversizedHeaderWeight)
+	}
+	if connectionMismatch(r) {
+		signals.Add(SignalConnectionMismatch, connectionMismatchWeight)
+	}
+
+	if r.Automated {
+		return signals
+	}
+
+	if !r.Headers.Has("Accept") {
+		signals.Add(SignalMissingAccept, missingAcceptWeight)
+	}
+	if !r.Headers.Has("Accept-Language") {
+		signals.Add(SignalMissingAcceptLanguage, missingAcceptLanguageWeight)
+	} else if languageCountryMismatch(r.Headers.Get("Accept-Language"), r.Country) {
+		signals.Add(SignalLanguageCountry, languageCountryWeight)
+	}
+	if !r.Headers.Has("Accept-Encoding") {
+		signals.Add(SignalMissingAcceptEncoding, missingAcceptEncodingWeight)
+	}
+	if inconsistentWithBrowser(r) {
+		signals.Add(SignalInconsistentHeaders, inconsistentHeadersWeight)
+	}
+	if missingCookie(r) {
+		signals.Add(SignalMissingCookie, missingCookieWeight)
+	}
+
+	return signals
+}
+
+func hasRepeatedSingleton(h Headers) bool {
+	for _, name := range singletons {
+		if strings.Contains(h.Get(name), ",") {
+			return true
+		}
+	}
+	return false
+}
+
+// connectionMismatch checks connection-specific headers, which are not allowed in HTTP/2 and HTTP/3,
+// and HTTP/1.0 requests, which use chunked transfer encoding introduced in HTTP/1.1.
+func connectionMismatch(r Request) bool {
+	protocol := strings.ToUpper(r.Protocol)
+	switch {
+	case strings.HasPrefix(protocol, "HTTP/2"), strings.HasPrefix(protocol, "HTTP/3"):
+		for _, name := range hopByHop {
+			if r.Headers.Has(name) {
+				return true
+			}
+		}
+	case protocol == "HTTP/1.0":
+		return strings.Contains(strings.ToLower(r.Headers.Get("Transfer-Encoding")), "chunked")
+	}
+	return false
+}
+
+// inconsistentWithBrowser checks headers, which are always (or never) sent by the claimed browser.
+// Chromium based browsers send client hints over HTTPS since version 89, Firefox and Safari never send them.
+func inconsistentWithBrowser(r Request) bool {
+	hints := r.Headers.Has("Sec-Ch-Ua")
+	switch r.Browser {
+	case "Firefox", "Safari", "IE":
+		return hints
+	case "Chrome":
+		return !hints && r.BrowserMajor >= 89 && strings.EqualFold(r.Scheme, "https")
+	}
+	return false
+}
+
+// languageCountryMismatch checks if any of the regions in Accept-Language matches IP country.
+// Languages without region (e.g. "en") are not checked.
+func languageCountryMismatch(acceptLanguage, country string) bool {
+	if country == "" || country == "-" {
+		return false
+	}
+
+	regions := 0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		subtags := strings.Split(tag, "-")
+		if len(subtags) < 2 {
+			continue
+		}
+		region := subtags[len(subtags)-1]
+		if len(region) != 2 {
+			continue
+		}
+		regions++
+		if strings.EqualFold(region, country) {
+			return false
+		}
+	}
+	return regions > 0
+}
+
+// missingCookie checks requests, which come from the same site (user navigates within the site),
+// cookies are expected in such requests, but not on landing requests.
+func missingCookie(r Request) bool {
+	if r.Headers.Has("Cookie") {
+		return false
+	}
+
+	referer, err := url.Parse(r.Headers.Get("Referer"))
+	if err != nil || referer.Hostname() == "" {
+		return false
+	}
+	return strings.EqualFold(referer.Hostname(), r.Host)
+}
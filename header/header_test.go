@@ -0,0 +1,147 @@
+package header
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var browserHeaders = map[string]string{
+	"Accept":          "text/html",
+	"Accept-Language": "pl-PL,pl;q=0.9,en-US;q=0.8",
+	"Accept-Encoding": "gzip, deflate, br",
+	"Sec-Ch-Ua":       `"Chromium";v="112"`,
+}
+
+func with(headers map[string]string, name, value string) map[string]string {
+	result := map[string]string{}
+	for k, v := range headers {
+		result[k] = v
+	}
+	if value == "" {
+		delete(result, name)
+	} else {
+		result[name] = value
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	h := Parse(map[string]string{"accept": "text/html", "X-Custom": "1"})
+	assert.Equal(t, "text/html", h.Get("Accept"))
+	assert.True(t, h.Has("ACCEPT"))
+	assert.False(t, h.Has("Cookie"))
+	assert.Equal(t, 2, h.Len())
+	assert.ElementsMatch(t, []string{"Accept", "X-Custom"}, h.Names())
+}
+
+func TestAnalyze(t *testing.T) {
+	chrome := Request{Host: "example.com", Scheme: "https", Protocol: "HTTP/2.0", Browser: "Chrome", BrowserMajor: 112, Country: "PL"}
+
+	tests := []struct {
+		name    string
+		request Request
+		headers map[string]string
+		want    []string
+	}{
+		{"no headers", chrome, nil, nil},
+		{"browser", chrome, browserHeaders, nil},
+		{"missing accept", chrome, with(browserHeaders, "Accept", ""), []string{SignalMissingAccept}},
+		{"missing language", chrome, with(browserHeaders, "Accept-Language", ""), []string{SignalMissingAcceptLanguage}},
+		{"missing encoding", chrome, with(browserHeaders, "Accept-Encoding", ""), []string{SignalMissingAcceptEncoding}},
+		{"chrome without hints", chrome, with(browserHeaders, "Sec-Ch-Ua", ""), []string{SignalInconsistentHeaders}},
+		{"firefox with hints", Request{Browser: "Firefox", BrowserMajor: 110, Country: "PL"}, browserHeaders, []string{SignalInconsistentHeaders}},
+		{"duplicate", chrome, with(browserHeaders, "accept", "*/*"), []string{SignalDuplicateHeader}},
+		{"repeated host", chrome, with(browserHeaders, "Host", "a.com, b.com"), []string{SignalDuplicateHeader}},
+		{"oversized", chrome, with(browserHeaders, "X-Data", strings.Repeat("a", maxHeaderSize+1)), []string{SignalOversizedHeader}},
+		{"connection in http2", chrome, with(browserHeaders, "Connection", "keep-alive"), []string{SignalConnectionMismatch}},
+		{"connection in http1.1", Request{Protocol: "HTTP/1.1", Country: "PL"}, with(browserHeaders, "Connection", "keep-alive"), nil},
+		{"chunked in http1.0", Request{Protocol: "HTTP/1.0", Country: "PL"}, with(browserHeaders, "Transfer-Encoding", "chunked"), []string{SignalConnectionMismatch}},
+		{"language country", Request{Country: "US"}, with(browserHeaders, "Accept-Language", "de-DE,de;q=0.9"), []string{SignalLanguageCountry}},
+		{"language without region", Request{Country: "US"}, with(browserHeaders, "Accept-Language", "de"), nil},
+		{"language unknown country", Request{Country: "-"}, browserHeaders, nil},
+		{"missing cookie", chrome, with(browserHeaders, "Referer", "https://example.com/page"), []string{SignalMissingCookie}},
+		{"cookie", chrome, with(with(browserHeaders, "Referer", "https://example.com/"), "Cookie", "a=1"), nil},
+		{"external referer", chrome, with(browserHeaders, "Referer", "https://google.com/"), nil},
+		{"automated", Request{Automated: true, Protocol: "HTTP/2"}, map[string]string{"Connection": "close"}, []string{SignalConnectionMismatch}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.request.Headers = Parse(tt.headers)
+			assert.Equal(t, tt.want, Analyze(tt.request).Names())
+		})
+	}
+}
+
+func TestForwardedChain(t *testing.T) {
+	tests := []struct {
+		name             string
+		headers          map[string]string
+		wantHeaders      []string
+		wantHops         []string
+		wantInconsistent bool
+	}{
+		{"no headers", nil, nil, nil, false},
+		{"via", map[string]string{"Via": "1.1 varnish"}, []string{"Via"}, nil, false},
+		{
+			name:        "x-forwarded-for",
+			headers:     map[string]string{"X-Forwarded-For": "203.0.113.1, 10.0.0.1:8080"},
+			wantHeaders: []string{"X-Forwarded-For"},
+			wantHops:    []string{"203.0.113.1", "10.0.0.1"},
+		},
+		{
+			name:        "forwarded",
+			headers:     map[string]string{"Forwarded": `for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711", for=unknown, for=_hidden`},
+			wantHeaders: []string{"Forwarded"},
+			wantHops:    []string{"192.0.2.60", "2001:db8:cafe::17"},
+		},
+		{
+			name:        "forwarded and x-forwarded-for",
+			headers:     map[string]string{"Forwarded": "for=192.0.2.60", "X-Forwarded-For": "192.0.2.60", "X-Real-IP": "192.0.2.60"},
+			wantHeaders: []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"},
+			wantHops:    []string{"192.0.2.60"},
+		},
+		{
+			name:             "forwarded differs",
+			headers:          map[string]string{"Forwarded": "for=192.0.2.60", "X-Forwarded-For": "192.0.2.61"},
+			wantHeaders:      []string{"Forwarded", "X-Forwarded-For"},
+			wantHops:         []string{"192.0.2.60"},
+			wantInconsistent: true,
+		},
+		{
+			name:        "real ip only",
+			headers:     map[string]string{"x-real-ip": "192.0.2.60"},
+			wantHeaders: []string{"X-Real-IP"},
+			wantHops:    []string{"192.0.2.60"},
+		},
+		{
+			name:             "client ip outside chain",
+			headers:          map[string]string{"X-Forwarded-For": "192.0.2.60", "Client-IP": "192.0.2.61"},
+			wantHeaders:      []string{"X-Forwarded-For", "Client-IP"},
+			wantHops:         []string{"192.0.2.60"},
+			wantInconsistent: true,
+		},
+		{
+			name:             "invalid address",
+			headers:          map[string]string{"X-Forwarded-For": "192.0.2.60, <script>"},
+			wantHeaders:      []string{"X-Forwarded-For"},
+			wantHops:         []string{"192.0.2.60"},
+			wantInconsistent: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := ForwardedChain(Parse(tt.headers))
+			assert.Equal(t, tt.wantHeaders, chain.Headers)
+			assert.Equal(t, tt.wantInconsistent, chain.Inconsistent)
+
+			var hops []string
+			for _, hop := range chain.Hops {
+				hops = append(hops, hop.String())
+			}
+			assert.Equal(t, tt.wantHops, hops)
+		})
+	}
+}
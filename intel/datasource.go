@@ -0,0 +1,110 @@
+package intel
+
+import (
+	"net"
+	"strings"
+
+	emailDatasource "github.com/optimatiq/threatbite/email/datasource"
+	ipDatasource "github.com/optimatiq/threatbite/ip/datasource"
+)
+
+// IPDataSource returns IPv4 and IPv6 indicators of the feed as IP list entries with metadata.
+// Active indicators are taken on every Reset, so expired indicators disappear when the list is reloaded.
+type IPDataSource struct {
+	feed       *Feed
+	indicators []Indicator
+	i          int
+	meta       *ipDatasource.Meta
+}
+
+// NewIPDataSource creates IP data source for the feed.
+func NewIPDataSource(feed *Feed) *IPDataSource {
+	return &IPDataSource{feed: feed}
+}
+
+// Reset takes current active indicators of the feed.
+func (s *IPDataSource) Reset() error {
+	s.indicators = s.feed.Indicators(TypeIPv4, TypeIPv6)
+	s.i = 0
+	s.meta = nil
+	return nil
+}
+
+// Next returns IP/CIDR of the next indicator.
+// ErrNoData is returned when there is no data, this error indicates that we reached the end.
+func (s *IPDataSource) Next() (*net.IPNet, error) {
+	if s.i >= len(s.indicators) {
+		return nil, ipDatasource.ErrNoData
+	}
+	indicator := s.indicators[s.i]
+	s.i++
+
+	network := parseNetwork(indicator.Value)
+	if network == nil {
+		return nil, ipDatasource.ErrInvalidData
+	}
+
+	s.meta = &ipDatasource.Meta{
+		Category:   strings.Join(indicator.Labels, ","),
+		Confidence: indicator.Confidence,
+		FirstSeen:  indicator.ValidFrom,
+		LastSeen:   indicator.Modified,
+		Reference:  indicator.Reference,
+	}
+	return network, nil
+}
+
+// Meta returns labels, confidence, validity start, modification time and reference of the last indicator.
+func (s *IPDataSource) Meta() *ipDatasource.Meta {
+	return s.meta
+}
+
+// parseNetwork returns network of IP address or CIDR, nil is returned for invalid values.
+func parseNetwork(value string) *net.IPNet {
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return network
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil
+	}
+	if to4 := ip.To4(); to4 != nil {
+		return &net.IPNet{IP: to4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// EmailDataSource returns domain and email indicators of the feed as email list entries.
+// Active indicators are taken on every Reset, so expired indicators disappear when the list is reloaded.
+type EmailDataSource struct {
+	feed       *Feed
+	indicators []Indicator
+	i          int
+}
+
+// NewEmailDataSource creates email data source for the feed.
+func NewEmailDataSource(feed *Feed) *EmailDataSource {
+	return &EmailDataSource{feed: feed}
+}
+
+// Reset takes current active indicators of the feed.
+func (s *EmailDataSource) Reset() error {
+	s.indicators = s.feed.Indicators(TypeDomain, TypeEmail)
+	s.i = 0
+	return nil
+}
+
+// Next returns domain or email address of the next indicator.
+// ErrNoData is returned when there is no data, this error indicates that we reached the end.
+func (s *EmailDataSource) Next() (string, error) {
+	if s.i >= len(s.indicators) {
+		return "", emailDatasource.ErrNoData
+	}
+	indicator := s.indicators[s.i]
+	s.i++
+
+	if indicator.Value == "" {
+		return "", emailDatasource.ErrInvalidData
+	}
+	return indicator.Value, nil
+}
@@ -0,0 +1,123 @@
+// Package intel ingests indicators published by threat intelligence platforms (TAXII 2.1 collections, MISP instances).
+// IPv4, IPv6, domain and email indicators are exposed as data sources of IP and email lists,
+// expired and revoked indicators are removed when lists are reloaded.
+package intel
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+// Types of indicators.
+const (
+	TypeIPv4   = "ipv4"
+	TypeIPv6   = "ipv6"
+	TypeDomain = "domain"
+	TypeEmail  = "email"
+)
+
+// Indicator is a single observable value with its labels and validity window.
+// Zero ValidFrom or ValidUntil means that the window is open on that side.
+type Indicator struct {
+	Type       string
+	Value      string
+	Labels     []string
+	Confidence uint8
+	ValidFrom  time.Time
+	ValidUntil time.Time
+	Modified   time.Time
+	Reference  string
+}
+
+// Active returns true when the indicator is valid at given time.
+func (i Indicator) Active(now time.Time) bool {
+	if !i.ValidFrom.IsZero() && now.Before(i.ValidFrom) {
+		return false
+	}
+	return i.ValidUntil.IsZero() || now.Before(i.ValidUntil)
+}
+
+// Source is a platform, from which indicators are fetched.
+type Source interface {
+	// Name identifies the source in logs.
+	Name() string
+	// Fetch returns all current indicators.
+	Fetch() ([]Indicator, error)
+}
+
+// Feed keeps indicators fetched from sources. Indicators are fetched again, when they are older than maxAge.
+// Indicators of the source, which cannot be fetched, are kept until the next successful fetch.
+type Feed struct {
+	sources []Source
+	maxAge  time.Duration
+
+	mu         sync.Mutex
+	fetched    time.Time
+	indicators map[string][]Indicator
+	now        func() time.Time
+}
+
+// NewFeed creates feed, which fetches indicators from sources at most once per maxAge.
+func NewFeed(maxAge time.Duration, sources ...Source) *Feed {
+	return &Feed{
+		sources:    sources,
+		maxAge:     maxAge,
+		indicators: map[string][]Indicator{},
+		now:        time.Now,
+	}
+}
+
+// Indicators returns active indicators of given types, they are fetched first when they are outdated.
+func (f *Feed) Indicators(types ...string) []Indicator {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	if f.fetched.IsZero() || now.Sub(f.fetched) >= f.maxAge {
+		f.fetch()
+		f.fetched = now
+	}
+
+	var indicators []Indicator
+	for _, source := range f.sources {
+		for _, indicator := range f.indicators[source.Name()] {
+			if indicator.Active(now) && hasType(types, indicator.Type) {
+				indicators = append(indicators, indicator)
+			}
+		}
+	}
+	return indicators
+}
+
+func (f *Feed) fetch() {
+	for _, source := range f.sources {
+		indicators, err := source.Fetch()
+		if err != nil {
+			log.Errorf("[intel] cannot fetch indicators from: %s, error: %s", source.Name(), err)
+			continue
+		}
+		f.indicators[source.Name()] = indicators
+		log.Debugf("[intel] source: %s indicators: %d", source.Name(), len(indicators))
+	}
+}
+
+func hasType(types []string, t string) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize returns lowercase value of domain and email indicators, IP addresses are returned unchanged.
+func normalize(typ, value string) string {
+	value = strings.TrimSpace(value)
+	if typ == TypeDomain || typ == TypeEmail {
+		return strings.TrimSuffix(strings.ToLower(value), ".")
+	}
+	return value
+}
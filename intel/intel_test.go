@@ -0,0 +1,229 @@
+package intel
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	emailDatasource "github.com/optimatiq/threatbite/email/datasource"
+	ipDatasource "github.com/optimatiq/threatbite/ip/datasource"
+)
+
+var taxiiPages = map[string]string{
+	"": `{"more": true, "next": "page2", "objects": [
+		{"type": "indicator", "id": "indicator--1", "pattern": "[ipv4-addr:value = '192.0.2.1']", "pattern_type": "stix",
+		 "labels": ["c2", "emotet"], "confidence": 80, "valid_from": "2020-04-01T00:00:00Z", "valid_until": "2030-01-01T00:00:00Z",
+		 "modified": "2020-04-10T12:00:00.000Z"},
+		{"type": "indicator", "id": "indicator--2", "pattern": "[ipv6-addr:value = '2001:db8::/32'] OR [domain-name:value = 'Evil.Example.']",
+		 "pattern_type": "stix", "valid_from": "2020-04-01T00:00:00Z"},
+		{"type": "indicator", "id": "indicator--3", "pattern": "[ipv4-addr:value = '198.51.100.1']", "pattern_type": "stix",
+		 "valid_from": "2020-04-01T00:00:00Z", "valid_until": "2020-05-01T00:00:00Z"}
+	]}`,
+	"page2": `{"objects": [
+		{"type": "indicator", "id": "indicator--4", "pattern": "[email-addr:value = 'ceo@phish.example']", "pattern_type": "stix",
+		 "valid_from": "2020-04-01T00:00:00Z"},
+		{"type": "indicator", "id": "indicator--5", "pattern": "[ipv4-addr:value = '203.0.113.1']", "revoked": true},
+		{"type": "indicator", "id": "indicator--6", "pattern": "[ipv4-addr:value = '203.0.113.2' AND network-traffic:dst_port = 443]"},
+		{"type": "indicator", "id": "indicator--7", "pattern": "alert tcp any any -> 203.0.113.3 any", "pattern_type": "snort"},
+		{"type": "indicator", "id": "indicator--8", "pattern": "[ipv4-addr:value NOT = '203.0.113.4']"},
+		{"type": "indicator", "id": "indicator--9", "pattern": "[ipv4-addr:value ISSUBSET '203.0.113.0/24'] OR [domain-name:value ISSUBSET 'example.com']",
+		 "valid_from": "2020-04-01T00:00:00Z"},
+		{"type": "indicator", "id": "indicator--10", "pattern": "[email-addr:value = 'not@mail.example' AND domain-name:value = 'x.example']"},
+		{"type": "malware", "id": "malware--1", "name": "emotet"}
+	]}`,
+}
+
+const mispResult = `{"response": {"Attribute": [
+	{"id": "1", "event_id": "10", "type": "ip-dst", "value": "192.0.2.10", "timestamp": "1585699200", "Tag": [{"name": "tlp:white"}]},
+	{"id": "2", "event_id": "10", "type": "ip-src", "value": "2001:db8::10", "timestamp": "1585699200", "last_seen": "2020-04-20T00:00:00Z"},
+	{"id": "3", "event_id": "11", "type": "hostname", "value": "Bad.Example", "timestamp": "1585699200"},
+	{"id": "4", "event_id": "11", "type": "ip-dst", "value": "not an ip", "timestamp": "1585699200"},
+	{"id": "5", "event_id": "11", "type": "url", "value": "https://bad.example/", "timestamp": "1585699200"}
+]}}`
+
+type IntelSuite struct {
+	suite.Suite
+	server     *httptest.Server
+	taxiiCalls int
+	mispCalls  int
+	available  bool
+}
+
+func (suite *IntelSuite) SetupTest() {
+	suite.taxiiCalls = 0
+	suite.mispCalls = 0
+	suite.available = true
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !suite.available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/collections/indicators/objects/":
+			suite.taxiiCalls++
+			user, password, _ := r.BasicAuth()
+			if user != "soc" || password != "secret" || r.Header.Get("Accept") != taxiiMediaType {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			suite.Equal("indicator", r.URL.Query().Get("match[type]"))
+			w.Header().Set("Content-Type", taxiiMediaType)
+			_, _ = w.Write([]byte(taxiiPages[r.URL.Query().Get("next")]))
+		case "/attributes/restSearch":
+			suite.mispCalls++
+			var search mispSearch
+			suite.NoError(json.NewDecoder(r.Body).Decode(&search))
+			if r.Method != http.MethodPost || r.Header.Get("Authorization") != "key" || !search.ToIDs {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(mispResult))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func (suite *IntelSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *IntelSuite) taxii() *TAXII {
+	return NewTAXII(suite.server.URL+"/api/collections/indicators", "soc", "secret")
+}
+
+func (suite *IntelSuite) misp(ttl time.Duration) *MISP {
+	return NewMISP(suite.server.URL+"/", "key", ttl)
+}
+
+func (suite *IntelSuite) Test_TAXII() {
+	indicators, err := suite.taxii().Fetch()
+	suite.NoError(err)
+	suite.Equal(2, suite.taxiiCalls)
+
+	validFrom := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	suite.Equal([]Indicator{
+		{
+			Type: TypeIPv4, Value: "192.0.2.1", Labels: []string{"c2", "emotet"}, Confidence: 80,
+			ValidFrom: validFrom, ValidUntil: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			Modified: time.Date(2020, 4, 10, 12, 0, 0, 0, time.UTC), Reference: "indicator--1",
+		},
+		{Type: TypeIPv6, Value: "2001:db8::/32", ValidFrom: validFrom, Reference: "indicator--2"},
+		{Type: TypeDomain, Value: "evil.example", ValidFrom: validFrom, Reference: "indicator--2"},
+		{
+			Type: TypeIPv4, Value: "198.51.100.1", ValidFrom: validFrom,
+			ValidUntil: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC), Reference: "indicator--3",
+		},
+		{Type: TypeEmail, Value: "ceo@phish.example", ValidFrom: validFrom, Reference: "indicator--4"},
+		{Type: TypeIPv4, Value: "203.0.113.0/24", ValidFrom: validFrom, Reference: "indicator--9"},
+	}, indicators)
+
+	_, err = NewTAXII(suite.server.URL+"/api/collections/indicators/", "soc", "invalid").Fetch()
+	suite.Error(err)
+}
+
+func (suite *IntelSuite) Test_MISP() {
+	modified := time.Unix(1585699200, 0).UTC()
+	indicators, err := suite.misp(24 * time.Hour).Fetch()
+	suite.NoError(err)
+	suite.Equal([]Indicator{
+		{
+			Type: TypeIPv4, Value: "192.0.2.10", Labels: []string{"tlp:white"},
+			Modified: modified, ValidUntil: modified.Add(24 * time.Hour), Reference: "event/10",
+		},
+		{
+			Type: TypeIPv6, Value: "2001:db8::10", Labels: []string{},
+			Modified: modified, ValidUntil: time.Date(2020, 4, 21, 0, 0, 0, 0, time.UTC), Reference: "event/10",
+		},
+		{
+			Type: TypeDomain, Value: "bad.example", Labels: []string{},
+			Modified: modified, ValidUntil: modified.Add(24 * time.Hour), Reference: "event/11",
+		},
+	}, indicators)
+
+	indicators, err = suite.misp(0).Fetch()
+	suite.NoError(err)
+	suite.True(indicators[0].ValidUntil.IsZero())
+
+	_, err = NewMISP(suite.server.URL, "invalid", 0).Fetch()
+	suite.Error(err)
+}
+
+func (suite *IntelSuite) Test_Feed() {
+	feed := NewFeed(time.Hour, suite.taxii(), suite.misp(0))
+	now := time.Date(2020, 4, 15, 0, 0, 0, 0, time.UTC)
+	feed.now = func() time.Time { return now }
+
+	values := func(indicators []Indicator) []string {
+		var values []string
+		for _, indicator := range indicators {
+			values = append(values, indicator.Value)
+		}
+		return values
+	}
+
+	suite.Equal([]string{"192.0.2.1", "2001:db8::/32", "198.51.100.1", "203.0.113.0/24", "192.0.2.10", "2001:db8::10"},
+		values(feed.Indicators(TypeIPv4, TypeIPv6)))
+	suite.Equal([]string{"evil.example", "ceo@phish.example", "bad.example"}, values(feed.Indicators(TypeDomain, TypeEmail)))
+	suite.Equal(2, suite.taxiiCalls)
+	suite.Equal(1, suite.mispCalls)
+
+	// expired indicator is removed, indicators are fetched again, but platforms are unavailable, so previous ones are kept
+	now = now.Add(30 * 24 * time.Hour)
+	suite.available = false
+	suite.Equal([]string{"192.0.2.1", "2001:db8::/32", "203.0.113.0/24", "192.0.2.10", "2001:db8::10"},
+		values(feed.Indicators(TypeIPv4, TypeIPv6)))
+
+	// indicator is not valid yet
+	now = time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	suite.Equal([]string{"192.0.2.10", "2001:db8::10"}, values(feed.Indicators(TypeIPv4, TypeIPv6)))
+}
+
+func (suite *IntelSuite) Test_IPDataSource() {
+	feed := NewFeed(time.Hour, suite.taxii())
+	feed.now = func() time.Time { return time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	ipnet := ipDatasource.NewIPNet(NewIPDataSource(feed), "threat_intel")
+	suite.NoError(ipnet.Load())
+
+	meta, found, err := ipnet.Find(net.ParseIP("192.0.2.1"))
+	suite.NoError(err)
+	suite.True(found)
+	suite.Equal(&ipDatasource.Meta{
+		Category:   "c2,emotet",
+		Confidence: 80,
+		FirstSeen:  time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC),
+		LastSeen:   time.Date(2020, 4, 10, 12, 0, 0, 0, time.UTC),
+		Reference:  "indicator--1",
+	}, meta)
+
+	found, err = ipnet.Check(net.ParseIP("2001:db8:1::1"))
+	suite.NoError(err)
+	suite.True(found)
+
+	// expired
+	found, err = ipnet.Check(net.ParseIP("198.51.100.1"))
+	suite.NoError(err)
+	suite.False(found)
+}
+
+func (suite *IntelSuite) Test_EmailDataSource() {
+	feed := NewFeed(time.Hour, suite.taxii(), suite.misp(0))
+	domains := emailDatasource.NewDomain(NewEmailDataSource(feed), "threat")
+	suite.NoError(domains.Load())
+
+	suite.True(domains.Check("evil.example"))
+	suite.True(domains.Check("bad.example"))
+	suite.True(domains.Check("ceo@phish.example"))
+	suite.False(domains.Check("phish.example"))
+}
+
+func TestIntelSuite(t *testing.T) {
+	suite.Run(t, new(IntelSuite))
+}
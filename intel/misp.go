@@ -0,0 +1,164 @@
+package intel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/optimatiq/threatbite/fetch"
+)
+
+var mispTypes = map[string]string{
+	"ip-src":    TypeIPv4,
+	"ip-dst":    TypeIPv4,
+	"domain":    TypeDomain,
+	"hostname":  TypeDomain,
+	"email":     TypeEmail,
+	"email-src": TypeEmail,
+	"email-dst": TypeEmail,
+}
+
+// MISP is a source of attributes, which are marked for detection (to_ids flag) in a MISP instance.
+type MISP struct {
+	url    string
+	key    string
+	ttl    time.Duration
+	client *http.Client
+}
+
+// NewMISP returns source, which searches attributes with MISP REST API.
+// MISP attributes don't have validity window, so they expire ttl after they were last seen or modified,
+// zero ttl means that attributes never expire.
+func NewMISP(url, key string, ttl time.Duration) *MISP {
+	return &MISP{
+		url:    strings.TrimSuffix(url, "/"),
+		key:    key,
+		ttl:    ttl,
+		client: fetch.NewHTTPClient(),
+	}
+}
+
+// Name returns URL of the instance.
+func (m *MISP) Name() string {
+	return m.url
+}
+
+type mispSearch struct {
+	ReturnFormat string   `json:"returnFormat"`
+	Type         []string `json:"type"`
+	ToIDs        bool     `json:"to_ids"`
+	Deleted      bool     `json:"deleted"`
+}
+
+type mispResponse struct {
+	Response struct {
+		Attribute []mispAttribute `json:"Attribute"`
+	} `json:"response"`
+}
+
+type mispAttribute struct {
+	ID        string `json:"id"`
+	EventID   string `json:"event_id"`
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+	Timestamp string `json:"timestamp"`
+	FirstSeen string `json:"first_seen"`
+	LastSeen  string `json:"last_seen"`
+	Tag       []struct {
+		Name string `json:"name"`
+	} `json:"Tag"`
+}
+
+// Fetch returns IP, domain and email attributes.
+func (m *MISP) Fetch() ([]Indicator, error) {
+	types := make([]string, 0, len(mispTypes))
+	for typ := range mispTypes {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+
+	body, err := json.Marshal(mispSearch{ReturnFormat: "json", Type: types, ToIDs: true})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, m.url+"/attributes/restSearch", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", m.key)
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := m.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid status code %d", response.StatusCode)
+	}
+
+	var result mispResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("cannot decode response, error: %w", err)
+	}
+
+	var indicators []Indicator
+	for _, attribute := range result.Response.Attribute {
+		if indicator, ok := m.indicator(attribute); ok {
+			indicators = append(indicators, indicator)
+		}
+	}
+	return indicators, nil
+}
+
+func (m *MISP) indicator(attribute mispAttribute) (Indicator, bool) {
+	typ, ok := mispTypes[attribute.Type]
+	if !ok {
+		return Indicator{}, false
+	}
+	if typ == TypeIPv4 {
+		ip := net.ParseIP(strings.TrimSpace(attribute.Value))
+		if ip == nil {
+			return Indicator{}, false
+		}
+		if ip.To4() == nil {
+			typ = TypeIPv6
+		}
+	}
+
+	labels := make([]string, 0, len(attribute.Tag))
+	for _, tag := range attribute.Tag {
+		labels = append(labels, tag.Name)
+	}
+
+	indicator := Indicator{
+		Type:      typ,
+		Value:     normalize(typ, attribute.Value),
+		Labels:    labels,
+		ValidFrom: parseTime(attribute.FirstSeen),
+		Reference: "event/" + attribute.EventID,
+	}
+	if timestamp, err := strconv.ParseInt(attribute.Timestamp, 10, 64); err == nil {
+		indicator.Modified = time.Unix(timestamp, 0).UTC()
+	}
+
+	if m.ttl > 0 {
+		seen := indicator.Modified
+		if lastSeen := parseTime(attribute.LastSeen); lastSeen.After(seen) {
+			seen = lastSeen
+		}
+		if !seen.IsZero() {
+			indicator.ValidUntil = seen.Add(m.ttl)
+		}
+	}
+	return indicator, true
+}
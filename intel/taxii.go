@@ -0,0 +1,185 @@
+package intel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/optimatiq/threatbite/fetch"
+)
+
+const (
+	taxiiMediaType = "application/taxii+json;version=2.1"
+	// taxiiMaxPages limits number of requested pages, when the server keeps returning "more" flag.
+	taxiiMaxPages = 1000
+)
+
+// taxiiPattern matches comparisons of supported observables in STIX patterns, e.g. [ipv4-addr:value = '192.0.2.1'].
+// Addresses can be also compared with networks, e.g. [ipv4-addr:value ISSUBSET '198.51.100.0/24'].
+var taxiiPattern = regexp.MustCompile(`(ipv4-addr|ipv6-addr|domain-name|email-addr):value\s*(=|ISSUBSET)\s*'((?:[^'\\]|\\.)*)'`)
+
+// taxiiUnsupported matches operators, which change meaning of comparisons, so indicators are not simple observables.
+var taxiiUnsupported = regexp.MustCompile(`\b(AND|FOLLOWEDBY|NOT)\b`)
+
+var taxiiTypes = map[string]string{
+	"ipv4-addr":   TypeIPv4,
+	"ipv6-addr":   TypeIPv6,
+	"domain-name": TypeDomain,
+	"email-addr":  TypeEmail,
+}
+
+// TAXII is a source of STIX 2.1 indicators from a TAXII 2.1 collection.
+type TAXII struct {
+	collectionURL string
+	user          string
+	password      string
+	client        *http.Client
+}
+
+// NewTAXII returns source, which polls objects of the collection.
+// collectionURL is the URL of the collection, e.g. https://taxii.example.com/api/collections/<id>/.
+// Basic authentication is used when user is not empty.
+func NewTAXII(collectionURL, user, password string) *TAXII {
+	return &TAXII{
+		collectionURL: strings.TrimSuffix(collectionURL, "/") + "/",
+		user:          user,
+		password:      password,
+		client:        fetch.NewHTTPClient(),
+	}
+}
+
+// Name returns URL of the collection.
+func (t *TAXII) Name() string {
+	return t.collectionURL
+}
+
+type taxiiEnvelope struct {
+	More    bool             `json:"more"`
+	Next    string           `json:"next"`
+	Objects []taxiiIndicator `json:"objects"`
+}
+
+type taxiiIndicator struct {
+	Type        string   `json:"type"`
+	ID          string   `json:"id"`
+	Pattern     string   `json:"pattern"`
+	PatternType string   `json:"pattern_type"`
+	Labels      []string `json:"labels"`
+	Confidence  int      `json:"confidence"`
+	ValidFrom   string   `json:"valid_from"`
+	ValidUntil  string   `json:"valid_until"`
+	Modified    string   `json:"modified"`
+	Revoked     bool     `json:"revoked"`
+}
+
+// Fetch returns indicators from all pages of the collection. Revoked indicators and patterns,
+// which combine observables with AND or FOLLOWEDBY or negate them with NOT, are skipped.
+func (t *TAXII) Fetch() ([]Indicator, error) {
+	var indicators []Indicator
+	next := ""
+	for page := 0; page < taxiiMaxPages; page++ {
+		envelope, err := t.page(next)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range envelope.Objects {
+			indicators = append(indicators, object.indicators()...)
+		}
+
+		if !envelope.More || envelope.Next == "" {
+			return indicators, nil
+		}
+		next = envelope.Next
+	}
+	return nil, fmt.Errorf("too many pages in collection: %s", t.collectionURL)
+}
+
+func (t *TAXII) page(next string) (*taxiiEnvelope, error) {
+	query := url.Values{}
+	query.Set("match[type]", "indicator")
+	if next != "" {
+		query.Set("next", next)
+	}
+
+	request, err := http.NewRequest(http.MethodGet, t.collectionURL+"objects/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", taxiiMediaType)
+	if t.user != "" {
+		request.SetBasicAuth(t.user, t.password)
+	}
+
+	response, err := t.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid status code %d", response.StatusCode)
+	}
+
+	var envelope taxiiEnvelope
+	if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("cannot decode envelope, error: %w", err)
+	}
+	return &envelope, nil
+}
+
+// indicators returns observables from the pattern of the STIX indicator.
+func (o taxiiIndicator) indicators() []Indicator {
+	if o.Type != "indicator" || o.Revoked || (o.PatternType != "" && o.PatternType != "stix") {
+		return nil
+	}
+	// quoted values are removed, so they are not mistaken for operators
+	if taxiiUnsupported.MatchString(taxiiPattern.ReplaceAllString(o.Pattern, "")) {
+		return nil
+	}
+
+	var indicators []Indicator
+	for _, match := range taxiiPattern.FindAllStringSubmatch(o.Pattern, -1) {
+		typ := taxiiTypes[match[1]]
+		value := strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(match[3])
+		// ISSUBSET makes sense only for networks, other observables are skipped
+		if match[2] == "ISSUBSET" && (typ == TypeDomain || typ == TypeEmail || !strings.Contains(value, "/")) {
+			continue
+		}
+		indicators = append(indicators, Indicator{
+			Type:       typ,
+			Value:      normalize(typ, value),
+			Labels:     o.Labels,
+			Confidence: confidence(o.Confidence),
+			ValidFrom:  parseTime(o.ValidFrom),
+			ValidUntil: parseTime(o.ValidUntil),
+			Modified:   parseTime(o.Modified),
+			Reference:  o.ID,
+		})
+	}
+	return indicators
+}
+
+// parseTime returns zero time for empty or invalid timestamps.
+func parseTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// confidence limits value to the 0-100 range.
+func confidence(value int) uint8 {
+	if value < 0 {
+		return 0
+	}
+	if value > 100 {
+		return 100
+	}
+	return uint8(value)
+}
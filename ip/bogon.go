@@ -0,0 +1,23 @@
+package ip
+
+import (
+	"net"
+
+	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/ip/datasource"
+)
+
+type bogon struct {
+	ipnet *datasource.IPNet
+}
+
+func newBogon(source datasource.DataSource) *bogon {
+	return &bogon{ipnet: datasource.NewIPNet(source, "bogon")}
+}
+
+// isBogon checks if IP belongs to unallocated or unannounced address space.
+func (b *bogon) isBogon(ip net.IP) (bool, error) {
+	isBogon, err := b.ipnet.Check(ip)
+	log.Debugf("[isBogon] ip: %s bogon: %t", ip, isBogon)
+	return isBogon, err
+}
@@ -0,0 +1,66 @@
+package ip
+
+import (
+	"net"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/ip/datasource"
+)
+
+// Category is a named list of IP addresses and networks (e.g. botnet C2, scanners, brute-forcers),
+// which is reloaded with its own interval and changes the scoring of listed addresses by its weight.
+// Weight is scaled by confidence of the entry, when the feed provides it.
+type Category struct {
+	Name     string
+	Interval time.Duration
+	Weight   int
+	ipnet    *datasource.IPNet
+}
+
+// NewCategory creates category with addresses from given data source.
+func NewCategory(name string, source datasource.DataSource, interval time.Duration, weight int) *Category {
+	return &Category{
+		Name:     name,
+		Interval: interval,
+		Weight:   weight,
+		ipnet:    datasource.NewIPNet(source, name),
+	}
+}
+
+// Evidence describes the entry of the category list, which contains the address.
+// Detail is the category of the entry in the feed (e.g. malware family), other fields are empty when feed doesn't provide them.
+type Evidence struct {
+	Category   string
+	Detail     string
+	Confidence uint8
+	FirstSeen  time.Time
+	LastSeen   time.Time
+	Reference  string
+}
+
+// find returns evidence when the address is on the category list.
+func (c *Category) find(ip net.IP) (*Evidence, error) {
+	meta, found, err := c.ipnet.Find(ip)
+	log.Debugf("[category] ip: %s %s: %t", ip, c.Name, found)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &Evidence{
+		Category:   c.Name,
+		Detail:     meta.Category,
+		Confidence: meta.Confidence,
+		FirstSeen:  meta.FirstSeen,
+		LastSeen:   meta.LastSeen,
+		Reference:  meta.Reference,
+	}, nil
+}
+
+// weight returns scoring change for the evidence, weight is scaled by confidence when feed provides it.
+func (c *Category) weight(evidence *Evidence) int {
+	if evidence.Confidence == 0 {
+		return c.Weight
+	}
+	return c.Weight * int(evidence.Confidence) / 100
+}
@@ -0,0 +1,36 @@
+package ip
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/optimatiq/threatbite/ip/datasource"
+)
+
+func TestCategory(t *testing.T) {
+	ds, err := datasource.NewListDataSource([]string{"192.0.2.0/24", "2001:db8::1"})
+	assert.NoError(t, err)
+
+	c := NewCategory("botnet", ds, time.Hour, -40)
+	assert.NoError(t, c.ipnet.Load())
+
+	for ip, want := range map[string]bool{
+		"192.0.2.7":     true,
+		"2001:db8::1":   true,
+		"198.51.100.1":  false,
+		"2001:db8:1::1": false,
+	} {
+		got, err := c.find(net.ParseIP(ip))
+		assert.NoError(t, err)
+		assert.Equal(t, want, got != nil, ip)
+	}
+
+	evidence, err := c.find(net.ParseIP("192.0.2.7"))
+	assert.NoError(t, err)
+	assert.Equal(t, &Evidence{Category: "botnet"}, evidence)
+	assert.Equal(t, -40, c.weight(evidence))
+	assert.Equal(t, -30, c.weight(&Evidence{Category: "botnet", Confidence: 75}))
+}
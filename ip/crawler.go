@@ -0,0 +1,204 @@
+package ip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/ip/datasource"
+)
+
+// Crawlers, which can be verified with the method published by their owners.
+const (
+	CrawlerGooglebot = "googlebot"
+	// CrawlerGoogleFetcher are fetchers triggered by users (e.g. feeds, site verification), not crawlers,
+	// they are the only Google agents using App Engine hosts.
+	CrawlerGoogleFetcher = "google-fetcher"
+	CrawlerBingbot       = "bingbot"
+	CrawlerYandexBot     = "yandexbot"
+	CrawlerApplebot      = "applebot"
+	CrawlerDuckDuckBot   = "duckduckbot"
+	CrawlerBaiduspider   = "baiduspider"
+	CrawlerYahooSlurp    = "yahoo-slurp"
+	CrawlerSeznamBot     = "seznambot"
+	CrawlerPetalBot      = "petalbot"
+	CrawlerMailRuBot     = "mailrubot"
+)
+
+// crawlerInfo describes how the crawler presents itself and how it can be verified.
+// Crawlers are verified by forward-confirmed reverse DNS (hostname has to end with one of the domains)
+// or by ranges published in JSON document (GCP format).
+type crawlerInfo struct {
+	name    string
+	agents  []string
+	domains []string
+	ranges  []string
+}
+
+var crawlers = []crawlerInfo{
+	{
+		name: CrawlerGooglebot,
+		agents: []string{"googlebot", "adsbot-google", "mediapartners-google", "apis-google",
+			"google-inspectiontool", "googleother", "storebot-google"},
+		// googleusercontent.com is not a Googlebot domain, every Google Cloud VM has a PTR record in it
+		domains: []string{".googlebot.com", ".google.com"},
+		ranges: []string{
+			"https://developers.google.com/static/search/apis/ipranges/googlebot.json",
+			"https://developers.google.com/static/search/apis/ipranges/special-crawlers.json",
+		},
+	},
+	{
+		name:    CrawlerGoogleFetcher,
+		agents:  []string{"feedfetcher-google", "google-read-aloud", "google-site-verification"},
+		domains: []string{".gae.googleusercontent.com"},
+		ranges: []string{
+			"https://developers.google.com/static/search/apis/ipranges/user-triggered-fetchers.json",
+			"https://developers.google.com/static/search/apis/ipranges/user-triggered-fetchers-google.json",
+		},
+	},
+	{
+		name:    CrawlerBingbot,
+		agents:  []string{"bingbot", "msnbot", "bingpreview", "adidxbot"},
+		domains: []string{".search.msn.com"},
+		ranges:  []string{"https://www.bing.com/toolbox/bingbot.json"},
+	},
+	{
+		name:    CrawlerYandexBot,
+		agents:  []string{"yandexbot", "yandeximages", "yandexmobilebot", "yandexmetrika", "yandexaccessibilitybot"},
+		domains: []string{".yandex.ru", ".yandex.net", ".yandex.com"},
+	},
+	{
+		name:    CrawlerApplebot,
+		agents:  []string{"applebot"},
+		domains: []string{".applebot.apple.com"},
+		ranges:  []string{"https://search.developer.apple.com/applebot.json"},
+	},
+	{
+		name:   CrawlerDuckDuckBot,
+		agents: []string{"duckduckbot", "duckassistbot"},
+		ranges: []string{"https://duckduckgo.com/duckduckbot.json"},
+	},
+	{
+		name:    CrawlerBaiduspider,
+		agents:  []string{"baiduspider"},
+		domains: []string{".crawl.baidu.com", ".crawl.baidu.jp"},
+	},
+	{
+		name:    CrawlerYahooSlurp,
+		agents:  []string{"yahoo! slurp"},
+		domains: []string{".crawl.yahoo.net"},
+	},
+	{
+		name:    CrawlerSeznamBot,
+		agents:  []string{"seznambot"},
+		domains: []string{".seznam.cz"},
+	},
+	{
+		name:    CrawlerPetalBot,
+		agents:  []string{"petalbot"},
+		domains: []string{".petalsearch.com"},
+	},
+	{
+		name:    CrawlerMailRuBot,
+		agents:  []string{"mail.ru_bot"},
+		domains: []string{".mail.ru"},
+	},
+}
+
+// ClaimedCrawler returns name of the crawler, which user agent claims to be, empty string is returned
+// when user agent doesn't belong to any of the verifiable crawlers.
+func ClaimedCrawler(userAgent string) string {
+	agent := strings.ToLower(userAgent)
+	for _, c := range crawlers {
+		for _, a := range c.agents {
+			if strings.Contains(agent, a) {
+				return c.name
+			}
+		}
+	}
+	return ""
+}
+
+type crawler struct {
+	ranges *datasource.IPNet
+}
+
+func newCrawler() *crawler {
+	var sources []datasource.CloudSource
+	for _, c := range crawlers {
+		for _, u := range c.ranges {
+			sources = append(sources, datasource.CloudSource{Provider: c.name, URL: u, Format: datasource.CloudGCP})
+		}
+	}
+
+	// all crawlers' documents use known format, so error is not possible here, they are remote and not signed
+	ds, err := datasource.NewCloudDataSource(sources, nil, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	return &crawler{ranges: datasource.NewIPNet(ds, "crawler")}
+}
+
+// verify returns name of the crawler, which uses the IP address. IP has to belong to the ranges published by
+// the crawler owner or one of its reverse DNS names (hostnames) has to end with the crawler domain and resolve back
+// to the same IP. Empty string is returned for IP, which is not used by any of the verifiable crawlers.
+func (c *crawler) verify(ip net.IP, hostnames []string) (string, error) {
+	meta, found, err := c.ranges.Find(ip)
+	if err != nil {
+		return "", fmt.Errorf("cannot run Find on %s, error: %w", ip, err)
+	}
+	if found {
+		log.Debugf("[crawler] ip: %s crawler: %s ranges match", ip, meta.Provider)
+		return meta.Provider, nil
+	}
+
+	for _, hostname := range hostnames {
+		name := crawlerByHostname(hostname)
+		if name == "" {
+			continue
+		}
+
+		if !forwardConfirmed(ip, hostname) {
+			log.Debugf("[crawler] ip: %s and host: %s don't match", ip, hostname)
+			continue
+		}
+
+		log.Debugf("[crawler] ip: %s crawler: %s host: %s", ip, name, hostname)
+		return name, nil
+	}
+
+	return "", nil
+}
+
+// crawlerByHostname returns name of the crawler, which owns the domain of hostname.
+func crawlerByHostname(hostname string) string {
+	host := "." + strings.TrimSuffix(strings.ToLower(hostname), ".")
+	for _, c := range crawlers {
+		for _, d := range c.domains {
+			if strings.HasSuffix(host, d) {
+				return c.name
+			}
+		}
+	}
+	return ""
+}
+
+// forwardConfirmed checks if hostname resolves to the IP address.
+func forwardConfirmed(ip net.IP, hostname string) bool {
+	ips, err := lookupIPWithTimeout(hostname, 500*time.Millisecond)
+	if err != nil {
+		// errors like "cannot lookup" are normal, we don't need to pollute error logs
+		log.Debugf("[crawler] ip: %s host: %s error: %s", ip, hostname, err)
+		return false
+	}
+
+	for _, i := range ips {
+		if i.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package ip
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimedCrawler(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)":                                   CrawlerGooglebot,
+		"FeedFetcher-Google; (+http://www.google.com/feedfetcher.html)":                                              CrawlerGoogleFetcher,
+		"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)":                                    CrawlerBingbot,
+		"Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)":                                           CrawlerYandexBot,
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_5) AppleWebKit/605.1.15 (Applebot/0.1)":                        CrawlerApplebot,
+		"DuckDuckBot/1.1; (+http://duckduckgo.com/duckduckbot.html)":                                                 CrawlerDuckDuckBot,
+		"Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)":                        CrawlerBaiduspider,
+		"Mozilla/5.0 (compatible; Yahoo! Slurp; http://help.yahoo.com/help/us/ysearch/slurp)":                        CrawlerYahooSlurp,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/83.0.4103.116":                          "",
+		"Mozilla/5.0 (compatible; SemrushBot/6~bl; +http://www.semrush.com/bot.html)":                                "",
+		"Mozilla/5.0 (Linux; Android 7.0;) AppleWebKit/537.36 (compatible; PetalBot;+https://aspiegel.com/petalbot)": CrawlerPetalBot,
+	}
+
+	for agent, want := range tests {
+		assert.Equal(t, want, ClaimedCrawler(agent), agent)
+	}
+}
+
+func Test_crawlerByHostname(t *testing.T) {
+	tests := map[string]string{
+		"crawl-66-249-66-1.googlebot.com.":                   CrawlerGooglebot,
+		"rate-limited-proxy-66-249-90-77.google.com":         CrawlerGooglebot,
+		"msnbot-157-55-39-1.search.msn.com":                  CrawlerBingbot,
+		"spider-5-255-253-1.yandex.com":                      CrawlerYandexBot,
+		"17-58-101-1.applebot.apple.com":                     CrawlerApplebot,
+		"baiduspider-123-125-71-1.crawl.baidu.com":           CrawlerBaiduspider,
+		"googlebot.com.attacker.example":                     "",
+		"1.2.0.35.bc.googleusercontent.com":                  "",
+		"google-proxy-66-249-81-1.gae.googleusercontent.com": CrawlerGoogleFetcher,
+		"fakegooglebot.com":                                  "",
+		"vm-1.cloudapp.azure.com":                            "",
+	}
+
+	for host, want := range tests {
+		assert.Equal(t, want, crawlerByHostname(host), host)
+	}
+}
+
+func Test_newCrawler(t *testing.T) {
+	assert.NotPanics(t, func() {
+		newCrawler()
+	})
+}
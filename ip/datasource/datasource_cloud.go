@@ -0,0 +1,347 @@
+package datasource
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/fetch"
+)
+
+// Cloud providers, which publish their address ranges in a machine-readable format.
+const (
+	CloudAWS          = "aws"
+	CloudGCP          = "gcp"
+	CloudAzure        = "azure"
+	CloudOracle       = "oracle"
+	CloudDigitalOcean = "digitalocean"
+	CloudCloudflare   = "cloudflare"
+)
+
+// cloudEntry is a single network found in the provider's document.
+type cloudEntry struct {
+	network string
+	service string
+	region  string
+}
+
+// cloudParsers contains parsers for documents published by cloud providers.
+var cloudParsers = map[string]func(body []byte) ([]cloudEntry, error){
+	CloudAWS:          parseAWS,
+	CloudGCP:          parseGCP,
+	CloudAzure:        parseAzure,
+	CloudOracle:       parseOracle,
+	CloudDigitalOcean: parseGeofeed,
+	CloudCloudflare:   parsePlain,
+}
+
+// CloudSource is a URL of the document with address ranges published by the cloud provider.
+// Format is the name of the provider, whose document format is used, by default it's the same as Provider.
+// It allows to load documents published in the same format by others, e.g. lists of crawlers in GCP format.
+type CloudSource struct {
+	Provider string
+	URL      string
+	Format   string
+}
+
+func (s CloudSource) format() string {
+	if s.Format != "" {
+		return s.Format
+	}
+	return s.Provider
+}
+
+// CloudDataSource stores current state (counters, sources, parsed entries) of this source.
+type CloudDataSource struct {
+	sources []CloudSource
+	s       int
+	entries []cloudEntry
+	e       int
+	meta    *Meta
+	client  *http.Client
+	keys    fetch.Keys
+	watcher *fetch.Watcher
+}
+
+// NewCloudDataSource returns iterator, which downloads documents published by cloud providers and extract
+// address ranges with the information about the provider, service and region.
+// Supported providers: aws, gcp, azure, oracle, digitalocean and cloudflare. Error is returned for unknown provider
+// or format. Documents can also be read from local files (file:// URLs or paths).
+// Signed documents are verified with keys, local files are checked for changes every poll interval (0 disables it).
+func NewCloudDataSource(sources []CloudSource, keys fetch.Keys, poll time.Duration) (*CloudDataSource, error) {
+	urls := make([]string, 0, len(sources))
+	for _, source := range sources {
+		if _, ok := cloudParsers[source.format()]; !ok {
+			return nil, fmt.Errorf("unsupported cloud provider: %s", source.format())
+		}
+		urls = append(urls, source.URL)
+	}
+
+	return &CloudDataSource{
+		sources: sources,
+		client:  fetch.NewHTTPClient(),
+		keys:    keys,
+		watcher: fetch.NewWatcher(urls, poll),
+	}, nil
+}
+
+// Reset rewinds source to the beginning.
+func (s *CloudDataSource) Reset() error {
+	s.watcher.Update()
+	s.s = 0
+	s.entries = nil
+	s.e = 0
+	s.meta = nil
+	return nil
+}
+
+// Changed returns true when local documents were modified since the last Reset.
+func (s *CloudDataSource) Changed() bool {
+	return s.watcher.Changed()
+}
+
+// Meta returns provider, service and region of the network returned by the last call of Next.
+func (s *CloudDataSource) Meta() *Meta {
+	return s.meta
+}
+
+// Next returns IP/CIDR, documents are downloaded and parsed one by one.
+// ErrNoData is returned when there is no data, this error indicates that we reached the end.
+// Error wrapping fetch.ErrUnverified is returned for signed documents, which cannot be verified.
+func (s *CloudDataSource) Next() (*net.IPNet, error) {
+	if s.s >= len(s.sources) {
+		return nil, ErrNoData
+	}
+	source := s.sources[s.s]
+
+	if s.entries == nil {
+		entries, err := s.download(source)
+		if err != nil {
+			s.s++
+			if errors.Is(err, fetch.ErrUnverified) {
+				return nil, err
+			}
+			log.Errorf("[datasource] cannot load %s ranges from: %s, error: %s", source.Provider, source.URL, err)
+			return nil, ErrInvalidData
+		}
+		s.entries = entries
+		s.e = 0
+	}
+
+	if s.e >= len(s.entries) {
+		s.entries = nil
+		s.s++
+		return s.Next()
+	}
+
+	entry := s.entries[s.e]
+	s.e++
+
+	_, ipNet, err := net.ParseCIDR(entry.network)
+	if err != nil {
+		return nil, ErrInvalidData
+	}
+
+	s.meta = &Meta{
+		Provider: source.Provider,
+		Service:  entry.service,
+		Region:   entry.region,
+	}
+	return ipNet, nil
+}
+
+func (s *CloudDataSource) download(source CloudSource) ([]cloudEntry, error) {
+	body, err := fetch.Get(s.client, source.URL, s.keys)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := cloudParsers[source.format()](body)
+	if err != nil {
+		return nil, err
+	}
+
+	// empty slice, to distinguish a document without ranges from a document that was not downloaded yet
+	if entries == nil {
+		entries = []cloudEntry{}
+	}
+	return entries, nil
+}
+
+// parseAWS parses https://ip-ranges.amazonaws.com/ip-ranges.json
+// The same prefix is listed for AMAZON (all services) and the specific service, specific ones are returned first,
+// because the first entry of the prefix is kept in the list.
+func parseAWS(body []byte) ([]cloudEntry, error) {
+	var document struct {
+		Prefixes []struct {
+			IPPrefix string `json:"ip_prefix"`
+			Region   string `json:"region"`
+			Service  string `json:"service"`
+		} `json:"prefixes"`
+		IPv6Prefixes []struct {
+			IPv6Prefix string `json:"ipv6_prefix"`
+			Region     string `json:"region"`
+			Service    string `json:"service"`
+		} `json:"ipv6_prefixes"`
+	}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, err
+	}
+
+	var entries []cloudEntry
+	for _, p := range document.Prefixes {
+		entries = append(entries, cloudEntry{network: p.IPPrefix, service: p.Service, region: p.Region})
+	}
+	for _, p := range document.IPv6Prefixes {
+		entries = append(entries, cloudEntry{network: p.IPv6Prefix, service: p.Service, region: p.Region})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].service != "AMAZON" && entries[j].service == "AMAZON"
+	})
+	return entries, nil
+}
+
+// parseGCP parses https://www.gstatic.com/ipranges/cloud.json
+// The same format is used by Google for lists of crawlers (googlebot.json), Bing and Apple.
+func parseGCP(body []byte) ([]cloudEntry, error) {
+	var document struct {
+		Prefixes []struct {
+			IPv4Prefix string `json:"ipv4Prefix"`
+			IPv6Prefix string `json:"ipv6Prefix"`
+			Service    string `json:"service"`
+			Scope      string `json:"scope"`
+		} `json:"prefixes"`
+	}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, err
+	}
+
+	var entries []cloudEntry
+	for _, p := range document.Prefixes {
+		network := p.IPv4Prefix
+		if network == "" {
+			network = p.IPv6Prefix
+		}
+		entries = append(entries, cloudEntry{network: network, service: p.Service, region: p.Scope})
+	}
+	return entries, nil
+}
+
+// parseAzure parses Azure IP Ranges and Service Tags (ServiceTags_Public_*.json).
+// Tags overlap, the most specific tags (service and region) are returned first.
+func parseAzure(body []byte) ([]cloudEntry, error) {
+	var document struct {
+		Values []struct {
+			Properties struct {
+				Region          string   `json:"region"`
+				SystemService   string   `json:"systemService"`
+				AddressPrefixes []string `json:"addressPrefixes"`
+			} `json:"properties"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, err
+	}
+
+	specificity := func(service, region string) int {
+		s := 0
+		if service != "" {
+			s += 2
+		}
+		if region != "" {
+			s++
+		}
+		return s
+	}
+
+	var entries []cloudEntry
+	for _, v := range document.Values {
+		service := v.Properties.SystemService
+		for _, prefix := range v.Properties.AddressPrefixes {
+			entries = append(entries, cloudEntry{network: prefix, service: service, region: v.Properties.Region})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return specificity(entries[i].service, entries[i].region) > specificity(entries[j].service, entries[j].region)
+	})
+	return entries, nil
+}
+
+// parseOracle parses https://docs.oracle.com/en-us/iaas/tools/public_ip_ranges.json
+func parseOracle(body []byte) ([]cloudEntry, error) {
+	var document struct {
+		Regions []struct {
+			Region string `json:"region"`
+			CIDRs  []struct {
+				CIDR string   `json:"cidr"`
+				Tags []string `json:"tags"`
+			} `json:"cidrs"`
+		} `json:"regions"`
+	}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, err
+	}
+
+	var entries []cloudEntry
+	for _, r := range document.Regions {
+		for _, c := range r.CIDRs {
+			entries = append(entries, cloudEntry{network: c.CIDR, service: strings.Join(c.Tags, ","), region: r.Region})
+		}
+	}
+	return entries, nil
+}
+
+// parseGeofeed parses RFC 8805 geofeed CSV (network, country, region, city, postal code),
+// which is used by DigitalOcean https://digitalocean.com/geo/google.csv
+func parseGeofeed(body []byte) ([]cloudEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	var entries []cloudEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// the most specific location available: city, region or country
+		var region string
+		for i := 3; i > 0 && region == ""; i-- {
+			if i < len(record) {
+				region = strings.TrimSpace(record[i])
+			}
+		}
+		entries = append(entries, cloudEntry{network: strings.TrimSpace(record[0]), region: region})
+	}
+	return entries, nil
+}
+
+// parsePlain parses documents with one CIDR per line, like https://www.cloudflare.com/ips-v4
+func parsePlain(body []byte) ([]cloudEntry, error) {
+	var entries []cloudEntry
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, cloudEntry{network: line})
+	}
+	return entries, scanner.Err()
+}
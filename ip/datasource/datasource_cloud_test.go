@@ -0,0 +1,107 @@
+package datasource
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var cloudDocuments = map[string]string{
+	"/aws.json": `{"syncToken":"1","prefixes":[
+		{"ip_prefix":"3.5.140.0/22","region":"ap-northeast-2","service":"AMAZON"},
+		{"ip_prefix":"3.5.140.0/22","region":"ap-northeast-2","service":"EC2"}],
+		"ipv6_prefixes":[{"ipv6_prefix":"2600:1f14::/35","region":"us-west-2","service":"EC2"}]}`,
+	"/gcp.json": `{"syncToken":"1","prefixes":[
+		{"ipv4Prefix":"34.1.208.0/20","service":"Google Cloud","scope":"africa-south1"},
+		{"ipv6Prefix":"2600:1900:8000::/44","service":"Google Cloud","scope":"us-central1"}]}`,
+	"/azure.json": `{"changeNumber":1,"values":[
+		{"name":"AzureCloud","properties":{"region":"","systemService":"","addressPrefixes":["13.64.0.0/11"]}},
+		{"name":"AzureFrontDoor.Frontend","properties":{"region":"westeurope","systemService":"AzureFrontDoor","addressPrefixes":["13.73.248.0/24"]}}]}`,
+	"/oracle.json": `{"last_updated_timestamp":"2020-01-01","regions":[
+		{"region":"us-phoenix-1","cidrs":[{"cidr":"129.146.0.0/21","tags":["OCI"]}]}]}`,
+	"/do.csv":         "# geofeed\n104.131.0.0/18,US,US-NY,New York,10011\n2a03:b0c0:3::/48,DE,DE-HE,,\n",
+	"/cloudflare.txt": "173.245.48.0/20\n2400:cb00::/32\n",
+	"/googlebot.json": `{"creationTime":"2020-01-01","prefixes":[{"ipv4Prefix":"66.249.64.0/27"}]}`,
+}
+
+type CloudSuite struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+func (suite *CloudSuite) SetupSuite() {
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, ok := cloudDocuments[r.URL.Path]; ok {
+			_, _ = w.Write([]byte(body))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+}
+
+func (suite *CloudSuite) TearDownSuite() {
+	suite.server.Close()
+}
+
+func (suite *CloudSuite) Test_NewCloudDataSource() {
+	_, err := NewCloudDataSource([]CloudSource{{Provider: "unknown", URL: suite.server.URL}}, nil, time.Second)
+	suite.Error(err)
+
+	_, err = NewCloudDataSource([]CloudSource{{Provider: CloudAWS, URL: suite.server.URL}}, nil, time.Second)
+	suite.NoError(err)
+
+	_, err = NewCloudDataSource([]CloudSource{{Provider: "googlebot", URL: suite.server.URL, Format: "unknown"}},
+		nil, time.Second)
+	suite.Error(err)
+}
+
+func (suite *CloudSuite) Test_Find() {
+	ds, err := NewCloudDataSource([]CloudSource{
+		{Provider: CloudAWS, URL: suite.server.URL + "/aws.json"},
+		{Provider: CloudGCP, URL: suite.server.URL + "/gcp.json"},
+		{Provider: CloudAzure, URL: suite.server.URL + "/not-found.json"},
+		{Provider: CloudAzure, URL: suite.server.URL + "/azure.json"},
+		{Provider: CloudOracle, URL: suite.server.URL + "/oracle.json"},
+		{Provider: CloudDigitalOcean, URL: suite.server.URL + "/do.csv"},
+		{Provider: CloudCloudflare, URL: suite.server.URL + "/cloudflare.txt"},
+		{Provider: "googlebot", URL: suite.server.URL + "/googlebot.json", Format: CloudGCP},
+	}, nil, time.Second)
+	suite.NoError(err)
+
+	list := NewIPNet(ds, "cloud")
+	suite.NoError(list.Load())
+
+	tests := []struct {
+		ip   string
+		want *Meta
+	}{
+		{"3.5.140.1", &Meta{Provider: CloudAWS, Service: "EC2", Region: "ap-northeast-2"}},
+		{"2600:1f14::1", &Meta{Provider: CloudAWS, Service: "EC2", Region: "us-west-2"}},
+		{"34.1.210.10", &Meta{Provider: CloudGCP, Service: "Google Cloud", Region: "africa-south1"}},
+		{"2600:1900:8000::1", &Meta{Provider: CloudGCP, Service: "Google Cloud", Region: "us-central1"}},
+		{"13.73.248.10", &Meta{Provider: CloudAzure, Service: "AzureFrontDoor", Region: "westeurope"}},
+		{"13.64.0.1", &Meta{Provider: CloudAzure}},
+		{"129.146.1.1", &Meta{Provider: CloudOracle, Service: "OCI", Region: "us-phoenix-1"}},
+		{"104.131.1.1", &Meta{Provider: CloudDigitalOcean, Region: "New York"}},
+		{"2a03:b0c0:3::1", &Meta{Provider: CloudDigitalOcean, Region: "DE-HE"}},
+		{"173.245.48.1", &Meta{Provider: CloudCloudflare}},
+		{"2400:cb00::1", &Meta{Provider: CloudCloudflare}},
+		{"66.249.64.1", &Meta{Provider: "googlebot"}},
+		{"8.8.8.8", nil},
+	}
+
+	for _, t := range tests {
+		meta, found, err := list.Find(net.ParseIP(t.ip))
+		suite.NoError(err)
+		suite.Equal(t.want != nil, found, t.ip)
+		suite.Equal(t.want, meta, t.ip)
+	}
+}
+
+func TestCloudSuite(t *testing.T) {
+	suite.Run(t, new(CloudSuite))
+}
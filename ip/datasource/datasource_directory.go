@@ -1,35 +1,36 @@
 package datasource
 
 import (
-	"bufio"
 	"fmt"
 	"net"
-	"os"
-	"path"
 	"path/filepath"
-	"strings"
-	"sync"
+	"time"
+
+	"github.com/optimatiq/threatbite/fetch"
 )
 
-// DirectoryDataSource stores current state (counters, files, scanners) of this source.
+// DirectoryDataSource stores current state (files, source reading them) of this source.
 type DirectoryDataSource struct {
-	path    string
-	files   []string
-	f       int
-	fLock   sync.Mutex
-	scanner *bufio.Scanner
-	file    *os.File
+	pattern string
+	keys    fetch.Keys
+	source  *URLDataSource
+	watcher *fetch.Watcher
 }
 
 // NewDirectoryDataSource returns iterator, which looks for all *.txt files in given directory or error.
-// Files should have each IPv4 IPv6 or CIDR in new line.
-// Comments are allowed and ignored. Comments start with # at the beginning of the line.
-func NewDirectoryDataSource(directory string) (*DirectoryDataSource, error) {
+// Files are read in the same way as local files of URLDataSource, so each line should contain IPv4, IPv6, CIDR or range.
+// Comments are allowed and ignored. Comments start with # or ; at the beginning of the line.
+// Directories can also be configured as sources of URLDataSource, then all files are read.
+// Files are checked for changes every poll interval (0 disables it).
+func NewDirectoryDataSource(directory string, keys fetch.Keys, poll time.Duration) (*DirectoryDataSource, error) {
+	pattern := filepath.Join(directory, "*.txt")
 	dataSource := &DirectoryDataSource{
-		path: path.Join(directory, "*.txt"),
+		pattern: pattern,
+		keys:    keys,
+		watcher: fetch.NewWatcher([]string{pattern}, poll),
 	}
 
-	err := dataSource.loadFiles()
+	err := dataSource.Reset()
 	if err != nil {
 		return nil, err
 	}
@@ -37,85 +38,35 @@ func NewDirectoryDataSource(directory string) (*DirectoryDataSource, error) {
 	return dataSource, nil
 }
 
-// Reset rewinds source to the beginning.
+// Reset looks for files again and rewinds source to the beginning.
 func (s *DirectoryDataSource) Reset() error {
-	return s.loadFiles()
-}
-
-func (s *DirectoryDataSource) loadFiles() error {
-	s.fLock.Lock()
-	defer s.fLock.Unlock()
-
-	matches, err := filepath.Glob(s.path)
+	matches, err := filepath.Glob(s.pattern)
 	if err != nil {
-		return fmt.Errorf("directory: %s, error: %w", s.path, err)
+		return fmt.Errorf("directory: %s, error: %w", s.pattern, err)
 	}
 
 	if len(matches) <= 0 {
-		return fmt.Errorf("no data in the directory: %s, error: %w", s.path, ErrInvalidData)
+		return fmt.Errorf("no data in the directory: %s, error: %w", s.pattern, ErrInvalidData)
 	}
 
-	s.scanner = nil
-	s.files = matches
-	s.f = 0
-
+	s.watcher.Update()
+	// files are watched by the directory source
+	s.source = NewURLDataSource(matches, s.keys, 0)
 	return nil
 }
 
-// Next returns IP/CIDR, this method knows, which file and line needs to be read.
-// ErrNoData is returned when there is no data, this error indicates that we reached the end.
-func (s *DirectoryDataSource) Next() (*net.IPNet, error) {
-	if s.f >= len(s.files) || len(s.files) <= 0 {
-		return nil, ErrNoData
-	}
-
-	// #nosec G304
-	if s.scanner == nil {
-		filename := s.files[s.f]
-		file, err := os.Open(filename)
-		if err != nil {
-			s.f++
-			return nil, fmt.Errorf("filename: %s, error: %w", filename, err)
-		}
-		s.scanner = bufio.NewScanner(file)
-		s.file = file
-	}
-
-	var line string
-	for s.scanner.Scan() {
-		line = s.scanner.Text()
-
-		// Comment
-		if strings.Index(line, "#") == 0 {
-			continue
-		}
-
-		// CIDR
-		if strings.Contains(line, "/") {
-			_, ipNet, err := net.ParseCIDR(line)
-			if err != nil {
-				return nil, ErrInvalidData
-			}
-			return ipNet, nil
-		}
-
-		// Single IP
-		ip := net.ParseIP(line)
-		if ip == nil {
-			return nil, ErrInvalidData
-		}
-		return &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))}, nil
-	}
-
-	// End of file or error
-	_ = s.file.Close()
-	err := s.scanner.Err()
-	s.scanner = nil
+// Changed returns true when *.txt files of the directory were modified, added or removed since the last Reset.
+func (s *DirectoryDataSource) Changed() bool {
+	return s.watcher.Changed()
+}
 
-	if err != nil {
-		return nil, err
-	}
+// Source returns file of the network returned by the last call of Next.
+func (s *DirectoryDataSource) Source() string {
+	return s.source.Source()
+}
 
-	s.f++
-	return s.Next()
+// Next returns IP/CIDR, files are read one by one.
+// ErrNoData is returned when there is no data, this error indicates that we reached the end.
+func (s *DirectoryDataSource) Next() (*net.IPNet, error) {
+	return s.source.Next()
 }
@@ -102,13 +102,13 @@ func (suite *DatasourceSuite) Test_StringDatasourceFunctions() {
 
 func (suite *DatasourceSuite) Test_NewDirectoryDatasource() {
 	dir := suite.createDir(true)
-	_, err := NewDirectoryDataSource(dir)
+	_, err := NewDirectoryDataSource(dir, nil, time.Second)
 	suite.NoError(err)
 	err = os.RemoveAll(dir)
 	suite.NoError(err)
 
 	dir = suite.createDir(false)
-	_, err = NewDirectoryDataSource(dir)
+	_, err = NewDirectoryDataSource(dir, nil, time.Second)
 	suite.Error(err)
 	err = os.RemoveAll(dir)
 	suite.NoError(err)
@@ -117,7 +117,7 @@ func (suite *DatasourceSuite) Test_NewDirectoryDatasource() {
 func (suite *DatasourceSuite) Test_NewURLDataSource() {
 	ds := NewURLDataSource([]string{
 		"https://iplists.firehol.org/files/proxz_1d.ipset",
-	})
+	}, nil, time.Second)
 
 	ip, err := ds.Next()
 	suite.NoError(err)
@@ -125,7 +125,7 @@ func (suite *DatasourceSuite) Test_NewURLDataSource() {
 
 	ds = NewURLDataSource([]string{
 		"invalid",
-	})
+	}, nil, time.Second)
 
 	suite.NoError(err)
 	ip, err = ds.Next()
@@ -135,7 +135,7 @@ func (suite *DatasourceSuite) Test_NewURLDataSource() {
 
 func (suite *DatasourceSuite) Test_DirectoryDatasourceNext() {
 	dir := suite.createDir(true)
-	ds, err := NewDirectoryDataSource(dir)
+	ds, err := NewDirectoryDataSource(dir, nil, time.Second)
 	suite.NoError(err)
 	for {
 		ip, err := ds.Next()
@@ -164,10 +164,45 @@ func (suite *DatasourceSuite) Test_DirectoryDatasourceNext() {
 	err = os.RemoveAll(dir)
 	suite.NoError(err)
 
-	_, err = NewDirectoryDataSource("\\")
+	_, err = NewDirectoryDataSource("\\", nil, time.Second)
 	suite.Error(err)
 }
 
+func (suite *DatasourceSuite) Test_URLDataSourceLocal() {
+	dir, err := ioutil.TempDir("", "datasource")
+	suite.NoError(err)
+	defer os.RemoveAll(dir)
+
+	suite.NoError(ioutil.WriteFile(filepath.Join(dir, "drop.txt"), []byte("1.10.16.0/20 ; SBL256894\n"), 0600))
+	file := filepath.Join(dir, "list.txt")
+	suite.NoError(ioutil.WriteFile(file, []byte("192.0.2.1\n"), 0600))
+
+	ipnet := NewIPNet(NewURLDataSource([]string{"file://" + dir + "#format=drop"}, nil, time.Second), "local")
+	suite.NoError(ipnet.Load())
+	suite.False(ipnet.Changed())
+
+	meta, found, err := ipnet.Find(net.ParseIP("1.10.16.1"))
+	suite.NoError(err)
+	suite.True(found)
+	suite.Equal("SBL256894", meta.Reference)
+
+	found, err = ipnet.Check(net.ParseIP("192.0.2.1"))
+	suite.NoError(err)
+	suite.True(found)
+
+	suite.NoError(ioutil.WriteFile(file, []byte("192.0.2.22\n"), 0600))
+	suite.True(ipnet.Changed())
+	suite.NoError(ipnet.Load())
+	suite.False(ipnet.Changed())
+
+	found, err = ipnet.Check(net.ParseIP("192.0.2.1"))
+	suite.NoError(err)
+	suite.False(found)
+	found, err = ipnet.Check(net.ParseIP("192.0.2.22"))
+	suite.NoError(err)
+	suite.True(found)
+}
+
 func (suite *DatasourceSuite) createDir(content bool) string {
 	dir, err := ioutil.TempDir("", "datasource")
 	suite.NoError(err)
@@ -1,123 +1,141 @@
 package datasource
 
 import (
-	"bufio"
-	"bytes"
-	"io/ioutil"
+	"errors"
 	"net"
 	"net/http"
-	"strings"
+	"net/url"
 	"time"
 
 	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/fetch"
 )
 
-// URLDataSource stores current state (counters, URLs, scanners) of this source.
+// URLDataSource stores current state (counters, URLs, parsed entries) of this source.
 type URLDataSource struct {
+	sources []string
 	urls    []string
 	u       int
-	scanner *bufio.Scanner
+	entries []feedEntry
+	e       int
+	meta    *Meta
 	client  *http.Client
+	keys    fetch.Keys
+	watcher *fetch.Watcher
 }
 
 // NewURLDataSource returns iterator, which downloads lists from provided URLs and extract addresses.
-// Files should have each IPv4 IPv6 or CIDR in new line.
-// Comments are allowed and ignored. Comments start with # at the beginning of the line.
-// Some lists have comments after their address, they are also ignored
-func NewURLDataSource(urls []string) *URLDataSource {
+// URLs can also point to local files and directories (file:// URLs or paths), all files of the directory are read.
+// By default files should have each IPv4, IPv6, CIDR or start-end range in new line.
+// Comments are allowed and ignored. Comments start with # or ; at the beginning of the line.
+// Some lists have comments after their address, they are also ignored.
+// Other formats (DROP, CSV, JSON, JSONL, abuse.ch) are selected in the URL fragment, see ParseFormat.
+// Signed URLs are verified with keys, local files are checked for changes every poll interval (0 disables it).
+func NewURLDataSource(urls []string, keys fetch.Keys, poll time.Duration) *URLDataSource {
 	dataSource := &URLDataSource{
-		client: &http.Client{
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   60 * time.Second,
-					KeepAlive: 15 * time.Second,
-				}).DialContext,
-				TLSHandshakeTimeout:   60 * time.Second,
-				ExpectContinueTimeout: 10 * time.Second,
-				ResponseHeaderTimeout: 10 * time.Second,
-			},
-			Timeout: 120 * time.Second,
-		},
-		urls: urls,
+		client:  fetch.NewHTTPClient(),
+		keys:    keys,
+		sources: urls,
+		watcher: fetch.NewWatcher(urls, poll),
 	}
 
 	return dataSource
 }
 
-// Reset rewinds source to the beginning.
+// Reset rewinds source to the beginning, local directories are listed again.
 func (s *URLDataSource) Reset() error {
+	s.watcher.Update()
+	s.urls = nil
 	s.u = 0
-	s.scanner = nil
+	s.entries = nil
+	s.e = 0
+	s.meta = nil
 	return nil
 }
 
-// Next returns IP/CIDR, this method knows which URL and line needs to be read.
-// URLs are downloaded one by one and kept in memory, bufio.NewScanner is used to keep track, which line has to be returned.
+// Changed returns true when local files of the source were modified since the last Reset.
+func (s *URLDataSource) Changed() bool {
+	return s.watcher.Changed()
+}
+
+// Source returns URL (or file of the directory) of the network returned by the last call of Next.
+func (s *URLDataSource) Source() string {
+	if s.u >= len(s.urls) {
+		return ""
+	}
+	return s.urls[s.u]
+}
+
+// Meta returns category, confidence, first and last seen time and reference of the network returned
+// by the last call of Next, nil is returned when the feed doesn't provide them.
+func (s *URLDataSource) Meta() *Meta {
+	return s.meta
+}
+
+// Next returns IP/CIDR, URLs are downloaded and parsed one by one.
+// Start-end ranges are returned as the smallest set of networks, which cover them.
 // ErrNoData is returned when there is no data, this error indicates that we reached the end.
+// Error wrapping fetch.ErrUnverified is returned for signed sources, which cannot be verified.
 func (s *URLDataSource) Next() (*net.IPNet, error) {
+	if s.urls == nil {
+		s.urls = fetch.Expand(s.sources)
+	}
 	if s.u >= len(s.urls) || len(s.urls) <= 0 {
 		return nil, ErrNoData
 	}
-	url := s.urls[s.u]
+	u := s.urls[s.u]
 
-	if s.scanner == nil {
-		response, err := s.client.Get(url)
+	if s.entries == nil {
+		entries, err := s.download(u)
 		if err != nil {
-			log.Errorf("[datasource] cannot download list from: %s, error: %s", url, err)
-			s.u++
-			return nil, ErrInvalidData
-		}
-
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			log.Errorf("[datasource] cannot read from: %s, error: %s", url, err)
 			s.u++
+			// unverified signed source is returned, so the update can be rejected and the current list kept
+			if errors.Is(err, fetch.ErrUnverified) {
+				return nil, err
+			}
+			log.Errorf("[datasource] cannot load list from: %s, error: %s", u, err)
 			return nil, ErrInvalidData
 		}
-
-		if err := response.Body.Close(); err != nil {
-			log.Errorf("[datasource] cannot close response body from: %s, error: %s", url, err)
-		}
-
-		s.scanner = bufio.NewScanner(bytes.NewReader(body))
+		s.entries = entries
+		s.e = 0
 	}
 
-	var line string
-	for s.scanner.Scan() {
-		line = s.scanner.Text()
-		// some lists have address with optional comment as a second argument separated by spaces or tabs
-		line = strings.ReplaceAll(line, "\t", " ")
-		line := strings.Split(line, " ")[0]
-
-		// Comment
-		if strings.Index(line, "#") == 0 {
-			continue
-		}
+	if s.e >= len(s.entries) {
+		s.entries = nil
+		s.u++
+		return s.Next()
+	}
 
-		// CIDR
-		if strings.Contains(line, "/") {
-			_, ipNet, err := net.ParseCIDR(line)
-			if err != nil {
-				return nil, ErrInvalidData
-			}
-			return ipNet, nil
-		}
+	entry := s.entries[s.e]
+	s.e++
+	s.meta = entry.meta
+	return entry.network, nil
+}
 
-		// Single IP
-		ip := net.ParseIP(line)
-		if ip == nil {
-			return nil, ErrInvalidData
-		}
-		return &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))}, nil
+// download gets the list without the fragment, which contains format options, and parses it.
+func (s *URLDataSource) download(rawURL string) ([]feedEntry, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	format, err := ParseFormat(u.Fragment)
+	if err != nil {
+		return nil, err
 	}
 
-	err := s.scanner.Err()
-	s.scanner = nil
+	body, err := fetch.Get(s.client, rawURL, s.keys)
+	if err != nil {
+		return nil, err
+	}
 
+	entries, err := format.parse(body)
 	if err != nil {
 		return nil, err
 	}
 
-	s.u++
-	return s.Next()
+	// empty slice, to distinguish a list without entries from a list that was not downloaded yet
+	if entries == nil {
+		entries = []feedEntry{}
+	}
+	return entries, nil
 }
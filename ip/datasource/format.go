@@ -0,0 +1,510 @@
+package datasource
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Feed formats, which can be selected in the URL fragment, e.g. https://example.com/feed.csv#format=csv&ip=2&category=5.
+const (
+	FormatPlain   = "plain"
+	FormatDROP    = "drop"
+	FormatCSV     = "csv"
+	FormatJSON    = "json"
+	FormatJSONL   = "jsonl"
+	FormatAbuseCH = "abusech"
+)
+
+// Fields of the feed entry, which can be mapped to CSV columns (1-based index or header name) or JSON keys (dotted path).
+const (
+	FieldIP         = "ip"
+	FieldEnd        = "end"
+	FieldCategory   = "category"
+	FieldConfidence = "confidence"
+	FieldFirstSeen  = "first_seen"
+	FieldLastSeen   = "last_seen"
+	FieldReference  = "reference"
+)
+
+var fields = []string{FieldIP, FieldEnd, FieldCategory, FieldConfidence, FieldFirstSeen, FieldLastSeen, FieldReference}
+
+// Format describes how entries are extracted from the feed.
+// Fields maps entry fields to CSV columns or JSON keys, Path is a JSONPath selector of entries in JSON document.
+// Category is assigned to all entries of plain and DROP lists, other formats map category to a column or key.
+type Format struct {
+	Name      string
+	Fields    map[string]string
+	Path      string
+	Separator rune
+	Category  string
+}
+
+// ParseFormat parses format options from the URL fragment, empty fragment means plain format.
+// Options: format, path (json), separator (csv), category and field mappings (ip, end, category, confidence,
+// first_seen, last_seen, reference).
+func ParseFormat(fragment string) (Format, error) {
+	values, err := url.ParseQuery(fragment)
+	if err != nil {
+		return Format{}, fmt.Errorf("invalid format options: %s, error: %w", fragment, err)
+	}
+
+	f := Format{Name: values.Get("format"), Fields: map[string]string{}, Separator: ','}
+	if f.Name == "" {
+		f.Name = FormatPlain
+	}
+
+	switch f.Name {
+	case FormatPlain, FormatDROP:
+	case FormatCSV:
+		f.Fields[FieldIP] = "1"
+	case FormatJSON, FormatJSONL:
+		f.Fields[FieldIP] = "ip"
+		f.Path = "$"
+	case FormatAbuseCH:
+		// abuse.ch CSV exports: first_seen_utc, dst_ip, dst_port, c2_status, last_online, malware
+		f.Fields = map[string]string{FieldFirstSeen: "1", FieldIP: "2", FieldLastSeen: "5", FieldCategory: "6"}
+	default:
+		return Format{}, fmt.Errorf("unsupported feed format: %s", f.Name)
+	}
+
+	for _, field := range fields {
+		if v := values.Get(field); v != "" {
+			f.Fields[field] = v
+		}
+	}
+	if v := values.Get("path"); v != "" {
+		f.Path = v
+	}
+	if v := values.Get("separator"); v != "" {
+		if v == "tab" {
+			v = "\t"
+		}
+		if len(v) != 1 {
+			return Format{}, fmt.Errorf("invalid separator: %s, expected single character or tab", v)
+		}
+		f.Separator = rune(v[0])
+	}
+	if f.Name == FormatPlain || f.Name == FormatDROP {
+		// lines don't have columns, so category is a constant assigned to all entries
+		f.Category = f.Fields[FieldCategory]
+		f.Fields = map[string]string{}
+	}
+
+	if f.Name == FormatJSON || f.Name == FormatJSONL {
+		if _, err := parsePath(f.Path); err != nil {
+			return Format{}, err
+		}
+	}
+	return f, nil
+}
+
+// feedEntry is a single network found in the feed, meta is nil when the feed doesn't provide any metadata.
+type feedEntry struct {
+	network *net.IPNet
+	meta    *Meta
+}
+
+// parse extracts entries from the feed, invalid entries are skipped.
+func (f Format) parse(body []byte) ([]feedEntry, error) {
+	switch f.Name {
+	case FormatCSV, FormatAbuseCH:
+		return f.parseCSV(body)
+	case FormatJSON:
+		return f.parseJSON(body)
+	case FormatJSONL:
+		return f.parseJSONL(body)
+	default:
+		return f.parseLines(body), nil
+	}
+}
+
+// parseLines parses IP, CIDR or start-end range in each line, lines starting with # or ; are comments.
+// In DROP format reference (e.g. SBL123) follows the address after semicolon, other formats ignore text after the address.
+func (f Format) parseLines(body []byte) []feedEntry {
+	var entries []feedEntry
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		record := map[string]string{}
+		if f.Name == FormatDROP {
+			if i := strings.IndexByte(line, ';'); i >= 0 {
+				record[FieldReference] = strings.TrimSpace(line[i+1:])
+				line = line[:i]
+			}
+		}
+
+		tokens := strings.Fields(line)
+		record[FieldIP] = tokens[0]
+		// range with spaces around the dash: 192.0.2.0 - 192.0.2.255
+		if len(tokens) >= 3 && tokens[1] == "-" {
+			record[FieldIP] = tokens[0] + "-" + tokens[2]
+		}
+		entries = append(entries, f.entries(record)...)
+	}
+	return entries
+}
+
+// parseCSV parses CSV with columns selected by 1-based index or by header name (then the first row is a header).
+func (f Format) parseCSV(body []byte) ([]feedEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.Comma = f.Separator
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	columns := map[string]int{}
+	var named bool
+	for field, column := range f.Fields {
+		i, err := strconv.Atoi(column)
+		if err != nil {
+			named = true
+			continue
+		}
+		if i < 1 {
+			return nil, fmt.Errorf("invalid column of %s: %s, columns are numbered from 1", field, column)
+		}
+		columns[field] = i - 1
+	}
+
+	if named {
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CSV header, error: %w", err)
+		}
+		for field, column := range f.Fields {
+			if _, ok := columns[field]; ok {
+				continue
+			}
+			for i, name := range header {
+				if strings.EqualFold(strings.TrimSpace(name), column) {
+					columns[field] = i
+				}
+			}
+			if _, ok := columns[field]; !ok {
+				return nil, fmt.Errorf("column of %s: %s not found in CSV header", field, column)
+			}
+		}
+	}
+
+	var entries []feedEntry
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				continue
+			}
+			return nil, err
+		}
+
+		record := map[string]string{}
+		for field, i := range columns {
+			if i < len(row) {
+				record[field] = strings.TrimSpace(row[i])
+			}
+		}
+		entries = append(entries, f.entries(record)...)
+	}
+}
+
+// parseJSON selects entries in the document with JSONPath selector.
+func (f Format) parseJSON(body []byte) ([]feedEntry, error) {
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, fmt.Errorf("cannot parse JSON, error: %w", err)
+	}
+
+	path, _ := parsePath(f.Path)
+	var entries []feedEntry
+	for _, item := range path.selectAll(document) {
+		entries = append(entries, f.entries(f.jsonRecord(item))...)
+	}
+	return entries, nil
+}
+
+// parseJSONL parses JSON value in each line, JSONPath selector is applied to each of them.
+func (f Format) parseJSONL(body []byte) ([]feedEntry, error) {
+	path, _ := parsePath(f.Path)
+	var entries []feedEntry
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(line, &value); err != nil {
+			continue
+		}
+		for _, item := range path.selectAll(value) {
+			entries = append(entries, f.entries(f.jsonRecord(item))...)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// jsonRecord converts selected JSON value into entry fields, string value is an address itself.
+func (f Format) jsonRecord(item interface{}) map[string]string {
+	record := map[string]string{}
+	if s, ok := item.(string); ok {
+		record[FieldIP] = s
+		return record
+	}
+
+	for field, key := range f.Fields {
+		path, err := parsePath("$." + key)
+		if err != nil {
+			continue
+		}
+		if values := path.selectAll(item); len(values) > 0 && values[0] != nil {
+			switch v := values[0].(type) {
+			case string:
+				record[field] = v
+			case float64:
+				record[field] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				record[field] = fmt.Sprint(v)
+			}
+		}
+	}
+	return record
+}
+
+// entries converts record into networks with metadata, invalid records result in no entries.
+func (f Format) entries(record map[string]string) []feedEntry {
+	networks := parseNetworks(record[FieldIP], record[FieldEnd])
+	if len(networks) == 0 {
+		return nil
+	}
+
+	meta := f.meta(record)
+	entries := make([]feedEntry, 0, len(networks))
+	for _, network := range networks {
+		entries = append(entries, feedEntry{network: network, meta: meta})
+	}
+	return entries
+}
+
+// meta returns metadata of the record or nil when there is none.
+func (f Format) meta(record map[string]string) *Meta {
+	meta := &Meta{
+		Category:  record[FieldCategory],
+		Reference: record[FieldReference],
+		FirstSeen: parseTime(record[FieldFirstSeen]),
+		LastSeen:  parseTime(record[FieldLastSeen]),
+	}
+	if meta.Category == "" {
+		meta.Category = f.Category
+	}
+	if v := record[FieldConfidence]; v != "" {
+		meta.Confidence = parseConfidence(v)
+	}
+
+	if *meta == (Meta{}) {
+		return nil
+	}
+	return meta
+}
+
+// parseNetworks parses IP, CIDR or start-end range (range can be also given as separate end value).
+// Range is split into the smallest set of networks, which cover it.
+func parseNetworks(value, end string) []*net.IPNet {
+	value = strings.TrimSpace(value)
+	if end == "" {
+		if i := strings.IndexByte(value, '-'); i > 0 {
+			value, end = strings.TrimSpace(value[:i]), strings.TrimSpace(value[i+1:])
+		}
+	}
+
+	if end != "" {
+		return rangeNetworks(net.ParseIP(value), net.ParseIP(strings.TrimSpace(end)))
+	}
+
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil
+		}
+		return []*net.IPNet{network}
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return []*net.IPNet{{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))}}
+}
+
+// rangeNetworks returns networks covering start-end range, nil is returned for invalid ranges.
+// A range is split into at most 2 networks per address bit.
+func rangeNetworks(start, end net.IP) []*net.IPNet {
+	if start == nil || end == nil {
+		return nil
+	}
+	if s4, e4 := start.To4(), end.To4(); s4 != nil && e4 != nil {
+		start, end = s4, e4
+	} else if s4 != nil || e4 != nil {
+		return nil
+	}
+
+	bits := 8 * len(start)
+	first := new(big.Int).SetBytes(start)
+	last := new(big.Int).SetBytes(end)
+	if first.Cmp(last) > 0 {
+		return nil
+	}
+
+	var networks []*net.IPNet
+	one := big.NewInt(1)
+	for first.Cmp(last) <= 0 {
+		// the largest network, which starts at first and doesn't go beyond last
+		size := 0
+		for size < bits {
+			if first.Bit(size) != 0 {
+				break
+			}
+			next := new(big.Int).Lsh(one, uint(size+1))
+			next.Add(next, first).Sub(next, one)
+			if next.Cmp(last) > 0 {
+				break
+			}
+			size++
+		}
+
+		ip := make(net.IP, bits/8)
+		b := first.Bytes()
+		copy(ip[len(ip)-len(b):], b)
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits-size, bits)})
+		first.Add(first, new(big.Int).Lsh(one, uint(size)))
+	}
+	return networks
+}
+
+// timeLayouts are layouts of dates used by feeds.
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// parseTime parses date in one of the common layouts or as unix timestamp, zero time is returned on failure.
+func parseTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil && unix > 0 {
+		return time.Unix(unix, 0).UTC()
+	}
+	return time.Time{}
+}
+
+// parseConfidence parses confidence as a percentage (0-100) or a fraction (0-1), 0 is returned on failure.
+func parseConfidence(value string) uint8 {
+	c, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || c < 0 {
+		return 0
+	}
+	if c <= 1 && strings.Contains(value, ".") {
+		c *= 100
+	}
+	if c > 100 {
+		c = 100
+	}
+	return uint8(c + 0.5)
+}
+
+// jsonPath is a parsed JSONPath selector, supported syntax: $, .key, ['key'], [n] and [*].
+type jsonPath []string
+
+const jsonPathWildcard = "*"
+
+func parsePath(path string) (jsonPath, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSONPath: %s, expected $ at the beginning", path)
+	}
+
+	var steps jsonPath
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			i := strings.IndexAny(rest, ".[")
+			if i < 0 {
+				i = len(rest)
+			}
+			if i == 0 {
+				return nil, fmt.Errorf("invalid JSONPath: %s, empty key", path)
+			}
+			steps = append(steps, rest[:i])
+			rest = rest[i:]
+		case '[':
+			i := strings.IndexByte(rest, ']')
+			if i < 0 {
+				return nil, fmt.Errorf("invalid JSONPath: %s, missing ]", path)
+			}
+			step := strings.Trim(rest[1:i], `'"`)
+			if step == "" {
+				return nil, fmt.Errorf("invalid JSONPath: %s, empty index", path)
+			}
+			steps = append(steps, step)
+			rest = rest[i+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSONPath: %s, unexpected character: %c", path, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// selectAll returns all values selected by the path, wildcard selects all elements of array or values of object.
+func (p jsonPath) selectAll(value interface{}) []interface{} {
+	values := []interface{}{value}
+	for _, step := range p {
+		var next []interface{}
+		for _, v := range values {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if step == jsonPathWildcard {
+					for _, item := range v {
+						next = append(next, item)
+					}
+				} else if item, ok := v[step]; ok {
+					next = append(next, item)
+				}
+			case []interface{}:
+				if step == jsonPathWildcard {
+					next = append(next, v...)
+				} else if i, err := strconv.Atoi(step); err == nil && i >= 0 && i < len(v) {
+					next = append(next, v[i])
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
@@ -0,0 +1,183 @@
+package datasource
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var feeds = map[string]string{
+	"/plain.txt": "# comment\n192.0.2.1 some comment\n198.51.100.0/24\n203.0.113.10 - 203.0.113.20\n2001:db8::/32\ninvalid\n",
+	"/drop.txt":  "; Spamhaus DROP List\n1.10.16.0/20 ; SBL256894\n1.19.0.0/16 ; SBL434604\n",
+	"/feed.csv": "ip,first_seen,last_seen,category,score\n" +
+		"192.0.2.1,2020-04-01,2020-04-10 12:00:00,scanner,80\n" +
+		"\"198.51.100.7\",1585699200,,bruteforce,0.5\n" +
+		"not an ip,2020-04-01,,scanner,10\n",
+	"/feed.tsv":   "192.0.2.1\t192.0.2.3\tc2\n",
+	"/feodo.csv":  "# Feodo Tracker\n# \"first_seen_utc\",\"dst_ip\",\"dst_port\",\"c2_status\",\"last_online\",\"malware\"\n\"2021-01-17 07:44:46\",\"51.178.161.32\",\"4643\",\"online\",\"2021-05-01\",\"Dridex\"\n",
+	"/feed.json":  `{"data": [{"attrs": {"ip": "192.0.2.1", "confidence": 90}, "category": "c2"}, {"attrs": {"ip": "bad"}}]}`,
+	"/plain.json": `["192.0.2.1", "198.51.100.0/24"]`,
+	"/feed.jsonl": "{\"cidr\":\"1.10.16.0/20\",\"sblid\":\"SBL256894\"}\n{\"type\":\"metadata\",\"records\":1}\n",
+}
+
+type FormatSuite struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+func (suite *FormatSuite) SetupSuite() {
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, ok := feeds[r.URL.Path]; ok {
+			_, _ = w.Write([]byte(body))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+}
+
+func (suite *FormatSuite) TearDownSuite() {
+	suite.server.Close()
+}
+
+// load returns all networks with metadata returned by the data source.
+func (suite *FormatSuite) load(url string) map[string]*Meta {
+	ds := NewURLDataSource([]string{suite.server.URL + url}, nil, time.Second)
+	networks := map[string]*Meta{}
+	for {
+		network, err := ds.Next()
+		if err == ErrNoData {
+			return networks
+		}
+		if err == ErrInvalidData {
+			continue
+		}
+		suite.Require().NoError(err)
+		networks[network.String()] = ds.Meta()
+	}
+}
+
+func (suite *FormatSuite) Test_ParseFormat() {
+	f, err := ParseFormat("")
+	suite.NoError(err)
+	suite.Equal(FormatPlain, f.Name)
+
+	f, err = ParseFormat("format=csv&ip=2&separator=tab")
+	suite.NoError(err)
+	suite.Equal(map[string]string{FieldIP: "2"}, f.Fields)
+	suite.Equal('\t', f.Separator)
+
+	f, err = ParseFormat("format=plain&category=botnet")
+	suite.NoError(err)
+	suite.Equal("botnet", f.Category)
+	suite.Empty(f.Fields)
+
+	for _, fragment := range []string{"format=xml", "format=csv&separator=;;", "format=json&path=data", "format=json&path=$.data[*", "%zz"} {
+		_, err := ParseFormat(fragment)
+		suite.Error(err, fragment)
+	}
+}
+
+func (suite *FormatSuite) Test_Plain() {
+	suite.Equal(map[string]*Meta{
+		"192.0.2.1/32":    nil,
+		"198.51.100.0/24": nil,
+		"203.0.113.10/31": nil,
+		"203.0.113.12/30": nil,
+		"203.0.113.16/30": nil,
+		"203.0.113.20/32": nil,
+		"2001:db8::/32":   nil,
+	}, suite.load("/plain.txt"))
+
+	networks := suite.load("/plain.txt#category=spam")
+	suite.Len(networks, 7)
+	suite.Equal(&Meta{Category: "spam"}, networks["192.0.2.1/32"])
+}
+
+func (suite *FormatSuite) Test_DROP() {
+	suite.Equal(map[string]*Meta{
+		"1.10.16.0/20": {Reference: "SBL256894"},
+		"1.19.0.0/16":  {Reference: "SBL434604"},
+	}, suite.load("/drop.txt#format=drop"))
+}
+
+func (suite *FormatSuite) Test_CSV() {
+	suite.Equal(map[string]*Meta{
+		"192.0.2.1/32": {
+			Category:   "scanner",
+			Confidence: 80,
+			FirstSeen:  time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC),
+			LastSeen:   time.Date(2020, 4, 10, 12, 0, 0, 0, time.UTC),
+		},
+		"198.51.100.7/32": {
+			Category:   "bruteforce",
+			Confidence: 50,
+			FirstSeen:  time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}, suite.load("/feed.csv#format=csv&ip=ip&first_seen=2&last_seen=3&category=category&confidence=score"))
+
+	suite.Equal(map[string]*Meta{
+		"192.0.2.1/32": {Category: "c2"},
+		"192.0.2.2/31": {Category: "c2"},
+	}, suite.load("/feed.tsv#format=csv&separator=tab&end=2&category=3"))
+
+	suite.Empty(suite.load("/feed.csv#format=csv&ip=address"))
+}
+
+func (suite *FormatSuite) Test_AbuseCH() {
+	suite.Equal(map[string]*Meta{
+		"51.178.161.32/32": {
+			Category:  "Dridex",
+			FirstSeen: time.Date(2021, 1, 17, 7, 44, 46, 0, time.UTC),
+			LastSeen:  time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}, suite.load("/feodo.csv#format=abusech"))
+}
+
+func (suite *FormatSuite) Test_JSON() {
+	suite.Equal(map[string]*Meta{
+		"192.0.2.1/32": {Category: "c2", Confidence: 90},
+	}, suite.load("/feed.json#format=json&path=$.data[*]&ip=attrs.ip&confidence=attrs.confidence&category=category"))
+
+	suite.Equal(map[string]*Meta{
+		"192.0.2.1/32":    nil,
+		"198.51.100.0/24": nil,
+	}, suite.load("/plain.json#format=json&path=$[*]"))
+
+	suite.Equal(map[string]*Meta{
+		"1.10.16.0/20": {Reference: "SBL256894"},
+	}, suite.load("/feed.jsonl#format=jsonl&ip=cidr&reference=sblid"))
+}
+
+func (suite *FormatSuite) Test_Errors() {
+	suite.Empty(suite.load("/missing.txt"))
+	suite.Empty(suite.load("/plain.txt#format=unknown"))
+	suite.Empty(suite.load("/plain.txt#format=json"))
+}
+
+func (suite *FormatSuite) Test_IPNet() {
+	ipnet := NewIPNet(NewURLDataSource([]string{suite.server.URL + "/drop.txt#format=drop"}, nil, time.Second), "drop")
+	suite.NoError(ipnet.Load())
+
+	meta, found, err := ipnet.Find(net.ParseIP("1.10.16.1"))
+	suite.NoError(err)
+	suite.True(found)
+	suite.Equal("SBL256894", meta.Reference)
+}
+
+func (suite *FormatSuite) Test_rangeNetworks() {
+	suite.Nil(rangeNetworks(net.ParseIP("192.0.2.10"), net.ParseIP("192.0.2.1")))
+	suite.Nil(rangeNetworks(net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")))
+	suite.Len(rangeNetworks(net.ParseIP("10.0.0.1"), net.ParseIP("10.255.255.254")), 46)
+	suite.Equal([]*net.IPNet{{IP: net.ParseIP("10.0.0.0").To4(), Mask: net.CIDRMask(8, 32)}},
+		rangeNetworks(net.ParseIP("10.0.0.0"), net.ParseIP("10.255.255.255")))
+	suite.Equal([]*net.IPNet{{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(127, 128)}},
+		rangeNetworks(net.ParseIP("2001:db8::"), net.ParseIP("2001:db8::1")))
+}
+
+func TestFormatSuite(t *testing.T) {
+	suite.Run(t, new(FormatSuite))
+}
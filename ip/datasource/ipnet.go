@@ -9,125 +9,228 @@ import (
 
 	"github.com/asergeyev/nradix"
 	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/fetch"
+	"github.com/optimatiq/threatbite/guard"
 	"github.com/patrickmn/go-cache"
 )
 
 // IPNet container struct for IP/CIDR operations
 type IPNet struct {
 	cache     *cache.Cache
-	cidrs     *nradix.Tree
+	cidrs4    *nradix.Tree
+	cidrs6    *nradix.Tree
 	cidrsLock sync.RWMutex
-	ips       map[uint64]bool
+	ips       map[uint64]*Meta
 	ipsLock   sync.RWMutex
+	entries   int
+	sources   map[string]int
 	ds        DataSource
 	name      string
+
+	guard      *guard.Guard
+	quarantine *guard.Quarantine
 }
 
 // NewIPNet returns a new IP/CIDR list build on top of radix tree (for CIDRS) and go map for IPs.
 func NewIPNet(ds DataSource, name string) *IPNet {
 	return &IPNet{
-		cache: cache.New(1*time.Minute, 1*time.Minute),
-		cidrs: nradix.NewTree(0),
-		ips:   make(map[uint64]bool),
-		ds:    ds,
-		name:  name,
+		cache:  cache.New(1*time.Minute, 1*time.Minute),
+		cidrs4: nradix.NewTree(0),
+		cidrs6: nradix.NewTree(0),
+		ips:    make(map[uint64]*Meta),
+		ds:     ds,
+		name:   name,
 	}
 }
 
+// Name returns name of the list.
+func (l *IPNet) Name() string {
+	return l.name
+}
+
+// SetGuard sets limits of updates, updates, which violate them, are put into the quarantine (optional) instead of applied.
+func (l *IPNet) SetGuard(g *guard.Guard, quarantine *guard.Quarantine) {
+	l.guard = g
+	l.quarantine = quarantine
+}
+
 // Check if lists contains IP from request
 func (l *IPNet) Check(ip net.IP) (bool, error) {
+	_, found, err := l.Find(ip)
+	return found, err
+}
+
+// Find returns metadata of the most specific entry, which contains IP from request.
+// Metadata is never nil for found entries, but it's empty when data source doesn't provide it.
+func (l *IPNet) Find(ip net.IP) (*Meta, bool, error) {
 	ipString := ip.String()
 	keyPermBlockIP := "ip_" + ipString
-	if _, ok := l.cache.Get(keyPermBlockIP); ok {
-		return true, nil
+	if v, ok := l.cache.Get(keyPermBlockIP); ok {
+		return v.(*Meta), true, nil
 	}
 
 	l.cidrsLock.RLock()
-	cidrFound, err := l.cidrs.FindCIDR(ipString)
+	cidrFound, err := l.tree(ip).FindCIDR(ipString)
 	l.cidrsLock.RUnlock()
 	if err != nil {
-		return false, fmt.Errorf("could not find element: %s, error: %w", ipString, err)
+		return nil, false, fmt.Errorf("could not find element: %s, error: %w", ipString, err)
 	}
 
-	if cidrFound != nil {
-		l.cache.Set(keyPermBlockIP, cidrFound, 0)
-		return true, nil
+	if meta, ok := cidrFound.(*Meta); ok {
+		l.cache.Set(keyPermBlockIP, meta, 0)
+		return meta, true, nil
 	}
 
 	uip, _ := l.ipToUint64(ip)
 	l.ipsLock.RLock()
-	value, ipFound := l.ips[uip]
+	meta, ipFound := l.ips[uip]
 	l.ipsLock.RUnlock()
 	if ipFound {
-		l.cache.Set(keyPermBlockIP, value, 0)
-		return true, nil
+		l.cache.Set(keyPermBlockIP, meta, 0)
+		return meta, true, nil
 	}
 
-	return false, nil
+	return nil, false, nil
 }
 
 // Close clears underlying radix tree.
 func (l *IPNet) Close() {
 	l.ipsLock.Lock()
-	l.ips = map[uint64]bool{}
+	l.ips = map[uint64]*Meta{}
 	l.ipsLock.Unlock()
 
 	l.cidrsLock.Lock()
-	l.cidrs = nradix.NewTree(0)
+	l.cidrs4 = nradix.NewTree(0)
+	l.cidrs6 = nradix.NewTree(0)
 	l.cidrsLock.Unlock()
 
 	l.cache.Flush()
 }
+
+// Changed returns true when the data source detected changes of its local files, so the list should be loaded again.
+func (l *IPNet) Changed() bool {
+	if ds, ok := l.ds.(WatchedDataSource); ok {
+		return ds.Changed()
+	}
+	return false
+}
+
+// Load replaces the list with the current content of the data source.
+// The update is applied only when the data source was read completely, on errors the current list is kept.
+// When the guard rejects the update, the current list is kept and the update is quarantined.
+// Sizes of sources of SourcedDataSource are checked separately, so a broken source is not hidden by other sources.
+// Signed sources, which cannot be verified, reject the update as well.
 func (l *IPNet) Load() error {
 	var cidrs int
+	var rejected error
 
-	cidrsTemp := nradix.NewTree(0)
-	ipsTemp := map[uint64]bool{}
+	cidrs4Temp := nradix.NewTree(0)
+	cidrs6Temp := nradix.NewTree(0)
+	ipsTemp := map[uint64]*Meta{}
+	sourcesTemp := map[string]int{}
 
 	log.Debugf("[list] loading %s list start", l.name)
-	defer func() {
-		l.ipsLock.Lock()
-		l.ips = ipsTemp
-		l.ipsLock.Unlock()
-
-		l.cidrsLock.Lock()
-		l.cidrs = cidrsTemp
-		l.cidrsLock.Unlock()
-
-		l.cache.Flush()
-
-		log.Debugf("[list] loading %s stop; stats IPs: %d, CIDRs: %d", l.name, len(l.ips), cidrs)
-	}()
-
 	if err := l.ds.Reset(); err != nil {
 		return fmt.Errorf("could not reset data source, error: %w", err)
 	}
 
+	metaSource, withMeta := l.ds.(MetaDataSource)
+	sourced, withSources := l.ds.(SourcedDataSource)
+
 	for {
 		ipNet, err := l.ds.Next()
 		if err != nil {
 			if err == ErrNoData {
-				return nil
+				break
 			} else if err == ErrInvalidData {
 				continue
+			} else if errors.Is(err, fetch.ErrUnverified) {
+				if rejected == nil {
+					rejected = fmt.Errorf("%w: %s", guard.ErrRejected, err)
+				}
+				continue
 			} else {
 				return fmt.Errorf("could not iterate over data source, error: %w", err)
 			}
 		}
 
+		// the first violation is reported, but the update is loaded completely, so it can be released from the quarantine
+		if rejected == nil {
+			rejected = l.guard.CheckNetwork(ipNet)
+		}
+		if withSources {
+			sourcesTemp[sourced.Source()]++
+		}
+
+		meta := emptyMeta
+		if withMeta {
+			if m := metaSource.Meta(); m != nil {
+				meta = m
+			}
+		}
+
 		// single IP address, not a CIDR, mask contains only "ones"
 		if ones, bits := ipNet.Mask.Size(); ones == bits {
 			i, _ := l.ipToUint64(ipNet.IP)
-			ipsTemp[i] = true
+			if _, ok := ipsTemp[i]; !ok {
+				ipsTemp[i] = meta
+			}
 			continue
 		}
 
-		err = cidrsTemp.AddCIDR(ipNet.String(), true)
+		cidrsTemp := cidrs6Temp
+		if ipNet.IP.To4() != nil {
+			cidrsTemp = cidrs4Temp
+		}
+		err = cidrsTemp.AddCIDR(ipNet.String(), meta)
 		if err != nil && err != nradix.ErrNodeBusy {
 			return fmt.Errorf("could not add IP: %s, error: %w", ipNet.String(), err)
 		}
 		cidrs++
 	}
+
+	l.ipsLock.RLock()
+	previous, previousSources := l.entries, l.sources
+	l.ipsLock.RUnlock()
+
+	err := l.guard.Apply(l.quarantine, guard.Update{
+		List:            l.name,
+		Entries:         len(ipsTemp) + cidrs,
+		Previous:        previous,
+		Sources:         sourcesTemp,
+		PreviousSources: previousSources,
+		Rejected:        rejected,
+		Apply: func() {
+			l.ipsLock.Lock()
+			l.ips = ipsTemp
+			l.entries = len(ipsTemp) + cidrs
+			l.sources = sourcesTemp
+			l.ipsLock.Unlock()
+
+			l.cidrsLock.Lock()
+			l.cidrs4 = cidrs4Temp
+			l.cidrs6 = cidrs6Temp
+			l.cidrsLock.Unlock()
+
+			l.cache.Flush()
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("could not load %s list, error: %w", l.name, err)
+	}
+
+	log.Debugf("[list] loading %s stop; stats IPs: %d, CIDRs: %d", l.name, len(ipsTemp), cidrs)
+	return nil
+}
+
+// tree returns radix tree for the IP version of given address.
+// IPv4 and IPv6 networks are kept in separate trees, because nradix compares only leading bits of the keys,
+// so IPv6 network like 2001:db8::/32 would also match IPv4 address 32.1.13.184.
+func (l *IPNet) tree(ip net.IP) *nradix.Tree {
+	if ip.To4() != nil {
+		return l.cidrs4
+	}
+	return l.cidrs6
 }
 
 func (l *IPNet) ipToUint64(ip net.IP) (uint64, error) {
@@ -1,9 +1,17 @@
 package datasource
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/optimatiq/threatbite/guard"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -31,6 +39,18 @@ func (suite *ListSuite) Test_CheckMixed() {
 			true,
 			false,
 		},
+		{
+			[]string{"2001:db8::/32"},
+			"32.1.13.184",
+			false,
+			false,
+		},
+		{
+			[]string{"32.1.0.0/16"},
+			"2001:db8::1",
+			false,
+			false,
+		},
 	}
 	for _, t := range tests {
 		ds, err := NewListDataSource(t.data)
@@ -181,6 +201,170 @@ func (suite *ListSuite) Test_CheckIP() {
 	}
 }
 
+func (suite *ListSuite) Test_Guard() {
+	load := func(ipnet *IPNet, data []string) error {
+		ds, err := NewListDataSource(data)
+		suite.NoError(err)
+		ipnet.ds = ds
+		return ipnet.Load()
+	}
+	check := func(ipnet *IPNet, ip string) bool {
+		found, err := ipnet.Check(net.ParseIP(ip))
+		suite.NoError(err)
+		return found
+	}
+
+	_, protected, _ := net.ParseCIDR("198.51.100.0/24")
+	quarantine := guard.NewQuarantine()
+	ipnet := NewIPNet(nil, "proxy")
+	ipnet.SetGuard(&guard.Guard{MaxPrefix4: 10, Protected: []*net.IPNet{protected}, MinEntries: 1, MaxChange: 50}, quarantine)
+
+	suite.NoError(load(ipnet, []string{"192.0.2.0/28", "203.0.113.1", "203.0.113.2", "203.0.113.3", "203.0.113.4",
+		"203.0.113.5", "203.0.113.6", "203.0.113.7", "203.0.113.8", "203.0.113.9", "203.0.113.10"}))
+	suite.Empty(quarantine.Reports())
+
+	// too large network
+	err := load(ipnet, []string{"192.0.2.0/28", "10.0.0.0/8"})
+	suite.True(errors.Is(err, guard.ErrRejected))
+	suite.False(check(ipnet, "10.1.1.1"))
+	suite.True(check(ipnet, "203.0.113.10"))
+
+	// own network
+	err = load(ipnet, []string{"192.0.2.0/28", "198.51.100.7"})
+	suite.True(errors.Is(err, guard.ErrRejected))
+	suite.False(check(ipnet, "198.51.100.7"))
+
+	// list shrank
+	err = load(ipnet, []string{"192.0.2.1"})
+	suite.True(errors.Is(err, guard.ErrRejected))
+	reports := quarantine.Reports()
+	suite.Len(reports, 1)
+	suite.Equal("proxy", reports[0].List)
+	suite.Equal(1, reports[0].Entries)
+	suite.Equal(11, reports[0].Previous)
+	suite.True(check(ipnet, "203.0.113.10"))
+
+	suite.NoError(quarantine.Release("proxy"))
+	suite.False(check(ipnet, "203.0.113.10"))
+	suite.True(check(ipnet, "192.0.2.1"))
+
+	// rejected update is replaced by the accepted one
+	suite.Error(load(ipnet, []string{"0.0.0.0/0"}))
+	suite.NoError(load(ipnet, []string{"192.0.2.2"}))
+	suite.Empty(quarantine.Reports())
+}
+
+func (suite *ListSuite) Test_GuardSources() {
+	dir, err := ioutil.TempDir("", "sources")
+	suite.NoError(err)
+	defer os.RemoveAll(dir)
+
+	write := func(name string, entries int) string {
+		var lines []string
+		for i := 1; i <= entries; i++ {
+			lines = append(lines, fmt.Sprintf("203.0.113.%d", i))
+		}
+		file := filepath.Join(dir, name)
+		suite.NoError(ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")), 0600))
+		return file
+	}
+	large, small := write("large.txt", 20), write("small.txt", 4)
+
+	quarantine := guard.NewQuarantine()
+	ipnet := NewIPNet(NewURLDataSource([]string{large, small}, nil, time.Second), "proxy")
+	ipnet.SetGuard(&guard.Guard{MaxChange: 50}, quarantine)
+	suite.NoError(ipnet.Load())
+
+	// total change is small, but the small source is empty
+	write("small.txt", 0)
+	err = ipnet.Load()
+	suite.True(errors.Is(err, guard.ErrRejected))
+	suite.Contains(err.Error(), small)
+	suite.Len(quarantine.Reports(), 1)
+}
+
+func (suite *ListSuite) Test_GuardUnverified() {
+	dir, err := ioutil.TempDir("", "unverified")
+	suite.NoError(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "list.txt")
+	suite.NoError(ioutil.WriteFile(file, []byte("192.0.2.1\n"), 0600))
+
+	quarantine := guard.NewQuarantine()
+	ipnet := NewIPNet(NewURLDataSource([]string{file}, nil, time.Second), "proxy")
+	ipnet.SetGuard(nil, quarantine)
+	suite.NoError(ipnet.Load())
+
+	// the signature cannot be verified, so the current list is kept
+	suite.NoError(ioutil.WriteFile(file, []byte("0.0.0.0/0\n"), 0600))
+	ipnet.ds = NewURLDataSource([]string{file + "#key=vendor"}, nil, time.Second)
+	err = ipnet.Load()
+	suite.True(errors.Is(err, guard.ErrRejected))
+	suite.Len(quarantine.Reports(), 1)
+	found, err := ipnet.Check(net.ParseIP("198.51.100.1"))
+	suite.NoError(err)
+	suite.False(found)
+	found, err = ipnet.Check(net.ParseIP("192.0.2.1"))
+	suite.NoError(err)
+	suite.True(found)
+}
+
+// failingDataSource returns entries of the list and then fails, like a download interrupted in the middle.
+type failingDataSource struct {
+	*ListDataSource
+	resetErr error
+}
+
+func (s *failingDataSource) Reset() error {
+	if s.resetErr != nil {
+		return s.resetErr
+	}
+	return s.ListDataSource.Reset()
+}
+
+func (s *failingDataSource) Next() (*net.IPNet, error) {
+	ipNet, err := s.ListDataSource.Next()
+	if err == ErrNoData {
+		return nil, errors.New("connection reset by peer")
+	}
+	return ipNet, err
+}
+
+func (suite *ListSuite) Test_LoadError() {
+	quarantine := guard.NewQuarantine()
+	ds, err := NewListDataSource([]string{"192.0.2.1"})
+	suite.NoError(err)
+	ipnet := NewIPNet(ds, "proxy")
+	ipnet.SetGuard(&guard.Guard{MinEntries: 5}, quarantine)
+	suite.Error(ipnet.Load())
+	suite.Len(quarantine.Reports(), 1)
+
+	ipnet.SetGuard(nil, quarantine)
+	suite.NoError(ipnet.Load())
+	suite.Empty(quarantine.Reports())
+
+	// neither partial nor failed update is applied or quarantined, the current list is kept
+	partial, err := NewListDataSource([]string{"198.51.100.1"})
+	suite.NoError(err)
+	for _, ds := range []DataSource{
+		&failingDataSource{ListDataSource: partial},
+		&failingDataSource{ListDataSource: partial, resetErr: errors.New("no such host")},
+	} {
+		ipnet.ds = ds
+		err = ipnet.Load()
+		suite.Error(err)
+		suite.False(errors.Is(err, guard.ErrRejected))
+		suite.Empty(quarantine.Reports())
+
+		found, err := ipnet.Check(net.ParseIP("192.0.2.1"))
+		suite.NoError(err)
+		suite.True(found)
+		found, err = ipnet.Check(net.ParseIP("198.51.100.1"))
+		suite.NoError(err)
+		suite.False(found)
+	}
+}
+
 func TestListSuite(t *testing.T) {
 	suite.Run(t, new(ListSuite))
 }
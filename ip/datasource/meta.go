@@ -0,0 +1,30 @@
+package datasource
+
+import "time"
+
+// Meta contains optional information about the network returned by the data source.
+// Provider, Service and Region describe cloud ranges, other fields come from threat feeds.
+type Meta struct {
+	Provider string
+	Service  string
+	Region   string
+
+	// Category of the threat (e.g. malware family), Confidence (0-100) is 0 when the feed doesn't provide it.
+	Category   string
+	Confidence uint8
+	FirstSeen  time.Time
+	LastSeen   time.Time
+
+	// Reference is an identifier of the entry in the feed, e.g. Spamhaus SBL ID.
+	Reference string
+}
+
+// emptyMeta is stored for networks from data sources, which don't provide any metadata.
+var emptyMeta = &Meta{}
+
+// MetaDataSource is a DataSource, which is able to describe returned networks.
+type MetaDataSource interface {
+	DataSource
+	// Meta returns information about the network returned by the last call of Next.
+	Meta() *Meta
+}
@@ -24,6 +24,11 @@ func (m *mockedGeoip) getCompany(ip net.IP) (string, error) {
 	return args.String(0), args.Error(1)
 }
 
+func (m *mockedGeoip) getASN(ip net.IP) (uint, error) {
+	args := m.Called(ip)
+	return uint(args.Int(0)), args.Error(1)
+}
+
 func (m *mockedGeoip) update() error {
 	return nil
 }
@@ -44,7 +49,8 @@ func Test_datacenter_isDC(t *testing.T) {
 		geoip geoip
 	}
 	type args struct {
-		ip net.IP
+		ip        net.IP
+		hostnames []string
 	}
 	tests := []struct {
 		name    string
@@ -92,6 +98,16 @@ func Test_datacenter_isDC(t *testing.T) {
 			args: args{ip: net.ParseIP("1.1.1.3")},
 			want: true,
 		},
+
+		{
+			name: "reverse name",
+			fields: fields{
+				list:  []string{"1.1.1.1"},
+				geoip: geo,
+			},
+			args: args{ip: net.ParseIP("1.1.1.2"), hostnames: []string{"vps-1-1-1-2.example.net"}},
+			want: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -100,11 +116,13 @@ func Test_datacenter_isDC(t *testing.T) {
 			d := &datacenter{
 				ipnet: datasource.NewIPNet(ds, "vpn"),
 				geoip: tt.fields.geoip,
+				names: NewDatacenterNames(),
+				hosts: NewDatacenterHosts(),
 			}
 			err = d.ipnet.Load()
 			assert.NoError(t, err)
 
-			got, err := d.isDC(tt.args.ip)
+			got, _, err := d.isDC(tt.args.ip, tt.args.hostnames)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("isDC() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -5,5 +5,6 @@ import "net"
 type geoip interface {
 	getCountry(ip net.IP) (string, error)
 	getCompany(ip net.IP) (string, error)
+	getASN(ip net.IP) (uint, error)
 	update() error
 }
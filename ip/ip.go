@@ -5,7 +5,10 @@ import (
 	"net"
 	"time"
 
+	"github.com/optimatiq/threatbite/fetch"
+	"github.com/optimatiq/threatbite/guard"
 	"github.com/optimatiq/threatbite/ip/datasource"
+	"github.com/optimatiq/threatbite/pattern"
 
 	"github.com/labstack/gommon/log"
 	"golang.org/x/sync/errgroup"
@@ -13,53 +16,185 @@ import (
 
 // Info a struct, which contains information about IP address.
 type Info struct {
-	Company        string
-	Country        string
-	Hostnames      []string
-	IsProxy        bool
-	IsSearchEngine bool
-	IsTor          bool
-	IsPrivate      bool
-	IsDatacenter   bool
-	IsSpam         bool
-	IsVpn          bool
-	IPScoring      uint8
+	Company       string
+	ASN           uint
+	Country       string
+	Hostnames     []string
+	IsProxy       bool
+	IsTor         bool
+	IsPrivate     bool
+	AddressClass  string
+	IsDatacenter  bool
+	IsSpam        bool
+	IsVpn         bool
+	IsBogon       bool
+	CloudProvider string
+	CloudService  string
+	CloudRegion   string
+	IPScoring     uint8
+
+	// Crawler is the name of verified search engine crawler, which uses IP address (e.g. googlebot, bingbot).
+	Crawler string
+
+	// LocalHistory is reputation of the address built from outcome feedback (-100 - 100), 0 means no history.
+	LocalHistory int
+
+	// Categories contains all configured categories, value is set when the address is on the category list.
+	// Evidence contains details of the entries, which matched the address.
+	Categories map[string]bool
+	Evidence   []Evidence
+
+	// Matches contains patterns, which were found in the company name or reverse DNS name, in form of "set:pattern".
+	Matches []string
+
+	// Transition and Embedded are set for IPv6 transition addresses (6to4, Teredo, NAT64),
+	// Embedded contains information about IPv4 address extracted from IPv6 address.
+	Transition string
+	Embedded   *Info
+}
+
+// History returns local reputation of the IP address (-100 - 100), 0 means no history.
+type History interface {
+	IP(ip net.IP) int
 }
 
 // IP container struct for IP service.
 type IP struct {
-	tor    *tor
-	geoip  geoip
-	engine *searchEngine
-	proxy  *proxy
-	dc     *datacenter
-	spam   *spam
-	vpn    *vpn
+	history    History
+	categories []*Category
+	tor        *tor
+	geoip      geoip
+	crawler    *crawler
+	proxy      *proxy
+	dc         *datacenter
+	spam       *spam
+	vpn        *vpn
+	bogon      *bogon
+}
+
+// Options configure the IP service, nil data sources are treated as empty lists.
+type Options struct {
+	// MaxmindKey is the MaxMind license key. MaxmindURL is URL of the mirror of MaxMind archives,
+	// {edition} is replaced with the edition ID (GeoLite2-ASN, GeoLite2-Country).
+	// Archives are verified with Keys, when the URL is signed (key option).
+	MaxmindKey string
+	MaxmindURL string
+	Keys       fetch.Keys
+
+	Proxy      datasource.DataSource
+	Spam       datasource.DataSource
+	VPN        datasource.DataSource
+	Datacenter datasource.DataSource
+	Bogon      datasource.DataSource
+	Cloud      datasource.DataSource
+
+	// Patterns of hosting companies names and reverse DNS names of servers and proxies,
+	// nil sets are replaced with built-in patterns.
+	DatacenterNames *pattern.Set
+	DatacenterHosts *pattern.Set
+	ProxyHosts      *pattern.Set
+
+	// Categories are checked in addition to built-in lists.
+	Categories []*Category
+
+	// Guards of lists by their names, updates rejected by a guard are put into the Quarantine.
+	// Lists without a guard accept every update.
+	Guards     map[string]*guard.Guard
+	Quarantine *guard.Quarantine
+
+	// History is a source of local reputation, which adjusts the scoring (optional).
+	History History
 }
 
 // NewIP creates a service for getting information about IP address.
-func NewIP(maxmindKey string, proxyDs, spamDs, vpnDs, dcDs datasource.DataSource) *IP {
-	geo := newMaxmind(maxmindKey)
-
-	return &IP{
-		geoip:  geo,
-		tor:    newTor(),
-		proxy:  newProxy(proxyDs),
-		engine: newSearchEngine(geo),
-		dc:     newDC(geo, dcDs),
-		spam:   newSpam(spamDs),
-		vpn:    newVpn(vpnDs),
+func NewIP(options Options) *IP {
+	geo := newMaxmind(options.MaxmindKey)
+	geo.url = options.MaxmindURL
+	geo.keys = options.Keys
+
+	if options.DatacenterNames == nil {
+		options.DatacenterNames = NewDatacenterNames()
+	}
+	if options.DatacenterHosts == nil {
+		options.DatacenterHosts = NewDatacenterHosts()
+	}
+	if options.ProxyHosts == nil {
+		options.ProxyHosts = NewProxyHosts()
+	}
+
+	i := &IP{
+		history:    options.History,
+		categories: options.Categories,
+		geoip:      geo,
+		tor:        newTor(),
+		proxy:      newProxy(orEmpty(options.Proxy), options.ProxyHosts),
+		crawler:    newCrawler(),
+		dc:         newDC(geo, orEmpty(options.Datacenter), orEmpty(options.Cloud), options.DatacenterNames, options.DatacenterHosts),
+		spam:       newSpam(orEmpty(options.Spam)),
+		vpn:        newVpn(orEmpty(options.VPN)),
+		bogon:      newBogon(orEmpty(options.Bogon)),
+	}
+
+	lists := []*datasource.IPNet{i.proxy.ipnet, i.spam.ipnet, i.vpn.ipnet, i.dc.ipnet, i.dc.cloud, i.crawler.ranges, i.bogon.ipnet}
+	for _, category := range i.categories {
+		lists = append(lists, category.ipnet)
 	}
+	for _, list := range lists {
+		list.SetGuard(options.Guards[list.Name()], options.Quarantine)
+	}
+
+	return i
+}
+
+func orEmpty(ds datasource.DataSource) datasource.DataSource {
+	if ds == nil {
+		return datasource.NewEmptyDataSource()
+	}
+	return ds
 }
 
 // GetInfo returns computed information (Info struct) for given IP address.
+// For IPv6 transition addresses, IPv4 address embedded in them is checked as well and the worse scoring is used.
 // Error is returned on critical condition, everything else is logged with debug level.
 func (i *IP) GetInfo(ip net.IP) (*Info, error) {
+	info, err := i.getInfo(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	embeddedIP, transition := embeddedIPv4(ip)
+	if embeddedIP == nil {
+		return info, nil
+	}
+
+	log.Debugf("[GetInfo] ip: %s %s embedded: %s", ip, transition, embeddedIP)
+	embedded, err := i.getInfo(embeddedIP)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Transition = transition
+	info.Embedded = embedded
+	if embedded.IPScoring < info.IPScoring {
+		info.IPScoring = embedded.IPScoring
+	}
+
+	return info, nil
+}
+
+func (i *IP) getInfo(ip net.IP) (*Info, error) {
 	country, err := i.geoip.getCountry(ip)
 	if err != nil {
 		return nil, err
 	}
 
+	// reverse DNS names are resolved once and shared by crawler, datacenter, proxy and VPN checks
+	hostnames, err := lookupAddrWithTimeout(ip.String(), 500*time.Millisecond)
+	if err != nil {
+		// errors like "no such host" are normal, we don't need to pollute error logs
+		log.Debugf("[getInfo] ip: %s error: %s", ip, err)
+	}
+
 	var g errgroup.Group
 
 	var company string
@@ -68,9 +203,15 @@ func (i *IP) GetInfo(ip net.IP) (*Info, error) {
 		return
 	})
 
-	var isSearch bool
+	var asn uint
+	g.Go(func() (err error) {
+		asn, err = i.geoip.getASN(ip)
+		return
+	})
+
+	var crawlerName string
 	g.Go(func() (err error) {
-		isSearch, err = i.engine.isSearchEngine(ip)
+		crawlerName, err = i.crawler.verify(ip, hostnames)
 		return
 	})
 
@@ -81,14 +222,22 @@ func (i *IP) GetInfo(ip net.IP) (*Info, error) {
 	})
 
 	var isProxy bool
+	var proxyMatch string
 	g.Go(func() (err error) {
-		isProxy, err = i.proxy.isProxy(ip)
+		isProxy, proxyMatch, err = i.proxy.isProxy(ip, hostnames)
 		return
 	})
 
 	var isDC bool
+	var dcMatch string
+	g.Go(func() (err error) {
+		isDC, dcMatch, err = i.dc.isDC(ip, hostnames)
+		return
+	})
+
+	var cloud *datasource.Meta
 	g.Go(func() (err error) {
-		isDC, err = i.dc.isDC(ip)
+		cloud, err = i.dc.cloudRange(ip)
 		return
 	})
 
@@ -100,25 +249,38 @@ func (i *IP) GetInfo(ip net.IP) (*Info, error) {
 
 	var isVpn bool
 	g.Go(func() (err error) {
-		isVpn, err = i.vpn.isVpn(ip)
+		isVpn, err = i.vpn.isVpn(ip, hostnames)
 		return
 	})
 
-	var isPrivateAddr bool
+	var isBogon bool
 	g.Go(func() (err error) {
-		isPrivateAddr = isPrivateIP(ip)
+		isBogon, err = i.bogon.isBogon(ip)
 		return
 	})
 
+	evidence := make([]*Evidence, len(i.categories))
+	for n, category := range i.categories {
+		n, category := n, category
+		g.Go(func() (err error) {
+			evidence[n], err = category.find(ip)
+			return
+		})
+	}
+
 	if err := g.Wait(); err != nil {
 		return nil, err
 	}
 
-	// error here can happen, and it's normal
-	hostnames, _ := lookupAddrWithTimeout(ip.String(), 500*time.Millisecond)
+	if cloud != nil {
+		isDC = true
+	}
+
+	class := addressClass(ip)
+	isPrivateAddr := !ip.IsGlobalUnicast() || isPrivateClass(class)
 
 	// Calculate scoring 0-100 (worst-best)
-	var score uint8 = 86
+	score := 86
 
 	if isProxy {
 		score -= 53
@@ -127,7 +289,7 @@ func (i *IP) GetInfo(ip net.IP) (*Info, error) {
 		score += 2
 	}
 
-	if isSearch {
+	if crawlerName != "" {
 		score++
 	}
 
@@ -147,10 +309,35 @@ func (i *IP) GetInfo(ip net.IP) (*Info, error) {
 		score -= 13
 	}
 
+	if isBogon {
+		score -= 40
+	}
+
 	if len(hostnames) == 0 {
 		score -= 3
 	}
 
+	score += classWeights[class]
+
+	var categories map[string]bool
+	if len(i.categories) > 0 {
+		categories = make(map[string]bool, len(i.categories))
+	}
+	var matched []Evidence
+	for n, category := range i.categories {
+		categories[category.Name] = evidence[n] != nil
+		if evidence[n] != nil {
+			score += category.weight(evidence[n])
+			matched = append(matched, *evidence[n])
+		}
+	}
+
+	var history int
+	if i.history != nil {
+		history = i.history.IP(ip)
+		score += history / 2
+	}
+
 	if isPrivateAddr {
 		score = 0
 	}
@@ -158,102 +345,115 @@ func (i *IP) GetInfo(ip net.IP) (*Info, error) {
 	if score > 100 {
 		score = 100
 	}
+	if score < 0 {
+		score = 0
+	}
+
+	info := &Info{
+		Company:      company,
+		ASN:          asn,
+		Country:      country,
+		IsProxy:      isProxy,
+		IsTor:        isTor,
+		Hostnames:    hostnames,
+		IsPrivate:    isPrivateAddr,
+		AddressClass: class,
+		IsDatacenter: isDC,
+		IsSpam:       isSpam,
+		IsVpn:        isVpn,
+		IsBogon:      isBogon,
+		IPScoring:    uint8(score),
+		Crawler:      crawlerName,
+		LocalHistory: history,
+		Categories:   categories,
+		Evidence:     matched,
+	}
+
+	for _, match := range []string{proxyMatch, dcMatch} {
+		if match != "" {
+			info.Matches = append(info.Matches, match)
+		}
+	}
 
-	return &Info{
-		Company:        company,
-		Country:        country,
-		IsProxy:        isProxy,
-		IsSearchEngine: isSearch,
-		IsTor:          isTor,
-		Hostnames:      hostnames,
-		IsPrivate:      isPrivateAddr,
-		IsDatacenter:   isDC,
-		IsSpam:         isSpam,
-		IsVpn:          isVpn,
-		IPScoring:      score,
-	}, nil
+	if cloud != nil {
+		info.CloudProvider = cloud.Provider
+		info.CloudService = cloud.Service
+		info.CloudRegion = cloud.Region
+	}
+
+	return info, nil
 }
 
 // RunUpdates schedules and runs updates.
-// Update interval is defined for each source individually.
-func (i *IP) RunUpdates() {
+// Update interval is defined for each source individually, local sources are checked for changes every poll interval.
+func (i *IP) RunUpdates(poll time.Duration) {
 	ctx := context.Background()
 
-	runAndSchedule(ctx, 15*time.Minute, func() {
+	fetch.Schedule(ctx, 15*time.Minute, 0, nil, func() {
 		if err := i.tor.update(); err != nil {
 			log.Error(err)
 		}
 	})
 
-	runAndSchedule(ctx, 24*time.Hour, func() {
+	fetch.Schedule(ctx, 24*time.Hour, 0, nil, func() {
 		if err := i.geoip.update(); err != nil {
 			log.Error(err)
 		}
 	})
 
-	runAndSchedule(ctx, 12*time.Hour, func() {
+	fetch.Schedule(ctx, 12*time.Hour, poll, i.proxy.ipnet.Changed, func() {
 		if err := i.proxy.ipnet.Load(); err != nil {
 			log.Error(err)
 		}
 	})
 
-	runAndSchedule(ctx, 12*time.Hour, func() {
+	fetch.Schedule(ctx, 12*time.Hour, poll, i.dc.ipnet.Changed, func() {
 		if err := i.dc.ipnet.Load(); err != nil {
 			log.Error(err)
 		}
 	})
 
-	runAndSchedule(ctx, 12*time.Hour, func() {
-		if err := i.spam.ipnet.Load(); err != nil {
+	fetch.Schedule(ctx, 24*time.Hour, poll, i.dc.cloud.Changed, func() {
+		if err := i.dc.cloud.Load(); err != nil {
 			log.Error(err)
 		}
 	})
 
-	runAndSchedule(ctx, 12*time.Hour, func() {
-		if err := i.vpn.ipnet.Load(); err != nil {
+	fetch.Schedule(ctx, 24*time.Hour, poll, i.crawler.ranges.Changed, func() {
+		if err := i.crawler.ranges.Load(); err != nil {
 			log.Error(err)
 		}
 	})
-}
 
-func runAndSchedule(ctx context.Context, interval time.Duration, f func()) {
-	go func() {
-		t := time.NewTimer(0) // first run - immediately
-		for {
-			select {
-			case <-t.C:
-				f()
-				t = time.NewTimer(interval) // next runs according to the schedule
-			case <-ctx.Done():
-				return
-			}
+	fetch.Schedule(ctx, 12*time.Hour, poll, i.spam.ipnet.Changed, func() {
+		if err := i.spam.ipnet.Load(); err != nil {
+			log.Error(err)
 		}
-	}()
-}
+	})
 
-// isPrivateIP CHeck if IP belongs to private networks
-func isPrivateIP(ip net.IP) bool {
-	// Eliminate by default multicast and loopback for IPv4 and IPv6
-	global := ip.IsGlobalUnicast()
-	if !global {
-		return true
-	}
+	fetch.Schedule(ctx, 12*time.Hour, poll, i.vpn.ipnet.Changed, func() {
+		if err := i.vpn.ipnet.Load(); err != nil {
+			log.Error(err)
+		}
+	})
 
-	privateIPs := []string{
-		"10.0.0.0/8",     // RFC1918
-		"172.16.0.0/12",  // RFC1918
-		"192.168.0.0/16", // RFC1918
-		"::1/128",        // IPv6 loopback
-		"fe80::/10",      // IPv6 link-local
-		"fc00::/7",       // IPv6 unique local addr
+	for _, set := range []*pattern.Set{i.dc.names, i.dc.hosts, i.proxy.hosts} {
+		set.RunUpdates(poll)
 	}
 
-	for _, i := range privateIPs {
-		_, network, _ := net.ParseCIDR(i)
-		if network.Contains(ip) {
-			return true
-		}
+	for _, category := range i.categories {
+		category := category
+		fetch.Schedule(ctx, category.Interval, poll, category.ipnet.Changed, func() {
+			if err := category.ipnet.Load(); err != nil {
+				log.Error(err)
+			}
+		})
 	}
 
-	return false
+	// bogon lists are changing often, as new prefixes are allocated and announced
+	fetch.Schedule(ctx, 4*time.Hour, poll, i.bogon.ipnet.Changed, func() {
+		if err := i.bogon.ipnet.Load(); err != nil {
+			log.Error(err)
+		}
+	})
 }
@@ -8,39 +8,43 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/fetch"
 	"github.com/oschwald/geoip2-golang"
 )
 
 const maxmindDir = "./resources/maxmind/"
 
+// maxmindURL is the URL of MaxMind archives, {edition} is replaced with the edition ID.
+const maxmindURL = "https://download.maxmind.com/app/geoip_download?edition_id={edition}&suffix=tar.gz"
+
 var maxmindFiles = []struct {
-	url  string
-	md5  string
-	file string
-	t    string
+	edition string
+	file    string
+	t       string
 }{
 	{
-		url:  "https://download.maxmind.com/app/geoip_download?edition_id=GeoLite2-ASN&suffix=tar.gz",
-		md5:  "https://download.maxmind.com/app/geoip_download?edition_id=GeoLite2-ASN&suffix=tar.gz.md5",
-		file: "GeoLite2-ASN.mmdb",
-		t:    "asn",
+		edition: "GeoLite2-ASN",
+		file:    "GeoLite2-ASN.mmdb",
+		t:       "asn",
 	},
 	{
-		url:  "https://download.maxmind.com/app/geoip_download?edition_id=GeoLite2-Country&suffix=tar.gz",
-		md5:  "https://download.maxmind.com/app/geoip_download?edition_id=GeoLite2-Country&suffix=tar.gz.md5",
-		file: "GeoLite2-Country.mmdb",
-		t:    "country",
+		edition: "GeoLite2-Country",
+		file:    "GeoLite2-Country.mmdb",
+		t:       "country",
 	},
 }
 
 type maxmind struct {
 	license string
+	// url of a mirror, which replaces MaxMind, archives are verified when the URL is signed (key option)
+	url     string
+	keys    fetch.Keys
 	country *geoip2.Reader
 	asn     *geoip2.Reader
 }
@@ -83,11 +87,24 @@ func (g *maxmind) getCompany(ip net.IP) (string, error) {
 	return asn.AutonomousSystemOrganization, nil
 }
 
+func (g *maxmind) getASN(ip net.IP) (uint, error) {
+	if g.asn == nil {
+		return 0, nil
+	}
+
+	asn, err := g.asn.ASN(ip)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get ASN for: %s , error: %w", ip, err)
+	}
+
+	return asn.AutonomousSystemNumber, nil
+}
+
 func (g *maxmind) update() error {
 	log.Debug("[geoip] update start")
 	defer log.Debug("[geoip] update finished")
 
-	if g.license == "" {
+	if g.license == "" && g.url == "" {
 		log.Debug("[geoip] no license, skip update")
 		return nil
 	}
@@ -97,9 +114,7 @@ func (g *maxmind) update() error {
 	}
 
 	for _, m := range maxmindFiles {
-		license := "&license_key=" + g.license
-
-		if err := g.download(m.url+license, m.md5+license); err != nil {
+		if err := g.download(m.edition); err != nil {
 			return err
 		}
 
@@ -120,17 +135,41 @@ func (g *maxmind) update() error {
 	return nil
 }
 
-func (g *maxmind) download(url string, md5Url string) error {
-	response, err := defaultHTTPClient.Get(url)
-	if err != nil {
-		return fmt.Errorf("cannot download url: %s, error: %w", url, err)
-	}
-	defer response.Body.Close()
+// download gets the archive of the edition, checks it and extracts it. Archives from MaxMind are checked with md5 sum,
+// archives from the mirror with the signature, when the mirror is signed, the last verified archive is used,
+// when the signature is invalid. Files are extracted only after the archive is checked.
+func (g *maxmind) download(edition string) error {
+	var url string
+	var archive []byte
+	var err error
+
+	if g.url == "" {
+		url = strings.Replace(maxmindURL, "{edition}", edition, -1)
+		license := "&license_key=" + g.license
 
-	var md5FileResponse bytes.Buffer
-	body := io.TeeReader(response.Body, &md5FileResponse)
+		if archive, err = fetch.Get(defaultHTTPClient, url+license, nil); err != nil {
+			return fmt.Errorf("cannot download url: %s, error: %w", url, err)
+		}
+
+		md5CheckResponse, err := fetch.Get(defaultHTTPClient, url+".md5"+license, nil)
+		if err != nil {
+			return fmt.Errorf("can read md5 sum from url: %s, error: %w", url+".md5", err)
+		}
+
+		md5File := fmt.Sprintf("%x", md5.Sum(archive)) // #nosec
+		md5Checksum := string(md5CheckResponse)
+		if md5Checksum != md5File {
+			return fmt.Errorf("url: %s, md5(file): %s, md5(checksum): %s error: %w",
+				url, md5File, md5Checksum, errors.New("invalid md5 checksum"))
+		}
+	} else {
+		url = strings.Replace(g.url, "{edition}", edition, -1)
+		if archive, err = fetch.Get(defaultHTTPClient, url, g.keys); err != nil {
+			return fmt.Errorf("cannot download url: %s, error: %w", url, err)
+		}
+	}
 
-	gzr, err := gzip.NewReader(body)
+	gzr, err := gzip.NewReader(bytes.NewReader(archive))
 	if err != nil {
 		return fmt.Errorf("cannot open GZIP reader url: %s, error: %w", url, err)
 	}
@@ -167,23 +206,5 @@ func (g *maxmind) download(url string, md5Url string) error {
 		}
 	}
 
-	response, err = defaultHTTPClient.Get(md5Url)
-	if err != nil {
-		return fmt.Errorf("cannot download url: %s, error: %w", md5Url, err)
-	}
-	defer response.Body.Close()
-
-	md5CheckResponse, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return fmt.Errorf("can read md5 sum from url: %s, error: %w", md5Url, err)
-	}
-
-	md5File := fmt.Sprintf("%x", md5.Sum(md5FileResponse.Bytes())) // #nosec
-	md5Checksum := string(md5CheckResponse)
-	if md5Checksum != md5File {
-		return fmt.Errorf("url: %s, md5(file): %s, md5(checksum): %s error: %w",
-			url, md5File, md5Checksum, errors.New("invalid md5 checksum"))
-	}
-
 	return nil
 }
@@ -2,41 +2,44 @@ package ip
 
 import (
 	"net"
-	"regexp"
-	"time"
 
 	"github.com/labstack/gommon/log"
 	"github.com/optimatiq/threatbite/ip/datasource"
+	"github.com/optimatiq/threatbite/pattern"
 )
 
 type proxy struct {
 	ipnet *datasource.IPNet
+	hosts *pattern.Set
 }
 
-func newProxy(source datasource.DataSource) *proxy {
-	return &proxy{ipnet: datasource.NewIPNet(source, "proxy")}
+func newProxy(source datasource.DataSource, hosts *pattern.Set) *proxy {
+	return &proxy{ipnet: datasource.NewIPNet(source, "proxy"), hosts: hosts}
 }
 
-var reIsProxy = regexp.MustCompile("proxy|sock|anon")
+// NewProxyHosts returns patterns of reverse DNS names used by proxies, built-in patterns are used
+// until lists set with SetSources are loaded.
+func NewProxyHosts() *pattern.Set {
+	return pattern.NewSet("proxy_hosts", []string{"proxy", "sock", "anon"})
+}
 
 // isProxy check if IP belongs to proxy list or have defined string in reverse name
-func (p *proxy) isProxy(ip net.IP) (bool, error) {
+// Pattern, which was matched, is returned in form of "set:pattern", it's empty for list match.
+func (p *proxy) isProxy(ip net.IP, hostnames []string) (bool, string, error) {
 	isProxy, err := p.ipnet.Check(ip)
 	if isProxy {
 		log.Debugf("[isProxy] ip: %s tor: %t", ip, isProxy)
-		return isProxy, err
+		return isProxy, "", err
 	}
 
-	reverse, err := lookupAddrWithTimeout(ip.String(), 500*time.Millisecond)
-	if err != nil {
-		// errors like "no such host" are normal, we don't need to pollute error logs
-		log.Debugf("[isProxy] ip: %s error: %s", ip, err)
-		return false, nil
+	if len(hostnames) == 0 {
+		return false, "", nil
 	}
 
-	if reIsProxy.MatchString(reverse[0]) {
-		return true, nil
+	if match, ok := p.hosts.Match(hostnames[0]); ok {
+		log.Debugf("[isProxy] ip: %s host: %s pattern: %s", ip, hostnames[0], match)
+		return true, p.hosts.Name() + ":" + match, nil
 	}
 
-	return false, nil
+	return false, "", nil
 }
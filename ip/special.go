@@ -0,0 +1,159 @@
+package ip
+
+import (
+	"net"
+
+	"github.com/asergeyev/nradix"
+)
+
+// Address classes of special-purpose addresses.
+// Classification is based on IANA IPv4 and IPv6 Special-Purpose Address Registries
+// https://www.iana.org/assignments/iana-ipv4-special-registry/
+// https://www.iana.org/assignments/iana-ipv6-special-registry/
+const (
+	ClassPrivate       = "private"
+	ClassShared        = "shared"
+	ClassLoopback      = "loopback"
+	ClassLinkLocal     = "link-local"
+	ClassUnspecified   = "unspecified"
+	ClassBroadcast     = "broadcast"
+	ClassMulticast     = "multicast"
+	ClassDocumentation = "documentation"
+	ClassBenchmarking  = "benchmarking"
+	ClassReserved      = "reserved"
+	ClassProtocol      = "protocol"
+	ClassAnycast       = "anycast"
+	ClassDiscard       = "discard"
+	ClassOrchid        = "orchid"
+	ClassTranslation   = "translation"
+	ClassTransition    = "transition"
+)
+
+// specialRanges are compiled once into radix trees, the lookup picks the most specific range.
+// IPv4-mapped addresses (::ffff:0:0/96) are not listed, net.IP keeps IPv4 addresses in this form,
+// so they are classified by the IPv4 part of the registry.
+var specialRanges = compileSpecialRanges([]struct {
+	cidr  string
+	class string
+	name  string
+}{
+	// IPv4
+	{"0.0.0.0/8", ClassReserved, "This network"},
+	{"0.0.0.0/32", ClassUnspecified, "This host on this network"},
+	{"10.0.0.0/8", ClassPrivate, "Private-Use"},
+	{"100.64.0.0/10", ClassShared, "Shared Address Space"},
+	{"127.0.0.0/8", ClassLoopback, "Loopback"},
+	{"169.254.0.0/16", ClassLinkLocal, "Link Local"},
+	{"172.16.0.0/12", ClassPrivate, "Private-Use"},
+	{"192.0.0.0/24", ClassProtocol, "IETF Protocol Assignments"},
+	{"192.0.0.0/29", ClassProtocol, "IPv4 Service Continuity Prefix"},
+	{"192.0.0.8/32", ClassProtocol, "IPv4 dummy address"},
+	{"192.0.0.9/32", ClassAnycast, "Port Control Protocol Anycast"},
+	{"192.0.0.10/32", ClassAnycast, "Traversal Using Relays around NAT Anycast"},
+	{"192.0.0.170/32", ClassProtocol, "NAT64/DNS64 Discovery"},
+	{"192.0.0.171/32", ClassProtocol, "NAT64/DNS64 Discovery"},
+	{"192.0.2.0/24", ClassDocumentation, "Documentation (TEST-NET-1)"},
+	{"192.31.196.0/24", ClassAnycast, "AS112-v4"},
+	{"192.52.193.0/24", ClassAnycast, "AMT"},
+	{"192.88.99.0/24", ClassAnycast, "Deprecated (6to4 Relay Anycast)"},
+	{"192.168.0.0/16", ClassPrivate, "Private-Use"},
+	{"192.175.48.0/24", ClassAnycast, "Direct Delegation AS112 Service"},
+	{"198.18.0.0/15", ClassBenchmarking, "Benchmarking"},
+	{"198.51.100.0/24", ClassDocumentation, "Documentation (TEST-NET-2)"},
+	{"203.0.113.0/24", ClassDocumentation, "Documentation (TEST-NET-3)"},
+	{"224.0.0.0/4", ClassMulticast, "Multicast"},
+	{"240.0.0.0/4", ClassReserved, "Reserved"},
+	{"255.255.255.255/32", ClassBroadcast, "Limited Broadcast"},
+
+	// IPv6
+	{"::/128", ClassUnspecified, "Unspecified Address"},
+	{"::1/128", ClassLoopback, "Loopback Address"},
+	{"64:ff9b::/96", ClassTranslation, "IPv4-IPv6 Translat."},
+	{"64:ff9b:1::/48", ClassTranslation, "IPv4-IPv6 Translat."},
+	{"100::/64", ClassDiscard, "Discard-Only Address Block"},
+	{"2001::/23", ClassProtocol, "IETF Protocol Assignments"},
+	{"2001::/32", ClassTransition, "TEREDO"},
+	{"2001:1::1/128", ClassAnycast, "Port Control Protocol Anycast"},
+	{"2001:1::2/128", ClassAnycast, "Traversal Using Relays around NAT Anycast"},
+	{"2001:1::3/128", ClassAnycast, "DNS-SD Service Registration Protocol Anycast"},
+	{"2001:2::/48", ClassBenchmarking, "Benchmarking"},
+	{"2001:3::/32", ClassAnycast, "AMT"},
+	{"2001:4:112::/48", ClassAnycast, "AS112-v6"},
+	{"2001:10::/28", ClassOrchid, "Deprecated (previously ORCHID)"},
+	{"2001:20::/28", ClassOrchid, "ORCHIDv2"},
+	{"2001:30::/28", ClassProtocol, "Drone Remote ID Protocol Entity Tags"},
+	{"2001:db8::/32", ClassDocumentation, "Documentation"},
+	{"2002::/16", ClassTransition, "6to4"},
+	{"2620:4f:8000::/48", ClassAnycast, "Direct Delegation AS112 Service"},
+	{"3fff::/20", ClassDocumentation, "Documentation"},
+	{"5f00::/16", ClassReserved, "Segment Routing (SRv6) SIDs"},
+	{"fc00::/7", ClassPrivate, "Unique-Local"},
+	{"fe80::/10", ClassLinkLocal, "Link-Local Unicast"},
+	{"ff00::/8", ClassMulticast, "Multicast"},
+})
+
+// classWeights defines how the class of the address affects scoring of the IP.
+// Classes not listed here don't change the scoring, private classes always set it to 0.
+var classWeights = map[string]int{
+	ClassShared:        -30,
+	ClassProtocol:      -30,
+	ClassOrchid:        -20,
+	ClassDocumentation: -50,
+	ClassBenchmarking:  -50,
+	ClassReserved:      -50,
+	ClassDiscard:       -50,
+}
+
+// specialTrees contains separate radix trees for IPv4 and IPv6 ranges, values are classes of the ranges.
+type specialTrees struct {
+	v4 *nradix.Tree
+	v6 *nradix.Tree
+}
+
+func compileSpecialRanges(ranges []struct {
+	cidr  string
+	class string
+	name  string
+}) specialTrees {
+	trees := specialTrees{v4: nradix.NewTree(0), v6: nradix.NewTree(0)}
+	for _, r := range ranges {
+		_, network, err := net.ParseCIDR(r.cidr)
+		if err != nil {
+			panic("invalid special-purpose range: " + r.cidr)
+		}
+		if err := trees.tree(network.IP).AddCIDR(network.String(), r.class); err != nil {
+			panic("duplicated special-purpose range: " + r.cidr)
+		}
+	}
+	return trees
+}
+
+// tree returns radix tree for the IP version of given address.
+func (t specialTrees) tree(ip net.IP) *nradix.Tree {
+	if ip.To4() != nil {
+		return t.v4
+	}
+	return t.v6
+}
+
+// addressClass returns class of the most specific special-purpose range, which contains IP.
+// Empty string is returned for regular global unicast addresses.
+func addressClass(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	class, err := specialRanges.tree(ip).FindCIDR(ip.String())
+	if err != nil || class == nil {
+		return ""
+	}
+	return class.(string)
+}
+
+// isPrivateClass returns true for classes, which can't be a source of the traffic coming from the internet.
+func isPrivateClass(class string) bool {
+	switch class {
+	case ClassPrivate, ClassLoopback, ClassLinkLocal, ClassUnspecified, ClassBroadcast, ClassMulticast:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package ip
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_addressClass(t *testing.T) {
+	tests := map[string]string{
+		"1.1.1.1":          "",
+		"8.8.8.8":          "",
+		"10.1.2.3":         ClassPrivate,
+		"172.31.255.255":   ClassPrivate,
+		"192.168.1.1":      ClassPrivate,
+		"100.64.0.1":       ClassShared,
+		"100.127.255.254":  ClassShared,
+		"100.128.0.1":      "",
+		"127.0.0.1":        ClassLoopback,
+		"169.254.169.254":  ClassLinkLocal,
+		"0.0.0.0":          ClassUnspecified,
+		"0.1.2.3":          ClassReserved,
+		"192.0.0.1":        ClassProtocol,
+		"192.0.0.9":        ClassAnycast,
+		"192.0.2.15":       ClassDocumentation,
+		"198.51.100.7":     ClassDocumentation,
+		"203.0.113.200":    ClassDocumentation,
+		"198.18.0.1":       ClassBenchmarking,
+		"198.19.255.1":     ClassBenchmarking,
+		"192.88.99.1":      ClassAnycast,
+		"224.0.0.251":      ClassMulticast,
+		"240.0.0.1":        ClassReserved,
+		"255.255.255.255":  ClassBroadcast,
+		"::":               ClassUnspecified,
+		"::1":              ClassLoopback,
+		"::ffff:10.0.0.1":  ClassPrivate,
+		"64:ff9b::8.8.8.8": ClassTranslation,
+		"100::1":           ClassDiscard,
+		"2001::1":          ClassTransition,
+		"2001:2::1":        ClassBenchmarking,
+		"2001:1::1":        ClassAnycast,
+		"2001:db8::1":      ClassDocumentation,
+		"2002:808:808::1":  ClassTransition,
+		"fd00::1":          ClassPrivate,
+		"fe80::1":          ClassLinkLocal,
+		"ff02::1":          ClassMulticast,
+		"2a00:1450::1":     "",
+	}
+
+	for ip, class := range tests {
+		assert.Equal(t, class, addressClass(net.ParseIP(ip)), ip)
+	}
+}
+
+func Test_isPrivateClass(t *testing.T) {
+	tests := map[string]bool{
+		"1.1.1.1":         false,
+		"255.255.255.255": true,
+		"8.8.8.8":         false,
+		"127.0.0.1":       true,
+		"192.168.10.10":   true,
+		"10.0.0.1":        true,
+		"123.123.123.12":  false,
+		"100.64.0.1":      false,
+		"192.0.2.1":       false,
+		"224.0.0.1":       true,
+		"0.0.0.0":         true,
+		"fd12:3456::1":    true,
+		"fe80::1":         true,
+		"ff02::1":         true,
+		"::1":             true,
+		"2001:db8::1":     false,
+	}
+
+	for ip, v := range tests {
+		assert.Equal(t, v, isPrivateClass(addressClass(net.ParseIP(ip))), ip)
+	}
+}
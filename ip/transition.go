@@ -0,0 +1,58 @@
+package ip
+
+import (
+	"net"
+)
+
+// Transition mechanisms, which carry IPv4 address inside IPv6 address.
+// IPv4-mapped addresses (::ffff:0:0/96) are not listed here, net.IP keeps every IPv4 address in this form,
+// so they are already checked as IPv4 addresses.
+const (
+	Transition6to4   = "6to4"
+	TransitionTeredo = "teredo"
+	TransitionNAT64  = "nat64"
+)
+
+var (
+	prefix6to4   = mustParseCIDR("2002::/16")
+	prefixTeredo = mustParseCIDR("2001::/32")
+	prefixNAT64  = mustParseCIDR("64:ff9b::/96")
+	// local-use prefix, RFC 8215
+	prefixNAT64Local = mustParseCIDR("64:ff9b:1::/48")
+)
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic("invalid CIDR: " + cidr)
+	}
+	return network
+}
+
+// embeddedIPv4 extracts IPv4 address from IPv6 transition address.
+// Returns nil and empty mechanism name, when IP is not a transition address.
+func embeddedIPv4(ip net.IP) (net.IP, string) {
+	if ip.To4() != nil {
+		return nil, ""
+	}
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return nil, ""
+	}
+
+	switch {
+	case prefix6to4.Contains(ip16):
+		// 2002:AABB:CCDD::/48 where AABBCCDD is IPv4 address of the site
+		return net.IPv4(ip16[2], ip16[3], ip16[4], ip16[5]), Transition6to4
+	case prefixTeredo.Contains(ip16):
+		// 2001:0000:SSSS:SSSS:FFFF:PPPP:CCCC:CCCC, where S is the Teredo server address,
+		// C is the obfuscated (all bits inverted) public address of the client.
+		return net.IPv4(^ip16[12], ^ip16[13], ^ip16[14], ^ip16[15]), TransitionTeredo
+	case prefixNAT64.Contains(ip16), prefixNAT64Local.Contains(ip16):
+		// 64:ff9b::AABB:CCDD well-known prefix, RFC 6052. Networks of the local-use prefix 64:ff9b:1::/48
+		// can be of any length allowed by RFC 6052, which isn't known here, so the most common /96 is assumed.
+		return net.IPv4(ip16[12], ip16[13], ip16[14], ip16[15]), TransitionNAT64
+	}
+
+	return nil, ""
+}
@@ -0,0 +1,40 @@
+package ip
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_embeddedIPv4(t *testing.T) {
+	tests := []struct {
+		ip         string
+		want       string
+		transition string
+	}{
+		{"2002:c000:204::1", "192.0.2.4", Transition6to4},
+		{"2002:0808:0808:1::1", "8.8.8.8", Transition6to4},
+		// RFC 4380 example: server 65.54.227.120, client 192.0.2.45 port 40000
+		{"2001:0:4136:e378:8000:63bf:3fff:fdd2", "192.0.2.45", TransitionTeredo},
+		{"64:ff9b::808:808", "8.8.8.8", TransitionNAT64},
+		{"64:ff9b::192.0.2.33", "192.0.2.33", TransitionNAT64},
+		{"64:ff9b:1::808:808", "8.8.8.8", TransitionNAT64},
+		{"64:ff9b:1:abcd::c000:221", "192.0.2.33", TransitionNAT64},
+		{"64:ff9b:2::808:808", "", ""},
+		{"::ffff:8.8.8.8", "", ""},
+		{"8.8.8.8", "", ""},
+		{"2a00:1450:4001:82a::200e", "", ""},
+		{"2001:db8::1", "", ""},
+	}
+
+	for _, tt := range tests {
+		embedded, transition := embeddedIPv4(net.ParseIP(tt.ip))
+		assert.Equal(t, tt.transition, transition, tt.ip)
+		if tt.want == "" {
+			assert.Nil(t, embedded, tt.ip)
+		} else {
+			assert.Equal(t, tt.want, embedded.String(), tt.ip)
+		}
+	}
+}
@@ -11,7 +11,8 @@ import (
 
 func Test_vpn_isVpn(t *testing.T) {
 	type args struct {
-		ip net.IP
+		ip        net.IP
+		hostnames []string
 	}
 	tests := []struct {
 		name    string
@@ -44,6 +45,15 @@ func Test_vpn_isVpn(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			name: "reverse name",
+			list: []string{"192.168.0.0/24"},
+			args: args{
+				ip:        net.ParseIP("191.168.0.1"),
+				hostnames: []string{"nl-ams-ovudp-01.example.net"},
+			},
+			want: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -55,7 +65,7 @@ func Test_vpn_isVpn(t *testing.T) {
 			err = v.ipnet.Load()
 			assert.NoError(t, err)
 
-			got, err := v.isVpn(tt.args.ip)
+			got, err := v.isVpn(tt.args.ip, tt.args.hostnames)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("isVpn() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -0,0 +1,449 @@
+// Package locallist keeps named allow and deny lists managed at runtime.
+// Entries are IP addresses, CIDR networks, ASNs (AS64500), email domains (with subdomains) and full email addresses.
+// Lists are persisted in JSON file on every change and take effect immediately.
+package locallist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/asergeyev/nradix"
+	"github.com/labstack/gommon/log"
+)
+
+// Types of lists, allow lists take precedence over deny lists.
+const (
+	TypeAllow = "allow"
+	TypeDeny  = "deny"
+)
+
+// ErrUnknownList is returned for lists, which don't exist.
+var ErrUnknownList = errors.New("unknown list")
+
+// ErrInvalidName is returned for invalid list names.
+var ErrInvalidName = errors.New("invalid list name, expected 1-64 characters: a-z, 0-9, _ or -")
+
+// ErrInvalidType is returned for list types other than allow and deny.
+var ErrInvalidType = errors.New("invalid list type, expected allow or deny")
+
+// ErrInvalidEntry is returned for entries, which are not IP address, CIDR, ASN, email domain or address.
+var ErrInvalidEntry = errors.New("invalid entry, expected IP address, CIDR, ASN, email domain or address")
+
+var reName = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+var reDomain = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// List is a named list of entries.
+type List struct {
+	Type    string   `json:"type"`
+	Entries []string `json:"entries"`
+}
+
+// Match is a list, which contains checked IP address, ASN or email, empty Type means no match.
+type Match struct {
+	List string
+	Type string
+}
+
+// index contains parsed entries of lists of the same type, values are names of lists.
+// IPv4 and IPv6 networks are kept in separate radix trees, so the most specific network matches.
+// When the same entry is on several lists, the first list by name is used.
+type index struct {
+	nets4   *nradix.Tree
+	nets6   *nradix.Tree
+	asns    map[uint]string
+	domains map[string]string
+	emails  map[string]string
+}
+
+func newIndex() *index {
+	return &index{
+		nets4:   nradix.NewTree(0),
+		nets6:   nradix.NewTree(0),
+		asns:    map[uint]string{},
+		domains: map[string]string{},
+		emails:  map[string]string{},
+	}
+}
+
+// add adds normalized entry to the index, entries already added by other lists are skipped.
+func (x *index) add(entry, name string) {
+	switch {
+	case strings.Contains(entry, "@"):
+		if _, ok := x.emails[entry]; !ok {
+			x.emails[entry] = name
+		}
+	case strings.Contains(entry, "/"):
+		_, network, _ := net.ParseCIDR(entry)
+		// ErrNodeBusy means that the network is already on other list
+		_ = x.tree(network.IP).AddCIDR(network.String(), name)
+	case strings.HasPrefix(entry, "AS"):
+		asn, _ := strconv.ParseUint(entry[2:], 10, 32)
+		if _, ok := x.asns[uint(asn)]; !ok {
+			x.asns[uint(asn)] = name
+		}
+	default:
+		if _, ok := x.domains[entry]; !ok {
+			x.domains[entry] = name
+		}
+	}
+}
+
+// tree returns radix tree for the IP version of given address.
+func (x *index) tree(ip net.IP) *nradix.Tree {
+	if ip.To4() != nil {
+		return x.nets4
+	}
+	return x.nets6
+}
+
+func (x *index) match(ip net.IP, asn uint, email string) string {
+	if ip != nil {
+		if name, err := x.tree(ip).FindCIDR(ip.String()); err == nil && name != nil {
+			return name.(string)
+		}
+	}
+	if asn != 0 {
+		if name, ok := x.asns[asn]; ok {
+			return name
+		}
+	}
+	if email == "" {
+		return ""
+	}
+	email = strings.ToLower(email)
+	if name, ok := x.emails[email]; ok {
+		return name
+	}
+	// domain matches its subdomains as well
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for domain != "" {
+		if name, ok := x.domains[domain]; ok {
+			return name
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+	return ""
+}
+
+// Store contains local lists.
+type Store struct {
+	mu       sync.RWMutex
+	filename string
+	lists    map[string]*List
+	allow    *index
+	deny     *index
+}
+
+// NewStore creates a store, lists are loaded from the file if it exists.
+// Empty filename means that lists are kept only in memory.
+func NewStore(filename string) (*Store, error) {
+	s := &Store{
+		filename: filename,
+		lists:    map[string]*List{},
+	}
+
+	if filename != "" {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("cannot read local lists file: %s, error: %w", filename, err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &s.lists); err != nil {
+				return nil, fmt.Errorf("cannot parse local lists file: %s, error: %w", filename, err)
+			}
+		}
+	}
+
+	for name, list := range s.lists {
+		if err := validate(name, list); err != nil {
+			return nil, fmt.Errorf("invalid local list: %s, error: %w", name, err)
+		}
+	}
+	s.reindex()
+	return s, nil
+}
+
+// Lists returns copy of all lists.
+func (s *Store) Lists() map[string]List {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	lists := make(map[string]List, len(s.lists))
+	for name, list := range s.lists {
+		lists[name] = copyList(list)
+	}
+	return lists
+}
+
+// Get returns copy of the list.
+func (s *Store) Get(name string) (List, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, ok := s.lists[name]
+	if !ok {
+		return List{}, ErrUnknownList
+	}
+	return copyList(list), nil
+}
+
+// Put creates the list or changes type of the existing one, entries are kept.
+func (s *Store) Put(name, listType string) error {
+	if !reName.MatchString(name) {
+		return ErrInvalidName
+	}
+	if listType != TypeAllow && listType != TypeDeny {
+		return ErrInvalidType
+	}
+
+	return s.update(func() error {
+		if list, ok := s.lists[name]; ok {
+			list.Type = listType
+		} else {
+			s.lists[name] = &List{Type: listType, Entries: []string{}}
+		}
+		return nil
+	})
+}
+
+// Delete removes the list.
+func (s *Store) Delete(name string) error {
+	return s.update(func() error {
+		if _, ok := s.lists[name]; !ok {
+			return ErrUnknownList
+		}
+		delete(s.lists, name)
+		return nil
+	})
+}
+
+// Add adds entries to the list, all entries are validated before the list is changed.
+func (s *Store) Add(name string, entries []string) error {
+	normalized, err := normalizeAll(entries)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func() error {
+		list, ok := s.lists[name]
+		if !ok {
+			return ErrUnknownList
+		}
+		list.Entries = merge(list.Entries, normalized, true)
+		return nil
+	})
+}
+
+// Remove removes entries from the list, entries, which are not on the list, are ignored.
+func (s *Store) Remove(name string, entries []string) error {
+	normalized, err := normalizeAll(entries)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func() error {
+		list, ok := s.lists[name]
+		if !ok {
+			return ErrUnknownList
+		}
+		list.Entries = merge(list.Entries, normalized, false)
+		return nil
+	})
+}
+
+// Match returns the list, which contains IP address, ASN or email (empty values are skipped).
+// Allow lists are checked first. It's safe to call it on nil Store.
+func (s *Store) Match(ip net.IP, asn uint, email string) Match {
+	if s == nil {
+		return Match{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if name := s.allow.match(ip, asn, email); name != "" {
+		return Match{List: name, Type: TypeAllow}
+	}
+	if name := s.deny.match(ip, asn, email); name != "" {
+		return Match{List: name, Type: TypeDeny}
+	}
+	return Match{}
+}
+
+// update changes lists, rebuilds index and saves the file. Changes are reverted when they cannot be saved.
+func (s *Store) update(change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := make(map[string]*List, len(s.lists))
+	for name, list := range s.lists {
+		l := copyList(list)
+		previous[name] = &l
+	}
+
+	if err := change(); err != nil {
+		s.lists = previous
+		return err
+	}
+	if err := s.save(); err != nil {
+		s.lists = previous
+		return err
+	}
+	s.reindex()
+	log.Debugf("[locallist] lists: %d saved", len(s.lists))
+	return nil
+}
+
+func (s *Store) reindex() {
+	names := make([]string, 0, len(s.lists))
+	for name := range s.lists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s.allow, s.deny = newIndex(), newIndex()
+	for _, name := range names {
+		list := s.lists[name]
+		x := s.deny
+		if list.Type == TypeAllow {
+			x = s.allow
+		}
+		for _, entry := range list.Entries {
+			x.add(entry, name)
+		}
+	}
+}
+
+// save writes lists to the file, the file is replaced atomically, so it's never left partially written.
+func (s *Store) save() error {
+	if s.filename == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.lists, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode local lists, error: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.filename), filepath.Base(s.filename)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot save local lists file: %s, error: %w", s.filename, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot save local lists file: %s, error: %w", s.filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot save local lists file: %s, error: %w", s.filename, err)
+	}
+	if err := os.Rename(tmp.Name(), s.filename); err != nil {
+		return fmt.Errorf("cannot save local lists file: %s, error: %w", s.filename, err)
+	}
+	return nil
+}
+
+// Normalize returns canonical form of the entry: IP address or network in CIDR notation, ASN as AS64500,
+// lowercase email domain or address. Error is returned for invalid entries.
+func Normalize(entry string) (string, error) {
+	entry = strings.TrimSpace(entry)
+
+	if ip := net.ParseIP(entry); ip != nil {
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return (&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}).String(), nil
+	}
+	if _, network, err := net.ParseCIDR(entry); err == nil {
+		return network.String(), nil
+	}
+
+	if len(entry) > 2 && strings.EqualFold(entry[:2], "AS") {
+		if asn, err := strconv.ParseUint(entry[2:], 10, 32); err == nil && asn > 0 {
+			return "AS" + strconv.FormatUint(asn, 10), nil
+		}
+	}
+
+	entry = strings.TrimSuffix(strings.ToLower(entry), ".")
+	domain := entry
+	if i := strings.LastIndex(entry, "@"); i >= 0 {
+		if i == 0 || strings.ContainsAny(entry[:i], " @") {
+			return "", fmt.Errorf("%w: %s", ErrInvalidEntry, entry)
+		}
+		domain = entry[i+1:]
+	}
+	if len(domain) > 253 || !reDomain.MatchString(domain) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidEntry, entry)
+	}
+	return entry, nil
+}
+
+func normalizeAll(entries []string) ([]string, error) {
+	normalized := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		n, err := Normalize(entry)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, n)
+	}
+	return normalized, nil
+}
+
+// merge adds or removes entries and returns sorted entries without duplicates.
+func merge(entries, changes []string, add bool) []string {
+	set := map[string]bool{}
+	for _, entry := range entries {
+		set[entry] = true
+	}
+	for _, entry := range changes {
+		set[entry] = add
+	}
+
+	merged := []string{}
+	for entry, ok := range set {
+		if ok {
+			merged = append(merged, entry)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+func validate(name string, list *List) error {
+	if !reName.MatchString(name) {
+		return ErrInvalidName
+	}
+	if list == nil || (list.Type != TypeAllow && list.Type != TypeDeny) {
+		return ErrInvalidType
+	}
+	for i, entry := range list.Entries {
+		n, err := Normalize(entry)
+		if err != nil {
+			return err
+		}
+		list.Entries[i] = n
+	}
+	return nil
+}
+
+func copyList(list *List) List {
+	return List{Type: list.Type, Entries: append([]string{}, list.Entries...)}
+}
@@ -0,0 +1,131 @@
+package locallist
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		entry   string
+		want    string
+		wantErr bool
+	}{
+		{"192.0.2.1", "192.0.2.1/32", false},
+		{" 192.0.2.7/24 ", "192.0.2.0/24", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"as64500", "AS64500", false},
+		{"Example.COM.", "example.com", false},
+		{"User@Example.com", "user@example.com", false},
+		{"AS0", "", true},
+		{"@example.com", "", true},
+		{"192.0.2.1/33", "", true},
+		{"not a domain", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			got, err := Normalize(tt.entry)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "locallist")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "lists.json")
+
+	s, err := NewStore(filename)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ErrInvalidName, s.Put("Partners!", TypeAllow))
+	assert.Equal(t, ErrInvalidType, s.Put("partners", "block"))
+	assert.Equal(t, ErrUnknownList, s.Add("partners", []string{"192.0.2.1"}))
+
+	assert.NoError(t, s.Put("partners", TypeAllow))
+	assert.NoError(t, s.Put("fraud", TypeDeny))
+	assert.NoError(t, s.Add("partners", []string{"192.0.2.10", "partner.example"}))
+	assert.NoError(t, s.Add("fraud", []string{"192.0.2.0/24", "AS64500", "bad@example.com"}))
+	assert.Error(t, s.Add("fraud", []string{"198.51.100.1", "invalid entry"}))
+
+	assert.Equal(t, Match{List: "partners", Type: TypeAllow}, s.Match(net.ParseIP("192.0.2.10"), 0, ""))
+	assert.Equal(t, Match{List: "fraud", Type: TypeDeny}, s.Match(net.ParseIP("192.0.2.11"), 0, ""))
+	assert.Equal(t, Match{List: "fraud", Type: TypeDeny}, s.Match(net.ParseIP("198.51.100.1"), 64500, ""))
+	assert.Equal(t, Match{List: "fraud", Type: TypeDeny}, s.Match(nil, 0, "BAD@example.com"))
+	assert.Equal(t, Match{List: "partners", Type: TypeAllow}, s.Match(nil, 0, "user@mail.partner.example"))
+	assert.Equal(t, Match{}, s.Match(net.ParseIP("198.51.100.1"), 64501, "user@example.com"))
+
+	// allow list takes precedence
+	assert.Equal(t, Match{List: "partners", Type: TypeAllow}, s.Match(net.ParseIP("192.0.2.11"), 0, "user@partner.example"))
+
+	// lists are persisted
+	loaded, err := NewStore(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, s.Lists(), loaded.Lists())
+
+	assert.NoError(t, s.Remove("fraud", []string{"192.0.2.0/24", "192.0.2.1"}))
+	assert.Equal(t, Match{}, s.Match(net.ParseIP("192.0.2.11"), 0, ""))
+	list, err := s.Get("fraud")
+	assert.NoError(t, err)
+	assert.Equal(t, List{Type: TypeDeny, Entries: []string{"AS64500", "bad@example.com"}}, list)
+
+	assert.NoError(t, s.Put("fraud", TypeAllow))
+	assert.Equal(t, Match{List: "fraud", Type: TypeAllow}, s.Match(nil, 64500, ""))
+
+	assert.NoError(t, s.Delete("fraud"))
+	assert.Equal(t, ErrUnknownList, s.Delete("fraud"))
+	_, err = s.Get("fraud")
+	assert.Equal(t, ErrUnknownList, err)
+
+	var disabled *Store
+	assert.Equal(t, Match{}, disabled.Match(net.ParseIP("192.0.2.1"), 0, ""))
+}
+
+func TestStoreMostSpecific(t *testing.T) {
+	s, err := NewStore("")
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("networks", TypeDeny))
+	assert.NoError(t, s.Put("hosts", TypeDeny))
+	assert.NoError(t, s.Put("abuse", TypeDeny))
+	assert.NoError(t, s.Add("networks", []string{"192.0.0.0/16", "2001:db8::/32"}))
+	assert.NoError(t, s.Add("hosts", []string{"192.0.2.0/24", "192.0.2.1"}))
+	assert.NoError(t, s.Add("abuse", []string{"192.0.2.1"}))
+
+	assert.Equal(t, Match{List: "networks", Type: TypeDeny}, s.Match(net.ParseIP("192.0.1.1"), 0, ""))
+	assert.Equal(t, Match{List: "hosts", Type: TypeDeny}, s.Match(net.ParseIP("192.0.2.2"), 0, ""))
+	assert.Equal(t, Match{List: "networks", Type: TypeDeny}, s.Match(net.ParseIP("2001:db8::1"), 0, ""))
+	assert.Equal(t, Match{}, s.Match(net.ParseIP("32.1.13.184"), 0, ""))
+
+	// the same entry on several lists matches the first list by name
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, Match{List: "abuse", Type: TypeDeny}, s.Match(net.ParseIP("192.0.2.1"), 0, ""))
+	}
+}
+
+func TestNewStoreInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "locallist")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "lists.json")
+
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`{"fraud": {"type": "deny", "entries": ["invalid entry"]}}`), 0600))
+	_, err = NewStore(filename)
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`{`), 0600))
+	_, err = NewStore(filename)
+	assert.Error(t, err)
+}
@@ -0,0 +1,142 @@
+// Package pattern provides lists of case-insensitive patterns, which can be updated from remote sources.
+package pattern
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	aho "github.com/BobuSumisu/aho-corasick"
+	"github.com/labstack/gommon/log"
+	"github.com/optimatiq/threatbite/fetch"
+)
+
+// Set is a list of substrings matched with Aho-Corasick automaton.
+// Built-in patterns are used until patterns from sources are loaded.
+type Set struct {
+	name     string
+	defaults []string
+	sources  []string
+	client   *http.Client
+	keys     fetch.Keys
+	watcher  *fetch.Watcher
+	trie     atomic.Value // *aho.Trie
+}
+
+// NewSet returns a new set of patterns with built-in defaults, defaults are ready to use immediately.
+// Patterns are case-insensitive.
+func NewSet(name string, defaults []string) *Set {
+	s := &Set{
+		name:     name,
+		defaults: defaults,
+		client:   fetch.NewHTTPClient(),
+	}
+	s.build(defaults)
+	return s
+}
+
+// Name returns name of the set.
+func (s *Set) Name() string {
+	return s.name
+}
+
+// SetSources sets URLs of the lists, which replace built-in patterns on the next Load.
+// URLs can also point to local files and directories (file:// URLs or paths).
+// Each line of the list is a single pattern, comments start with # at the beginning of the line.
+// Signed URLs are verified with keys, local files are checked for changes every poll interval (0 disables it).
+func (s *Set) SetSources(urls []string, keys fetch.Keys, poll time.Duration) {
+	s.sources = urls
+	s.keys = keys
+	s.watcher = fetch.NewWatcher(urls, poll)
+}
+
+// Changed returns true when local files of the sources were modified since the last Load.
+func (s *Set) Changed() bool {
+	return s.watcher.Changed()
+}
+
+// Load downloads patterns from the sources and rebuilds the automaton.
+// The automaton is replaced atomically, so matching is not blocked during the update.
+// When sources are not configured built-in patterns are kept; when sources contain no patterns,
+// the error is returned and the current patterns are kept. Current patterns are kept also when a signed source
+// cannot be verified.
+func (s *Set) Load() error {
+	if len(s.sources) == 0 {
+		return nil
+	}
+
+	log.Debugf("[pattern] loading %s patterns start", s.name)
+	s.watcher.Update()
+
+	var patterns []string
+	for _, url := range fetch.Expand(s.sources) {
+		list, err := s.download(url)
+		if errors.Is(err, fetch.ErrUnverified) {
+			return fmt.Errorf("%s patterns: %w, current patterns are kept", s.name, err)
+		}
+		if err != nil {
+			log.Errorf("[pattern] cannot download %s patterns from: %s, error: %s", s.name, url, err)
+			continue
+		}
+		patterns = append(patterns, list...)
+	}
+
+	if len(patterns) == 0 {
+		return fmt.Errorf("no %s patterns in sources: %v, current patterns are kept", s.name, s.sources)
+	}
+
+	s.build(patterns)
+	log.Debugf("[pattern] loading %s patterns stop; stats patterns: %d", s.name, len(patterns))
+	return nil
+}
+
+// RunUpdates schedules and runs updates every 12 hours, local sources are checked for changes every poll interval
+// and changes are loaded immediately.
+func (s *Set) RunUpdates(poll time.Duration) {
+	fetch.Schedule(context.Background(), 12*time.Hour, poll, s.Changed, func() {
+		if err := s.Load(); err != nil {
+			log.Error(err)
+		}
+	})
+}
+
+// Match returns the first pattern found in the text.
+func (s *Set) Match(text string) (string, bool) {
+	trie := s.trie.Load().(*aho.Trie)
+	if match := trie.MatchFirstString(strings.ToLower(text)); match != nil {
+		return match.MatchString(), true
+	}
+	return "", false
+}
+
+func (s *Set) build(patterns []string) {
+	lower := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		lower = append(lower, strings.ToLower(p))
+	}
+	s.trie.Store(aho.NewTrieBuilder().AddStrings(lower).Build())
+}
+
+func (s *Set) download(url string) ([]string, error) {
+	body, err := fetch.Get(s.client, url, s.keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,56 @@
+package pattern
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hosting.txt":
+			_, _ = w.Write([]byte("# hosting companies\nHetzner\n\n  ovh  \n"))
+		case "/empty.txt":
+			_, _ = w.Write([]byte("# nothing here\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	set := NewSet("hosting", []string{"Server", "vps"})
+	assert.Equal(t, "hosting", set.Name())
+
+	// built-in patterns are used before the first load and when sources are not configured
+	assert.NoError(t, set.Load())
+	match, ok := set.Match("my-SERVER.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "server", match)
+
+	_, ok = set.Match("Hetzner Online GmbH")
+	assert.False(t, ok)
+
+	set.SetSources([]string{server.URL + "/not-found.txt", server.URL + "/hosting.txt"}, nil, time.Second)
+	assert.NoError(t, set.Load())
+
+	match, ok = set.Match("Hetzner Online GmbH")
+	assert.True(t, ok)
+	assert.Equal(t, "hetzner", match)
+
+	match, ok = set.Match("OVH SAS")
+	assert.True(t, ok)
+	assert.Equal(t, "ovh", match)
+
+	_, ok = set.Match("vps.example.com")
+	assert.False(t, ok)
+
+	// current patterns are kept, when sources don't contain any pattern
+	set.SetSources([]string{server.URL + "/empty.txt"}, nil, time.Second)
+	assert.Error(t, set.Load())
+	_, ok = set.Match("OVH SAS")
+	assert.True(t, ok)
+}
@@ -0,0 +1,201 @@
+// Package policy maps scored requests to actions with declarative, per-host rules.
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/optimatiq/threatbite/scoring"
+)
+
+// Rule matches the request, when all defined conditions are met, empty conditions match everything.
+// Matched rule returns Action or, when Action is empty, action computed from its own thresholds.
+type Rule struct {
+	ID string `json:"id"`
+
+	// Hosts are glob patterns (path.Match syntax), e.g. "*.example.com".
+	Hosts            []string `json:"hosts"`
+	URIPrefixes      []string `json:"uri_prefixes"`
+	Methods          []string `json:"methods"`
+	Countries        []string `json:"countries"`
+	ExcludeCountries []string `json:"exclude_countries"`
+	ASNs             []uint   `json:"asns"`
+	// Signals matches when at least one of them was raised.
+	Signals []string `json:"signals"`
+	// MaxScore matches scores lower or equal to the value.
+	MaxScore *uint8 `json:"max_score"`
+
+	Action         string `json:"action"`
+	BlockBelow     *uint8 `json:"block_below"`
+	ChallengeBelow *uint8 `json:"challenge_below"`
+}
+
+// Policy is an ordered list of rules, the first matching rule wins.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Input contains information about the scored request, which is matched against rules.
+type Input struct {
+	Host    string
+	URI     string
+	Method  string
+	Country string
+	ASN     uint
+	Signals []string
+	Score   uint8
+}
+
+// Load reads policy from JSON file and validates all rules.
+func Load(filename string) (*Policy, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read policy file: %s, error: %w", filename, err)
+	}
+
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("cannot parse policy file: %s, error: %w", filename, err)
+	}
+
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file: %s, error: %w", filename, err)
+	}
+	return policy, nil
+}
+
+func (p *Policy) validate() error {
+	ids := map[string]bool{}
+	for i, r := range p.Rules {
+		if r.ID == "" {
+			return fmt.Errorf("rule #%d has no id", i)
+		}
+		if ids[r.ID] {
+			return fmt.Errorf("duplicated rule id: %s", r.ID)
+		}
+		ids[r.ID] = true
+
+		for _, h := range r.Hosts {
+			if _, err := path.Match(h, ""); err != nil {
+				return fmt.Errorf("rule %s has invalid host pattern: %s, error: %w", r.ID, h, err)
+			}
+		}
+
+		switch r.Action {
+		case scoring.ActionAllow, scoring.ActionChallenge, scoring.ActionBlock:
+			if r.BlockBelow != nil || r.ChallengeBelow != nil {
+				return fmt.Errorf("rule %s defines both action and thresholds", r.ID)
+			}
+		case "":
+			if r.BlockBelow == nil || r.ChallengeBelow == nil {
+				return fmt.Errorf("rule %s requires action or both thresholds", r.ID)
+			}
+			if *r.BlockBelow > *r.ChallengeBelow {
+				return fmt.Errorf("rule %s has block_below greater than challenge_below", r.ID)
+			}
+		default:
+			return fmt.Errorf("rule %s has invalid action: %s", r.ID, r.Action)
+		}
+	}
+	return nil
+}
+
+// ErrNoMatch is returned when none of the rules matches the input.
+var ErrNoMatch = errors.New("no matching rule")
+
+// Evaluate returns ID of the first matching rule and its action.
+// ErrNoMatch is returned when no rule matches, nil policy doesn't match anything.
+func (p *Policy) Evaluate(in Input) (string, string, error) {
+	if p == nil {
+		return "", "", ErrNoMatch
+	}
+
+	for _, r := range p.Rules {
+		if !r.match(in) {
+			continue
+		}
+
+		if r.Action != "" {
+			return r.ID, r.Action, nil
+		}
+		thresholds := scoring.Thresholds{BlockBelow: *r.BlockBelow, ChallengeBelow: *r.ChallengeBelow}
+		return r.ID, thresholds.Action(in.Score), nil
+	}
+	return "", "", ErrNoMatch
+}
+
+func (r *Rule) match(in Input) bool {
+	if len(r.Hosts) > 0 && !matchAny(r.Hosts, func(h string) bool {
+		ok, _ := path.Match(strings.ToLower(h), strings.ToLower(in.Host))
+		return ok
+	}) {
+		return false
+	}
+
+	if len(r.URIPrefixes) > 0 && !matchAny(r.URIPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(in.URI, prefix)
+	}) {
+		return false
+	}
+
+	if len(r.Methods) > 0 && !matchAny(r.Methods, func(m string) bool {
+		return strings.EqualFold(m, in.Method)
+	}) {
+		return false
+	}
+
+	if len(r.Countries) > 0 && !matchAny(r.Countries, func(c string) bool {
+		return strings.EqualFold(c, in.Country)
+	}) {
+		return false
+	}
+
+	if matchAny(r.ExcludeCountries, func(c string) bool {
+		return strings.EqualFold(c, in.Country)
+	}) {
+		return false
+	}
+
+	if len(r.ASNs) > 0 {
+		found := false
+		for _, asn := range r.ASNs {
+			if asn == in.ASN {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(r.Signals) > 0 && !matchAny(r.Signals, func(s string) bool {
+		for _, signal := range in.Signals {
+			if signal == s {
+				return true
+			}
+		}
+		return false
+	}) {
+		return false
+	}
+
+	if r.MaxScore != nil && in.Score > *r.MaxScore {
+		return false
+	}
+
+	return true
+}
+
+func matchAny(values []string, f func(string) bool) bool {
+	for _, v := range values {
+		if f(v) {
+			return true
+		}
+	}
+	return false
+}
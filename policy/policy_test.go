@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/optimatiq/threatbite/scoring"
+	"github.com/stretchr/testify/suite"
+)
+
+const testPolicy = `{"rules": [
+	{"id": "checkout-tor", "hosts": ["checkout.example.com"], "signals": ["tor"], "action": "block"},
+	{"id": "blog-tor", "hosts": ["blog.example.com"], "signals": ["tor", "proxy"], "action": "challenge"},
+	{"id": "admin-geofence", "hosts": ["admin.*"], "exclude_countries": ["PL", "DE"], "action": "block"},
+	{"id": "api-writes", "hosts": ["*.example.com"], "uri_prefixes": ["/api/"], "methods": ["POST", "PUT"],
+		"block_below": 40, "challenge_below": 80},
+	{"id": "bad-asn", "asns": [64496], "max_score": 60, "action": "block"},
+	{"id": "default-countries", "countries": ["KP"], "action": "block"}
+]}`
+
+type PolicySuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *PolicySuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "policy")
+	suite.NoError(err)
+	suite.dir = dir
+}
+
+func (suite *PolicySuite) TearDownTest() {
+	suite.NoError(os.RemoveAll(suite.dir))
+}
+
+func (suite *PolicySuite) write(content string) string {
+	filename := filepath.Join(suite.dir, "policy.json")
+	suite.NoError(ioutil.WriteFile(filename, []byte(content), 0600))
+	return filename
+}
+
+func (suite *PolicySuite) TestLoadInvalid() {
+	_, err := Load(filepath.Join(suite.dir, "missing.json"))
+	suite.Error(err)
+
+	invalid := []string{
+		`{"rules": [`,
+		`{"rules": [{"action": "block"}]}`,
+		`{"rules": [{"id": "a", "action": "block"}, {"id": "a", "action": "allow"}]}`,
+		`{"rules": [{"id": "a", "hosts": ["[a-"], "action": "block"}]}`,
+		`{"rules": [{"id": "a", "action": "captcha"}]}`,
+		`{"rules": [{"id": "a"}]}`,
+		`{"rules": [{"id": "a", "action": "block", "block_below": 10, "challenge_below": 20}]}`,
+		`{"rules": [{"id": "a", "block_below": 30, "challenge_below": 20}]}`,
+	}
+	for _, content := range invalid {
+		_, err := Load(suite.write(content))
+		suite.Error(err, content)
+	}
+}
+
+func (suite *PolicySuite) TestEvaluate() {
+	policy, err := Load(suite.write(testPolicy))
+	suite.NoError(err)
+
+	tests := []struct {
+		in     Input
+		id     string
+		action string
+	}{
+		{Input{Host: "checkout.example.com", Signals: []string{"tor"}, Score: 90}, "checkout-tor", scoring.ActionBlock},
+		{Input{Host: "blog.example.com", Signals: []string{"dc", "tor"}, Score: 90}, "blog-tor", scoring.ActionChallenge},
+		{Input{Host: "Admin.example.com", Country: "US", Score: 90}, "admin-geofence", scoring.ActionBlock},
+		{Input{Host: "shop.example.com", URI: "/api/order", Method: "POST", Score: 30}, "api-writes", scoring.ActionBlock},
+		{Input{Host: "shop.example.com", URI: "/api/order", Method: "put", Score: 50}, "api-writes", scoring.ActionChallenge},
+		{Input{Host: "shop.example.com", URI: "/api/order", Method: "POST", Score: 90}, "api-writes", scoring.ActionAllow},
+		{Input{Host: "other.com", ASN: 64496, Score: 60}, "bad-asn", scoring.ActionBlock},
+		{Input{Host: "other.com", Country: "kp", Score: 100}, "default-countries", scoring.ActionBlock},
+	}
+	for _, t := range tests {
+		id, action, err := policy.Evaluate(t.in)
+		suite.NoError(err, t.in)
+		suite.Equal(t.id, id, t.in)
+		suite.Equal(t.action, action, t.in)
+	}
+
+	noMatch := []Input{
+		{Host: "checkout.example.com", Score: 90},
+		{Host: "admin.example.com", Country: "PL", Score: 90},
+		{Host: "shop.example.com", URI: "/api/order", Method: "GET", Score: 10},
+		{Host: "other.com", ASN: 64496, Score: 61},
+	}
+	for _, in := range noMatch {
+		_, _, err := policy.Evaluate(in)
+		suite.Equal(ErrNoMatch, err, in)
+	}
+
+	var empty *Policy
+	_, _, err = empty.Evaluate(Input{})
+	suite.Equal(ErrNoMatch, err)
+}
+
+func TestPolicySuite(t *testing.T) {
+	suite.Run(t, new(PolicySuite))
+}
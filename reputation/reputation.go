@@ -0,0 +1,256 @@
+// Package reputation keeps local reputation of IP addresses and email addresses built from outcomes
+// reported by clients (chargebacks, confirmed fraud, spam complaints, confirmed good users).
+// Reputation decays over time with configured half-life and is persisted in JSON file.
+package reputation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/labstack/gommon/log"
+)
+
+// Outcomes reported by clients.
+const (
+	OutcomeChargeback    = "chargeback"
+	OutcomeFraud         = "fraud"
+	OutcomeGood          = "good"
+	OutcomeSpamComplaint = "spam_complaint"
+)
+
+// weights of outcomes, reputation is kept in range -100 - 100.
+var weights = map[string]float64{
+	OutcomeChargeback:    -40,
+	OutcomeFraud:         -50,
+	OutcomeGood:          20,
+	OutcomeSpamComplaint: -20,
+}
+
+// maxReputation is the maximal absolute value of reputation.
+const maxReputation = 100
+
+// maxGoodReputation caps reputation built from good outcomes, so they cannot outweigh bad ones,
+// e.g. a single fraud outcome always results in bad reputation.
+const maxGoodReputation = 40
+
+// minReputation is the absolute value, below which decayed entries are removed.
+const minReputation = 1
+
+// trackedRequests is a number of recent request IDs, which can be referenced by feedback.
+const trackedRequests = 65536
+
+// ErrUnknownOutcome is returned for outcomes, which are not supported.
+var ErrUnknownOutcome = errors.New("unknown outcome")
+
+// ErrUnknownRequest is returned for request IDs, which are not tracked (too old or never scored).
+var ErrUnknownRequest = errors.New("unknown request ID")
+
+// Subject is an IP address and/or email address, which the outcome refers to.
+type Subject struct {
+	IP    string
+	Email string
+}
+
+type entry struct {
+	Value   float64   `json:"value"`
+	Updated time.Time `json:"updated"`
+}
+
+// Store is a local reputation store.
+type Store struct {
+	mu       sync.RWMutex
+	entries  map[string]*entry
+	dirty    bool
+	filename string
+	halfLife time.Duration
+	requests *lru.Cache
+	now      func() time.Time
+}
+
+// NewStore creates a store with given half-life of reputation, entries are loaded from the file if it exists.
+// Empty filename means that reputation is kept only in memory.
+func NewStore(filename string, halfLife time.Duration) (*Store, error) {
+	requests, err := lru.New(trackedRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		entries:  map[string]*entry{},
+		filename: filename,
+		halfLife: halfLife,
+		requests: requests,
+		now:      time.Now,
+	}
+
+	if filename == "" {
+		return s, nil
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read reputation file: %s, error: %w", filename, err)
+	}
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("cannot parse reputation file: %s, error: %w", filename, err)
+	}
+	return s, nil
+}
+
+// Track remembers subject of the scored request, so feedback can refer to it by request ID.
+func (s *Store) Track(requestID string, subject Subject) {
+	if s == nil || requestID == "" {
+		return
+	}
+	s.requests.Add(requestID, subject)
+}
+
+// Resolve returns subject of the tracked request.
+func (s *Store) Resolve(requestID string) (Subject, error) {
+	if v, ok := s.requests.Get(requestID); ok {
+		return v.(Subject), nil
+	}
+	return Subject{}, ErrUnknownRequest
+}
+
+// Record adds outcome to reputation of the subject, empty fields of the subject are skipped.
+func (s *Store) Record(subject Subject, outcome string) error {
+	weight, ok := weights[outcome]
+	if !ok {
+		return ErrUnknownOutcome
+	}
+
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys(subject) {
+		e, ok := s.entries[key]
+		if !ok {
+			e = &entry{}
+			s.entries[key] = e
+		}
+		e.Value = math.Max(-maxReputation, math.Min(maxGoodReputation, s.decayed(e, now)+weight))
+		e.Updated = now
+		log.Debugf("[reputation] %s outcome: %s reputation: %.1f", key, outcome, e.Value)
+	}
+	s.dirty = true
+	return nil
+}
+
+// IP returns local reputation of the IP address (-100 - 100), 0 means no history.
+func (s *Store) IP(ip net.IP) int {
+	return s.get(ipKey(ip))
+}
+
+// Email returns local reputation of the email address (-100 - 100), 0 means no history.
+func (s *Store) Email(address string) int {
+	return s.get(emailKey(address))
+}
+
+func (s *Store) get(key string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return 0
+	}
+	return int(math.Round(s.decayed(e, s.now())))
+}
+
+// decayed returns value of the entry after exponential decay.
+func (s *Store) decayed(e *entry, now time.Time) float64 {
+	if s.halfLife <= 0 || e.Updated.IsZero() {
+		return e.Value
+	}
+	return e.Value * math.Pow(0.5, float64(now.Sub(e.Updated))/float64(s.halfLife))
+}
+
+// Save removes decayed entries and writes the store to the file, when it was changed since the last save.
+func (s *Store) Save() error {
+	if s.filename == "" {
+		return nil
+	}
+
+	now := s.now()
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	for key, e := range s.entries {
+		if math.Abs(s.decayed(e, now)) < minReputation {
+			delete(s.entries, key)
+		}
+	}
+	data, err := json.Marshal(s.entries)
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("cannot encode reputation, error: %w", err)
+	}
+
+	// file is replaced atomically, so it's never left partially written
+	tmp, err := ioutil.TempFile(filepath.Dir(s.filename), filepath.Base(s.filename)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot save reputation file: %s, error: %w", s.filename, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot save reputation file: %s, error: %w", s.filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot save reputation file: %s, error: %w", s.filename, err)
+	}
+	if err := os.Rename(tmp.Name(), s.filename); err != nil {
+		return fmt.Errorf("cannot save reputation file: %s, error: %w", s.filename, err)
+	}
+	return nil
+}
+
+// RunUpdates saves the store every minute.
+func (s *Store) RunUpdates() {
+	go func() {
+		for range time.Tick(time.Minute) {
+			if err := s.Save(); err != nil {
+				log.Error(err)
+			}
+		}
+	}()
+}
+
+func keys(subject Subject) []string {
+	var keys []string
+	if ip := net.ParseIP(subject.IP); ip != nil {
+		keys = append(keys, ipKey(ip))
+	}
+	if subject.Email != "" {
+		keys = append(keys, emailKey(subject.Email))
+	}
+	return keys
+}
+
+func ipKey(ip net.IP) string {
+	return "ip:" + ip.String()
+}
+
+func emailKey(address string) string {
+	return "email:" + strings.ToLower(address)
+}
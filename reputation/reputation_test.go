@@ -0,0 +1,93 @@
+package reputation
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reputation")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "reputation.json")
+
+	s, err := NewStore(filename, 24*time.Hour)
+	assert.NoError(t, err)
+	now := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	ip := net.ParseIP("192.0.2.1")
+	assert.Equal(t, 0, s.IP(ip))
+
+	assert.NoError(t, s.Record(Subject{IP: "192.0.2.1", Email: "User@Example.com"}, OutcomeChargeback))
+	assert.Equal(t, -40, s.IP(ip))
+	assert.Equal(t, -40, s.Email("user@example.com"))
+
+	assert.NoError(t, s.Record(Subject{IP: "192.0.2.1"}, OutcomeFraud))
+	assert.NoError(t, s.Record(Subject{IP: "192.0.2.1"}, OutcomeFraud))
+	assert.Equal(t, -100, s.IP(ip), "reputation is limited")
+
+	assert.Equal(t, ErrUnknownOutcome, s.Record(Subject{IP: "192.0.2.1"}, "unknown"))
+
+	// half-life
+	now = now.Add(24 * time.Hour)
+	assert.Equal(t, -50, s.IP(ip))
+	assert.Equal(t, -20, s.Email("user@example.com"))
+
+	assert.NoError(t, s.Record(Subject{IP: "192.0.2.2"}, OutcomeGood))
+
+	// good outcomes cannot outweigh bad ones
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, s.Record(Subject{IP: "192.0.2.4"}, OutcomeGood))
+	}
+	assert.Equal(t, 40, s.IP(net.ParseIP("192.0.2.4")))
+	assert.NoError(t, s.Record(Subject{IP: "192.0.2.4"}, OutcomeFraud))
+	assert.Equal(t, -10, s.IP(net.ParseIP("192.0.2.4")))
+	assert.NoError(t, s.Save())
+
+	loaded, err := NewStore(filename, 24*time.Hour)
+	assert.NoError(t, err)
+	loaded.now = s.now
+	assert.Equal(t, -50, loaded.IP(ip))
+	assert.Equal(t, 20, loaded.IP(net.ParseIP("192.0.2.2")))
+
+	// decayed entries are removed on save
+	now = now.Add(30 * 24 * time.Hour)
+	assert.NoError(t, s.Record(Subject{IP: "192.0.2.3"}, OutcomeGood))
+	assert.NoError(t, s.Save())
+	loaded, err = NewStore(filename, 24*time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, loaded.entries, 1)
+}
+
+func TestStoreRequests(t *testing.T) {
+	s, err := NewStore("", time.Hour)
+	assert.NoError(t, err)
+
+	s.Track("abc", Subject{IP: "192.0.2.1", Email: "user@example.com"})
+	subject, err := s.Resolve("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", subject.IP)
+
+	_, err = s.Resolve("missing")
+	assert.Equal(t, ErrUnknownRequest, err)
+
+	assert.NoError(t, s.Save(), "memory only store")
+}
+
+func TestNewStoreInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reputation")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "reputation.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("{"), 0600))
+	_, err = NewStore(filename, time.Hour)
+	assert.Error(t, err)
+}
@@ -0,0 +1,409 @@
+package rule
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Type of the variable or expression.
+type Type int
+
+// Types supported in expressions. List is a list of strings.
+const (
+	Bool Type = iota + 1
+	String
+	Number
+	List
+)
+
+func (t Type) String() string {
+	switch t {
+	case Bool:
+		return "bool"
+	case String:
+		return "string"
+	case Number:
+		return "number"
+	case List:
+		return "list"
+	}
+	return "unknown"
+}
+
+// Schema declares variables available in expressions and their types.
+type Schema map[string]Type
+
+// Vars contains values of variables: bool, string, float64 or []string depending on the type.
+// Missing variable has zero value of its type.
+type Vars map[string]interface{}
+
+// Expression is compiled and type-checked boolean expression.
+type Expression struct {
+	source string
+	eval   func(Vars) interface{}
+}
+
+// String returns source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval returns result of the expression for given variables.
+func (e *Expression) Eval(vars Vars) bool {
+	return e.eval(vars).(bool)
+}
+
+// Compile parses expression and checks types of all operands, expression has to return bool.
+//
+// Supported syntax:
+//   - literals: "string", 'string', 10, 1.5, true, false, ["a", "b"]
+//   - variables declared in schema, e.g. ip.tor, request.method
+//   - logical operators: &&, ||, !, parentheses
+//   - comparison: ==, != (all types except list), <, <=, >, >= (numbers)
+//   - string in list: ip.country in ["PL", "DE"]
+//   - string methods: startsWith, endsWith, contains, matches (regular expression literal)
+//   - list method: contains
+func Compile(expr string, schema Schema) (*Expression, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, schema: schema}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	if n.typ != Bool {
+		return nil, fmt.Errorf("expression returns %s, bool expected", n.typ)
+	}
+	return &Expression{source: expr, eval: n.eval}, nil
+}
+
+type node struct {
+	typ  Type
+	eval func(Vars) interface{}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	schema Schema
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOperator(op string) bool {
+	t := p.peek()
+	return t.kind == tokenOperator && t.text == op
+}
+
+func (p *parser) expect(op string) error {
+	if t := p.next(); t.kind != tokenOperator || t.text != op {
+		return fmt.Errorf("expected %q at %d, got %q", op, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (*node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("||") {
+		t := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ != Bool || right.typ != Bool {
+			return nil, fmt.Errorf("operator || at %d requires bool operands", t.pos)
+		}
+		l, r := left.eval, right.eval
+		left = &node{typ: Bool, eval: func(v Vars) interface{} {
+			return l(v).(bool) || r(v).(bool)
+		}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (*node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("&&") {
+		t := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ != Bool || right.typ != Bool {
+			return nil, fmt.Errorf("operator && at %d requires bool operands", t.pos)
+		}
+		l, r := left.eval, right.eval
+		left = &node{typ: Bool, eval: func(v Vars) interface{} {
+			return l(v).(bool) && r(v).(bool)
+		}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (*node, error) {
+	if p.isOperator("!") {
+		t := p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ != Bool {
+			return nil, fmt.Errorf("operator ! at %d requires bool operand", t.pos)
+		}
+		o := operand.eval
+		return &node{typ: Bool, eval: func(v Vars) interface{} {
+			return !o(v).(bool)
+		}}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (*node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.kind == tokenIdent && t.text == "in" {
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ != String || right.typ != List {
+			return nil, fmt.Errorf("operator in at %d requires string and list operands", t.pos)
+		}
+		l, r := left.eval, right.eval
+		return &node{typ: Bool, eval: func(v Vars) interface{} {
+			return listContains(r(v).([]string), l(v).(string))
+		}}, nil
+	}
+
+	if t.kind != tokenOperator {
+		return left, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if left.typ != right.typ {
+		return nil, fmt.Errorf("operator %s at %d compares %s with %s", t.text, t.pos, left.typ, right.typ)
+	}
+	if left.typ == List {
+		return nil, fmt.Errorf("operator %s at %d cannot compare lists", t.text, t.pos)
+	}
+	if t.text != "==" && t.text != "!=" && left.typ != Number {
+		return nil, fmt.Errorf("operator %s at %d requires number operands", t.text, t.pos)
+	}
+
+	l, r, op := left.eval, right.eval, t.text
+	return &node{typ: Bool, eval: func(v Vars) interface{} {
+		a, b := l(v), r(v)
+		switch op {
+		case "==":
+			return a == b
+		case "!=":
+			return a != b
+		case "<":
+			return a.(float64) < b.(float64)
+		case "<=":
+			return a.(float64) <= b.(float64)
+		case ">":
+			return a.(float64) > b.(float64)
+		default:
+			return a.(float64) >= b.(float64)
+		}
+	}}, nil
+}
+
+func (p *parser) parsePrimary() (*node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString, tokenNumber:
+		value := t.value
+		typ := String
+		if t.kind == tokenNumber {
+			typ = Number
+		}
+		return &node{typ: typ, eval: func(Vars) interface{} { return value }}, nil
+
+	case tokenIdent:
+		switch t.text {
+		case "true", "false":
+			value := t.text == "true"
+			return &node{typ: Bool, eval: func(Vars) interface{} { return value }}, nil
+		}
+		if p.isOperator("(") {
+			return p.parseMethod(t)
+		}
+		return p.variable(t.text, t.pos)
+
+	case tokenOperator:
+		switch t.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			return p.parseList()
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *parser) parseList() (*node, error) {
+	var list []string
+	for !p.isOperator("]") {
+		if len(list) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		t := p.next()
+		if t.kind != tokenString {
+			return nil, fmt.Errorf("list at %d can contain only strings, got %q", t.pos, t.text)
+		}
+		list = append(list, t.value.(string))
+	}
+	p.next()
+	return &node{typ: List, eval: func(Vars) interface{} { return list }}, nil
+}
+
+func (p *parser) variable(name string, pos int) (*node, error) {
+	typ, ok := p.schema[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %q at %d", name, pos)
+	}
+
+	var zero interface{}
+	switch typ {
+	case Bool:
+		zero = false
+	case String:
+		zero = ""
+	case Number:
+		zero = float64(0)
+	case List:
+		zero = []string(nil)
+	}
+
+	return &node{typ: typ, eval: func(v Vars) interface{} {
+		value, ok := v[name]
+		if !ok || value == nil {
+			return zero
+		}
+		if typ == Number {
+			return toNumber(value)
+		}
+		return value
+	}}, nil
+}
+
+// parseMethod parses call like request.uri.startsWith("/login"), receiver is a variable before the last dot.
+func (p *parser) parseMethod(t token) (*node, error) {
+	dot := strings.LastIndex(t.text, ".")
+	if dot < 0 {
+		return nil, fmt.Errorf("unknown function %q at %d", t.text, t.pos)
+	}
+	receiver, err := p.variable(t.text[:dot], t.pos)
+	if err != nil {
+		return nil, err
+	}
+	method := t.text[dot+1:]
+
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	arg := p.next()
+	if arg.kind != tokenString {
+		return nil, fmt.Errorf("method %s at %d requires string literal argument", method, t.pos)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	r, s := receiver.eval, arg.value.(string)
+	var f func(v Vars) interface{}
+
+	switch {
+	case receiver.typ == String && method == "startsWith":
+		f = func(v Vars) interface{} { return strings.HasPrefix(r(v).(string), s) }
+	case receiver.typ == String && method == "endsWith":
+		f = func(v Vars) interface{} { return strings.HasSuffix(r(v).(string), s) }
+	case receiver.typ == String && method == "contains":
+		f = func(v Vars) interface{} { return strings.Contains(r(v).(string), s) }
+	case receiver.typ == String && method == "matches":
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression at %d, error: %w", arg.pos, err)
+		}
+		f = func(v Vars) interface{} { return re.MatchString(r(v).(string)) }
+	case receiver.typ == List && method == "contains":
+		f = func(v Vars) interface{} { return listContains(r(v).([]string), s) }
+	default:
+		return nil, fmt.Errorf("unknown method %s of %s at %d", method, receiver.typ, t.pos)
+	}
+
+	return &node{typ: Bool, eval: f}, nil
+}
+
+// toNumber converts integer values to float64, so callers don't have to convert them.
+func toNumber(value interface{}) float64 {
+	switch n := value.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case uint:
+		return float64(n)
+	case uint8:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	}
+	return 0
+}
+
+func listContains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
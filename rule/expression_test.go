@@ -0,0 +1,85 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = Schema{
+	"ip.tor":          Bool,
+	"ip.country":      String,
+	"ip.asn":          Number,
+	"request.method":  String,
+	"request.uri":     String,
+	"request.signals": List,
+	"email.free":      Bool,
+}
+
+func TestCompile(t *testing.T) {
+	vars := Vars{
+		"ip.tor":          true,
+		"ip.country":      "US",
+		"ip.asn":          uint(64496),
+		"request.method":  "POST",
+		"request.uri":     "/login?next=/",
+		"request.signals": []string{"script", "missing_accept"},
+	}
+
+	tests := map[string]bool{
+		`ip.tor && request.method == "POST" && request.uri.startsWith("/login")`: true,
+		`email.free && ip.country != "PL"`:                                       false,
+		`!email.free && ip.country != 'PL'`:                                      true,
+		`ip.country in ["PL", "DE"]`:                                             false,
+		`ip.country in ["PL", "US"] || false`:                                    true,
+		`ip.asn >= 64496 && ip.asn < 64512`:                                      true,
+		`ip.asn == 64497`:                                                        false,
+		`request.signals.contains("script")`:                                     true,
+		`"bot" in request.signals`:                                               false,
+		`request.uri.matches("(?i)^/LOGIN")`:                                     true,
+		`request.uri.endsWith("/") && request.uri.contains("next")`:              true,
+		`!(ip.tor || email.free)`:                                                false,
+		`true && !false`:                                                         true,
+	}
+
+	for expr, want := range tests {
+		e, err := Compile(expr, testSchema)
+		if assert.NoError(t, err, expr) {
+			assert.Equal(t, want, e.Eval(vars), expr)
+			assert.Equal(t, expr, e.String())
+		}
+	}
+
+	// missing variables have zero values
+	e, err := Compile(`!ip.tor && ip.country == "" && ip.asn == 0`, testSchema)
+	assert.NoError(t, err)
+	assert.True(t, e.Eval(Vars{}))
+}
+
+func TestCompileInvalid(t *testing.T) {
+	invalid := []string{
+		``,
+		`ip.country`,
+		`ip.unknown`,
+		`ip.tor &&`,
+		`ip.tor == "true"`,
+		`ip.country < "PL"`,
+		`ip.country in "PL"`,
+		`request.signals == request.signals`,
+		`request.uri.startsWith(1)`,
+		`request.uri.unknown("a")`,
+		`request.uri.matches("[")`,
+		`ip.tor.contains("a")`,
+		`(ip.tor`,
+		`ip.tor)`,
+		`"unterminated`,
+		`ip.tor # comment`,
+		`["a", 1]`,
+		`!ip.country`,
+	}
+
+	for _, expr := range invalid {
+		_, err := Compile(expr, testSchema)
+		assert.Error(t, err, expr)
+	}
+}
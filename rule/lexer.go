@@ -0,0 +1,102 @@
+package rule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+// operators are ordered, so longer ones are matched first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "!", "<", ">", "(", ")", "[", "]", ","}
+
+// tokenize splits expression into tokens. Identifiers may contain dots, e.g. request.uri.startsWith
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	for pos := 0; pos < len(expr); {
+		c := rune(expr[pos])
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+
+		case c == '"' || c == '\'':
+			end := pos + 1
+			for end < len(expr) && rune(expr[end]) != c {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at %d", pos)
+			}
+			raw := expr[pos+1 : end]
+			if c == '\'' {
+				raw = strings.ReplaceAll(raw, `\'`, `'`)
+				raw = strings.ReplaceAll(raw, `"`, `\"`)
+			}
+			s, err := strconv.Unquote(`"` + raw + `"`)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at %d, error: %w", pos, err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: expr[pos : end+1], value: s, pos: pos})
+			pos = end + 1
+
+		case unicode.IsDigit(c) || (c == '-' && pos+1 < len(expr) && unicode.IsDigit(rune(expr[pos+1]))):
+			end := pos + 1
+			for end < len(expr) && (unicode.IsDigit(rune(expr[end])) || expr[end] == '.') {
+				end++
+			}
+			n, err := strconv.ParseFloat(expr[pos:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number at %d, error: %w", pos, err)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expr[pos:end], value: n, pos: pos})
+			pos = end
+
+		case unicode.IsLetter(c) || c == '_':
+			end := pos + 1
+			for end < len(expr) && (isIdentRune(rune(expr[end])) || expr[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: expr[pos:end], pos: pos})
+			pos = end
+
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(expr[pos:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: pos})
+					pos += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, pos)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(expr)}), nil
+}
+
+func isIdentRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_'
+}
@@ -0,0 +1,112 @@
+// Package rule evaluates custom rules written in simple expression language over IP, email and request results.
+// Rules are compiled and validated when they are loaded, matched rules adjust the score or force the action.
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/optimatiq/threatbite/scoring"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var ruleHits = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "threatbite",
+	Name:      "rule_hits_total",
+	Help:      "Number of requests matched by the custom rule.",
+}, []string{"rule"})
+
+// Rule is a single custom rule. Score is added to the score of matched object, Action (optional) forces the action.
+type Rule struct {
+	ID         string `json:"id"`
+	Expression string `json:"expression"`
+	Score      int    `json:"score"`
+	Action     string `json:"action"`
+
+	compiled *Expression
+}
+
+// Rules is an ordered list of compiled rules.
+type Rules struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Result contains matched rules, score adjustments and the action forced by the first matched rule with action.
+type Result struct {
+	Matched []string
+	Signals scoring.Signals
+	Action  string
+}
+
+// Load reads rules from JSON file and compiles them with given schema of variables.
+func Load(filename string, schema Schema) (*Rules, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rules file: %s, error: %w", filename, err)
+	}
+
+	rules := &Rules{}
+	if err := json.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("cannot parse rules file: %s, error: %w", filename, err)
+	}
+
+	if err := rules.compile(schema); err != nil {
+		return nil, fmt.Errorf("invalid rules file: %s, error: %w", filename, err)
+	}
+	return rules, nil
+}
+
+func (r *Rules) compile(schema Schema) error {
+	ids := map[string]bool{}
+	for i, rule := range r.Rules {
+		if rule.ID == "" {
+			return fmt.Errorf("rule #%d has no id", i)
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("duplicated rule id: %s", rule.ID)
+		}
+		ids[rule.ID] = true
+
+		switch rule.Action {
+		case "", scoring.ActionAllow, scoring.ActionChallenge, scoring.ActionBlock:
+		default:
+			return fmt.Errorf("rule %s has invalid action: %s", rule.ID, rule.Action)
+		}
+		if rule.Action == "" && rule.Score == 0 {
+			return fmt.Errorf("rule %s requires score or action", rule.ID)
+		}
+
+		compiled, err := Compile(rule.Expression, schema)
+		if err != nil {
+			return fmt.Errorf("rule %s has invalid expression, error: %w", rule.ID, err)
+		}
+		rule.compiled = compiled
+	}
+	return nil
+}
+
+// Evaluate runs all rules against variables, nil rules don't match anything.
+func (r *Rules) Evaluate(vars Vars) Result {
+	var result Result
+	if r == nil {
+		return result
+	}
+
+	for _, rule := range r.Rules {
+		if !rule.compiled.Eval(vars) {
+			continue
+		}
+
+		ruleHits.WithLabelValues(rule.ID).Inc()
+		result.Matched = append(result.Matched, rule.ID)
+		if rule.Score != 0 {
+			result.Signals.Add("rule:"+rule.ID, rule.Score)
+		}
+		if result.Action == "" {
+			result.Action = rule.Action
+		}
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+package rule
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/optimatiq/threatbite/scoring"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeRules(t *testing.T, dir, content string) string {
+	filename := filepath.Join(dir, "rules.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(content), 0600))
+	return filename
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = Load(filepath.Join(dir, "missing.json"), testSchema)
+	assert.Error(t, err)
+
+	invalid := []string{
+		`{"rules": [`,
+		`{"rules": [{"expression": "ip.tor", "score": -10}]}`,
+		`{"rules": [{"id": "a", "expression": "ip.tor", "score": -10}, {"id": "a", "expression": "ip.tor", "score": -10}]}`,
+		`{"rules": [{"id": "a", "expression": "ip.tor"}]}`,
+		`{"rules": [{"id": "a", "expression": "ip.tor", "action": "captcha"}]}`,
+		`{"rules": [{"id": "a", "expression": "ip.unknown", "score": -10}]}`,
+	}
+	for _, content := range invalid {
+		_, err := Load(writeRules(t, dir, content), testSchema)
+		assert.Error(t, err, content)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rules, err := Load(writeRules(t, dir, `{"rules": [
+		{"id": "tor-login", "expression": "ip.tor && request.uri.startsWith(\"/login\")", "score": -30},
+		{"id": "tor-post", "expression": "ip.tor && request.method == \"POST\"", "action": "block"},
+		{"id": "free-email", "expression": "email.free && ip.country != \"PL\"", "score": -10, "action": "challenge"}
+	]}`), testSchema)
+	assert.NoError(t, err)
+
+	result := rules.Evaluate(Vars{"ip.tor": true, "request.method": "POST", "request.uri": "/login", "email.free": true})
+	assert.Equal(t, []string{"tor-login", "tor-post", "free-email"}, result.Matched)
+	assert.Equal(t, scoring.Signals{{Name: "rule:tor-login", Weight: -30}, {Name: "rule:free-email", Weight: -10}}, result.Signals)
+	assert.Equal(t, scoring.ActionBlock, result.Action)
+	assert.Equal(t, float64(1), testutil.ToFloat64(ruleHits.WithLabelValues("tor-post")))
+
+	result = rules.Evaluate(Vars{"ip.country": "PL", "email.free": true})
+	assert.Empty(t, result.Matched)
+	assert.Empty(t, result.Action)
+
+	var empty *Rules
+	assert.Empty(t, empty.Evaluate(Vars{}).Matched)
+}
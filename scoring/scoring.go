@@ -0,0 +1,71 @@
+// Package scoring combines signals into the score and converts the score into the suggested action.
+package scoring
+
+// Actions, which are suggested for scored requests.
+const (
+	ActionAllow     = "allow"
+	ActionChallenge = "challenge"
+	ActionBlock     = "block"
+)
+
+// Signal is a single observation, which changes the score by its weight (negative weight lowers the score).
+type Signal struct {
+	Name   string
+	Weight int
+}
+
+// Signals is a list of signals raised for the scored object.
+type Signals []Signal
+
+// Add appends new signal to the list.
+func (s *Signals) Add(name string, weight int) {
+	*s = append(*s, Signal{Name: name, Weight: weight})
+}
+
+// Names returns names of all signals, nil is returned for the empty list.
+func (s Signals) Names() []string {
+	var names []string
+	for _, signal := range s {
+		names = append(names, signal.Name)
+	}
+	return names
+}
+
+// Score returns base score changed by weights of all signals, result is clamped to 0-100 (worst-best).
+func (s Signals) Score(base int) uint8 {
+	score := base
+	for _, signal := range s {
+		score += signal.Weight
+	}
+	return Clamp(score)
+}
+
+// Clamp returns score limited to 0-100 range.
+func Clamp(score int) uint8 {
+	if score > 100 {
+		return 100
+	}
+	if score < 0 {
+		return 0
+	}
+	return uint8(score)
+}
+
+// Thresholds defines scores, which separate actions.
+// Score lower than BlockBelow results in block, lower than ChallengeBelow in challenge, otherwise allow.
+type Thresholds struct {
+	BlockBelow     uint8
+	ChallengeBelow uint8
+}
+
+// Action returns action suggested for the score.
+func (t Thresholds) Action(score uint8) string {
+	switch {
+	case score < t.BlockBelow:
+		return ActionBlock
+	case score < t.ChallengeBelow:
+		return ActionChallenge
+	default:
+		return ActionAllow
+	}
+}
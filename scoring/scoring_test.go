@@ -0,0 +1,39 @@
+package scoring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignals(t *testing.T) {
+	var signals Signals
+	assert.Nil(t, signals.Names())
+	assert.Equal(t, uint8(80), signals.Score(80))
+
+	signals.Add("script", -25)
+	signals.Add("fake_bot", -60)
+	assert.Equal(t, []string{"script", "fake_bot"}, signals.Names())
+	assert.Equal(t, uint8(15), signals.Score(100))
+	assert.Equal(t, uint8(0), signals.Score(50))
+
+	signals = Signals{{Name: "verified", Weight: 30}}
+	assert.Equal(t, uint8(100), signals.Score(90))
+}
+
+func TestThresholds_Action(t *testing.T) {
+	thresholds := Thresholds{BlockBelow: 20, ChallengeBelow: 50}
+
+	tests := map[uint8]string{
+		0:   ActionBlock,
+		19:  ActionBlock,
+		20:  ActionChallenge,
+		49:  ActionChallenge,
+		50:  ActionAllow,
+		100: ActionAllow,
+	}
+
+	for score, want := range tests {
+		assert.Equal(t, want, thresholds.Action(score), score)
+	}
+}
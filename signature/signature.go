@@ -0,0 +1,225 @@
+// Package signature detects attacks (SQL injection, XSS, path traversal, command injection, scanners)
+// in URI and payload of the request. Signatures are regular expressions, built-in set can be replaced by ruleset file.
+package signature
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/optimatiq/threatbite/scoring"
+)
+
+// Categories of attacks.
+const (
+	CategorySQLInjection     = "sqli"
+	CategoryXSS              = "xss"
+	CategoryTraversal        = "traversal"
+	CategoryCommandInjection = "command_injection"
+	CategoryScanner          = "scanner"
+	CategoryEvasion          = "evasion"
+)
+
+// Targets, which are matched by signatures.
+const (
+	TargetURI     = "uri"
+	TargetPath    = "path"
+	TargetPayload = "payload"
+)
+
+// SignalPrefix is a prefix of signals raised for categories, e.g. attack_sqli.
+const SignalPrefix = "attack_"
+
+// weights of the categories, a category is counted once, no matter how many signatures matched.
+var weights = map[string]int{
+	CategorySQLInjection:     -40,
+	CategoryXSS:              -40,
+	CategoryTraversal:        -40,
+	CategoryCommandInjection: -40,
+	CategoryScanner:          -30,
+	CategoryEvasion:          -20,
+}
+
+// maxPayload limits size of the payload, which is inspected.
+const maxPayload = 64 * 1024
+
+// maxDecode limits rounds of URL decoding, attackers use multiple encoding to evade filters.
+const maxDecode = 3
+
+// Signature is a single attack signature.
+// Pattern is matched against lower-cased and decoded (URL, HTML entities) target, when Raw is set it's matched
+// against the original text, which is needed to detect encoding tricks. Targets default to uri and payload.
+type Signature struct {
+	ID       string   `json:"id"`
+	Category string   `json:"category"`
+	Pattern  string   `json:"pattern"`
+	Targets  []string `json:"targets"`
+	Raw      bool     `json:"raw"`
+
+	re *regexp.Regexp
+}
+
+// Ruleset is a list of compiled signatures.
+type Ruleset struct {
+	Signatures []*Signature `json:"signatures"`
+}
+
+// Result contains IDs of matched signatures and signals of their categories.
+type Result struct {
+	Matched []string
+	Signals scoring.Signals
+}
+
+// Default returns built-in signatures.
+func Default() *Ruleset {
+	ruleset := &Ruleset{Signatures: builtin()}
+	if err := ruleset.compile(); err != nil {
+		panic(err)
+	}
+	return ruleset
+}
+
+// Load reads signatures from JSON file, they replace built-in signatures.
+func Load(filename string) (*Ruleset, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read signatures file: %s, error: %w", filename, err)
+	}
+
+	ruleset := &Ruleset{}
+	if err := json.Unmarshal(data, ruleset); err != nil {
+		return nil, fmt.Errorf("cannot parse signatures file: %s, error: %w", filename, err)
+	}
+
+	if err := ruleset.compile(); err != nil {
+		return nil, fmt.Errorf("invalid signatures file: %s, error: %w", filename, err)
+	}
+	return ruleset, nil
+}
+
+func (r *Ruleset) compile() error {
+	ids := map[string]bool{}
+	for i, signature := range r.Signatures {
+		if signature.ID == "" {
+			return fmt.Errorf("signature #%d has no id", i)
+		}
+		if ids[signature.ID] {
+			return fmt.Errorf("duplicated signature id: %s", signature.ID)
+		}
+		ids[signature.ID] = true
+
+		if _, ok := weights[signature.Category]; !ok {
+			return fmt.Errorf("signature %s has invalid category: %s", signature.ID, signature.Category)
+		}
+
+		if len(signature.Targets) == 0 {
+			signature.Targets = []string{TargetURI, TargetPayload}
+		}
+		for _, target := range signature.Targets {
+			switch target {
+			case TargetURI, TargetPath, TargetPayload:
+			default:
+				return fmt.Errorf("signature %s has invalid target: %s", signature.ID, target)
+			}
+		}
+
+		re, err := regexp.Compile(signature.Pattern)
+		if err != nil {
+			return fmt.Errorf("signature %s has invalid pattern, error: %w", signature.ID, err)
+		}
+		signature.re = re
+	}
+	return nil
+}
+
+// Match checks URI and payload (optional) against signatures. It's safe to call it on nil ruleset.
+func (r *Ruleset) Match(uri, payload string) Result {
+	var result Result
+	if r == nil {
+		return result
+	}
+
+	if len(payload) > maxPayload {
+		payload = payload[:maxPayload]
+	}
+	path := uri
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	raw := map[string]string{TargetURI: uri, TargetPath: path, TargetPayload: payload}
+	decoded := map[string]string{}
+	for target, text := range raw {
+		decoded[target] = normalize(text)
+	}
+
+	categories := map[string]bool{}
+	for _, signature := range r.Signatures {
+		texts := decoded
+		if signature.Raw {
+			texts = raw
+		}
+		for _, target := range signature.Targets {
+			if texts[target] == "" || !signature.re.MatchString(texts[target]) {
+				continue
+			}
+
+			result.Matched = append(result.Matched, signature.ID)
+			if !categories[signature.Category] {
+				categories[signature.Category] = true
+				result.Signals.Add(SignalPrefix+signature.Category, weights[signature.Category])
+			}
+			break
+		}
+	}
+	return result
+}
+
+// normalize decodes URL encoding (several times) and HTML entities, and converts text to lower case.
+func normalize(text string) string {
+	for i := 0; i < maxDecode && strings.Contains(text, "%"); i++ {
+		decoded, err := url.QueryUnescape(text)
+		if err != nil {
+			break
+		}
+		text = decoded
+	}
+	return strings.ToLower(html.UnescapeString(text))
+}
+
+func builtin() []*Signature {
+	return []*Signature{
+		{ID: "sqli-union", Category: CategorySQLInjection, Pattern: `union(\s|/\*.*?\*/)+(all(\s|/\*.*?\*/)+)?select\b`},
+		{ID: "sqli-tautology", Category: CategorySQLInjection, Pattern: `['"]\s*(or|and)\s+['"]?(\w+)['"]?\s*(=|like)\s*['"]?\w+`},
+		{ID: "sqli-comment", Category: CategorySQLInjection, Pattern: `'\s*\)?\s*(--(\s|$)|#|/\*)`},
+		{ID: "sqli-function", Category: CategorySQLInjection, Pattern: `\b(sleep|benchmark|pg_sleep|load_file|extractvalue|updatexml)\s*\(|waitfor\s+delay\b|\binto\s+(out|dump)file\b`},
+		{ID: "sqli-stacked", Category: CategorySQLInjection, Pattern: `;\s*(drop|truncate|shutdown|exec(ute)?|xp_cmdshell)\b`},
+		{ID: "sqli-schema", Category: CategorySQLInjection, Pattern: `\binformation_schema\b|\bsys\.(user_)?objects\b|\bpg_catalog\b`},
+
+		{ID: "xss-script", Category: CategoryXSS, Pattern: `<script[\s/>]`},
+		{ID: "xss-handler", Category: CategoryXSS, Pattern: `<[a-z][^>]*[\s/]on[a-z]+\s*=`},
+		{ID: "xss-protocol", Category: CategoryXSS, Pattern: `(javascript|vbscript)\s*:|data:text/html`},
+		{ID: "xss-tag", Category: CategoryXSS, Pattern: `<(iframe|object|embed|base|meta|svg)[\s/>]`},
+
+		{ID: "traversal-dotdot", Category: CategoryTraversal, Pattern: `\.\.[/\\]`},
+		{ID: "traversal-file", Category: CategoryTraversal, Pattern: `/etc/(passwd|shadow|group|hosts)\b|/proc/self/|c:\\windows\\|\bboot\.ini\b|\bweb\.config\b`},
+
+		{ID: "cmd-chain", Category: CategoryCommandInjection, Pattern: "(;|\\|\\|?|&&|\\$\\(|`)\\s*(cat|ls|id|whoami|uname|wget|curl|nc|ncat|bash|sh|ping|powershell|cmd)(\\s+[-/]|\\s*$|\\s*[;|&)`])"},
+		{ID: "cmd-shellshock", Category: CategoryCommandInjection, Pattern: `\(\)\s*\{\s*:;\s*\}`},
+		{ID: "cmd-ifs", Category: CategoryCommandInjection, Pattern: `\$\{ifs\}|\$ifs\$`},
+
+		{ID: "scanner-wordpress", Category: CategoryScanner, Pattern: `/(wp-login\.php|xmlrpc\.php|wp-config\.php)$`, Targets: []string{TargetPath}},
+		{ID: "scanner-dotfile", Category: CategoryScanner, Pattern: `/\.(env\b|git(/|$)|svn(/|$)|hg(/|$)|ds_store|htaccess|htpasswd|aws/|ssh/)`, Targets: []string{TargetPath}},
+		{ID: "scanner-admin", Category: CategoryScanner, Pattern: `/(phpmyadmin|pma|myadmin|adminer\.php|server-status|actuator/|cgi-bin/|phpinfo\.php)`, Targets: []string{TargetPath}},
+		{ID: "scanner-backup", Category: CategoryScanner, Pattern: `\.(bak|old|orig|sql|swp)$`, Targets: []string{TargetPath}},
+
+		{ID: "evasion-double-encoding", Category: CategoryEvasion, Pattern: `(?i)%25[0-9a-f]{2}`, Raw: true},
+		{ID: "evasion-overlong-utf8", Category: CategoryEvasion, Pattern: `(?i)%c0%a[ef]|%e0%80%af|%c1%9c|%c1%1c`, Raw: true},
+		{ID: "evasion-unicode", Category: CategoryEvasion, Pattern: `(?i)%u[0-9a-f]{4}`, Raw: true},
+		{ID: "evasion-null-byte", Category: CategoryEvasion, Pattern: `%00|\\x00|\\u0000`, Raw: true},
+	}
+}
@@ -0,0 +1,93 @@
+package signature
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultMatch(t *testing.T) {
+	ruleset := Default()
+
+	tests := []struct {
+		name        string
+		uri         string
+		payload     string
+		wantSignals []string
+	}{
+		{"clean", "/products/list?page=2&sort=price", "", nil},
+		{"clean payload", "/api/profile", `{"name": "Tom and Jerry", "color": "#fff", "bio": "dogs; cat food"}`, nil},
+		{"clean download", "/files/report.zip", "", nil},
+		{"union select", "/item?id=1%20UNION%20ALL%20SELECT%20password%20FROM%20users", "", []string{"attack_sqli"}},
+		{"tautology", "/login", "user=admin' OR '1'='1", []string{"attack_sqli"}},
+		{"sleep", "/item?id=1+AND+SLEEP(5)", "", []string{"attack_sqli"}},
+		{"script", "/search?q=%3Cscript%3Ealert(1)%3C/script%3E", "", []string{"attack_xss"}},
+		{"event handler", "/comment", `text=<img src=x onerror=alert(1)>`, []string{"attack_xss"}},
+		{"html entities", "/comment", `text=&lt;svg/onload=alert(1)&gt;`, []string{"attack_xss"}},
+		{"traversal", "/download?file=../../etc/passwd", "", []string{"attack_traversal"}},
+		{"command", "/ping?host=127.0.0.1;cat%20/etc/hosts", "", []string{"attack_traversal", "attack_command_injection"}},
+		{"shellshock", "/cgi", "() { :; }; /bin/bash -c id", []string{"attack_command_injection"}},
+		{"wp-login", "/wp-login.php", "", []string{"attack_scanner"}},
+		{"env", "/.env", "", []string{"attack_scanner"}},
+		{"git config", "/.git/config", "", []string{"attack_scanner"}},
+		{"scanner path in query only", "/search?q=/.env", "", nil},
+		{"double encoding", "/download?file=%252e%252e%252fetc%252fpasswd", "", []string{"attack_traversal", "attack_evasion"}},
+		{"overlong utf8", "/download?file=..%c0%af..%c0%afwindows", "", []string{"attack_evasion"}},
+		{"null byte", "/image?file=shell.php%00.png", "", []string{"attack_evasion"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ruleset.Match(tt.uri, tt.payload)
+			assert.ElementsMatch(t, tt.wantSignals, result.Signals.Names())
+			assert.Equal(t, len(tt.wantSignals) > 0, len(result.Matched) > 0)
+		})
+	}
+}
+
+func TestMatchNil(t *testing.T) {
+	var ruleset *Ruleset
+	assert.Empty(t, ruleset.Match("/.env", "").Matched)
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signatures")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(content string) string {
+		filename := filepath.Join(dir, "signatures.json")
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(content), 0600))
+		return filename
+	}
+
+	_, err = Load(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+
+	invalid := []string{
+		`{"signatures": [`,
+		`{"signatures": [{"category": "xss", "pattern": "<x"}]}`,
+		`{"signatures": [{"id": "a", "category": "xss", "pattern": "<x"}, {"id": "a", "category": "xss", "pattern": "<y"}]}`,
+		`{"signatures": [{"id": "a", "category": "unknown", "pattern": "<x"}]}`,
+		`{"signatures": [{"id": "a", "category": "xss", "pattern": "<x", "targets": ["header"]}]}`,
+		`{"signatures": [{"id": "a", "category": "xss", "pattern": "(<x"}]}`,
+	}
+	for _, content := range invalid {
+		_, err := Load(write(content))
+		assert.Error(t, err, content)
+	}
+
+	ruleset, err := Load(write(`{"signatures": [
+		{"id": "scanner-custom", "category": "scanner", "pattern": "^/secret-admin", "targets": ["path"]}
+	]}`))
+	assert.NoError(t, err)
+
+	result := ruleset.Match("/secret-admin/login", "")
+	assert.Equal(t, []string{"scanner-custom"}, result.Matched)
+	assert.Equal(t, []string{"attack_scanner"}, result.Signals.Names())
+
+	// file replaces built-in signatures
+	assert.Empty(t, ruleset.Match("/.env", "").Matched)
+}
@@ -0,0 +1,242 @@
+// Package velocity counts requests per key (IP address, subnet, IP and user agent, host and URI, email domain)
+// in sliding windows of 1 minute, 10 minutes and 1 hour, and raises signals when limits are exceeded.
+// Counters are kept in memory of a single instance, number of tracked keys is bounded by LRU cache.
+package velocity
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/optimatiq/threatbite/scoring"
+)
+
+// Signals raised for keys, which exceeded limits.
+const (
+	SignalBurst            = "burst"
+	SignalScraping         = "scraping"
+	SignalSubnetBurst      = "subnet_burst"
+	SignalURIBurst         = "uri_burst"
+	SignalEmailDomainBurst = "email_domain_burst"
+)
+
+// Weights of the velocity signals.
+const (
+	burstWeight            = -20
+	scrapingWeight         = -20
+	subnetBurstWeight      = -15
+	uriBurstWeight         = -10
+	emailDomainBurstWeight = -20
+)
+
+// DefaultSize is the default number of tracked keys, each key takes about 0.5 KB.
+const DefaultSize = 65536
+
+// buckets is a number of 1 minute buckets, the longest window is 1 hour.
+const buckets = 60
+
+// Counts contains number of observations in windows.
+type Counts struct {
+	Minute     uint32
+	TenMinutes uint32
+	Hour       uint32
+}
+
+// Limit contains maximal number of observations in windows, zero disables the window.
+type Limit struct {
+	Minute     uint32
+	TenMinutes uint32
+	Hour       uint32
+}
+
+// exceeded returns true when any window exceeds the limit, short is set for 1 minute window.
+func (l Limit) exceeded(c Counts) (short, long bool) {
+	short = l.Minute > 0 && c.Minute > l.Minute
+	long = (l.TenMinutes > 0 && c.TenMinutes > l.TenMinutes) || (l.Hour > 0 && c.Hour > l.Hour)
+	return short, long
+}
+
+// Limits contains limits for all kinds of keys.
+type Limits struct {
+	IP          Limit
+	IPUserAgent Limit
+	Subnet      Limit
+	URI         Limit
+	EmailDomain Limit
+}
+
+// window is a ring of 1 minute buckets, minutes contains the minute (since epoch) of each bucket.
+type window struct {
+	minutes [buckets]uint32
+	counts  [buckets]uint32
+}
+
+func (w *window) add(minute uint32) Counts {
+	i := minute % buckets
+	if w.minutes[i] != minute {
+		w.minutes[i] = minute
+		w.counts[i] = 0
+	}
+	w.counts[i]++
+	return w.sum(minute)
+}
+
+func (w *window) sum(minute uint32) Counts {
+	var c Counts
+	for j := range w.minutes {
+		// empty buckets and buckets from the future (clock moved back) are skipped
+		age := minute - w.minutes[j]
+		if w.minutes[j] == 0 || age >= buckets {
+			continue
+		}
+		count := w.counts[j]
+		c.Hour += count
+		if age < 10 {
+			c.TenMinutes += count
+		}
+		if age == 0 {
+			c.Minute += count
+		}
+	}
+	return c
+}
+
+// Tracker counts observations per key.
+type Tracker struct {
+	mu    sync.Mutex
+	cache *lru.Cache
+	now   func() time.Time
+}
+
+// NewTracker creates tracker, which keeps counters for given number of the most recently used keys.
+func NewTracker(size int) (*Tracker, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &Tracker{cache: cache, now: time.Now}, nil
+}
+
+// Observe counts an observation of the key and returns counts including it.
+func (t *Tracker) Observe(key string) Counts {
+	minute := uint32(t.now().Unix() / 60)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var w *window
+	if v, ok := t.cache.Get(key); ok {
+		w = v.(*window)
+	} else {
+		w = &window{}
+		t.cache.Add(key, w)
+	}
+	return w.add(minute)
+}
+
+// Counts returns counts of the key without counting an observation.
+func (t *Tracker) Counts(key string) Counts {
+	minute := uint32(t.now().Unix() / 60)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if v, ok := t.cache.Peek(key); ok {
+		return v.(*window).sum(minute)
+	}
+	return Counts{}
+}
+
+// Observation contains keys of a single request, empty values are not counted.
+type Observation struct {
+	IP        net.IP
+	UserAgent string
+	Host      string
+	URI       string
+}
+
+// Velocity counts observations and compares them with limits.
+type Velocity struct {
+	tracker *Tracker
+	limits  Limits
+}
+
+// New creates velocity subsystem with given limits.
+func New(limits Limits, size int) (*Velocity, error) {
+	tracker, err := NewTracker(size)
+	if err != nil {
+		return nil, err
+	}
+	return &Velocity{tracker: tracker, limits: limits}, nil
+}
+
+// Observe counts the request and returns signals for exceeded limits. It's safe to call it on nil Velocity.
+// Exceeded 1 minute window is reported as a burst, longer windows as scraping.
+func (v *Velocity) Observe(o Observation) scoring.Signals {
+	var signals scoring.Signals
+	if v == nil {
+		return signals
+	}
+
+	var burst, scraping bool
+	if o.IP != nil {
+		ip := o.IP.String()
+		short, long := v.limits.IP.exceeded(v.tracker.Observe("ip:" + ip))
+		burst, scraping = burst || short, scraping || long
+
+		if o.UserAgent != "" {
+			short, long = v.limits.IPUserAgent.exceeded(v.tracker.Observe("ipua:" + ip + "|" + o.UserAgent))
+			burst, scraping = burst || short, scraping || long
+		}
+
+		short, long = v.limits.Subnet.exceeded(v.tracker.Observe("net:" + Subnet(o.IP)))
+		if short || long {
+			signals.Add(SignalSubnetBurst, subnetBurstWeight)
+		}
+	}
+	if burst {
+		signals.Add(SignalBurst, burstWeight)
+	}
+	if scraping {
+		signals.Add(SignalScraping, scrapingWeight)
+	}
+
+	if o.Host != "" && o.URI != "" {
+		// query is not a part of the key, otherwise a random parameter would give each request its own counter
+		uri := o.URI
+		if i := strings.IndexByte(uri, '?'); i >= 0 {
+			uri = uri[:i]
+		}
+		short, long := v.limits.URI.exceeded(v.tracker.Observe("uri:" + strings.ToLower(o.Host) + uri))
+		if short || long {
+			signals.Add(SignalURIBurst, uriBurstWeight)
+		}
+	}
+
+	return signals
+}
+
+// ObserveEmailDomain counts email domain and returns signal when the limit is exceeded.
+// It's safe to call it on nil Velocity.
+func (v *Velocity) ObserveEmailDomain(domain string) scoring.Signals {
+	var signals scoring.Signals
+	if v == nil || domain == "" {
+		return signals
+	}
+
+	short, long := v.limits.EmailDomain.exceeded(v.tracker.Observe("domain:" + strings.ToLower(domain)))
+	if short || long {
+		signals.Add(SignalEmailDomainBurst, emailDomainBurstWeight)
+	}
+	return signals
+}
+
+// Subnet returns /24 network for IPv4 and /64 network for IPv6 address.
+func Subnet(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
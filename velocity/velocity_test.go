@@ -0,0 +1,98 @@
+package velocity
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTracker(t *testing.T, now *time.Time) *Tracker {
+	tracker, err := NewTracker(16)
+	assert.NoError(t, err)
+	tracker.now = func() time.Time { return *now }
+	return tracker
+}
+
+func TestTrackerWindows(t *testing.T) {
+	now := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(t, &now)
+
+	assert.Equal(t, Counts{Minute: 1, TenMinutes: 1, Hour: 1}, tracker.Observe("a"))
+	assert.Equal(t, Counts{Minute: 2, TenMinutes: 2, Hour: 2}, tracker.Observe("a"))
+	assert.Equal(t, Counts{Minute: 1, TenMinutes: 1, Hour: 1}, tracker.Observe("b"))
+
+	now = now.Add(5 * time.Minute)
+	assert.Equal(t, Counts{Minute: 1, TenMinutes: 3, Hour: 3}, tracker.Observe("a"))
+
+	now = now.Add(10 * time.Minute)
+	assert.Equal(t, Counts{Minute: 1, TenMinutes: 1, Hour: 4}, tracker.Observe("a"))
+
+	// buckets older than 1 hour are reused
+	now = now.Add(50 * time.Minute)
+	assert.Equal(t, Counts{Minute: 1, TenMinutes: 1, Hour: 2}, tracker.Observe("a"))
+
+	now = now.Add(2 * time.Hour)
+	assert.Equal(t, Counts{Minute: 1, TenMinutes: 1, Hour: 1}, tracker.Observe("a"))
+	assert.Equal(t, Counts{Minute: 1, TenMinutes: 1, Hour: 1}, tracker.Counts("a"))
+	assert.Equal(t, Counts{}, tracker.Counts("missing"))
+}
+
+func TestTrackerBounded(t *testing.T) {
+	now := time.Now()
+	tracker := newTestTracker(t, &now)
+	for i := 0; i < 100; i++ {
+		tracker.Observe(string(rune('a' + i)))
+	}
+	assert.Equal(t, 16, tracker.cache.Len())
+}
+
+func TestVelocityObserve(t *testing.T) {
+	v, err := New(Limits{
+		IP:          Limit{Minute: 3, Hour: 5},
+		IPUserAgent: Limit{TenMinutes: 10},
+		Subnet:      Limit{Minute: 4},
+		URI:         Limit{Minute: 6},
+		EmailDomain: Limit{Hour: 2},
+	}, 64)
+	assert.NoError(t, err)
+	now := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	v.tracker.now = func() time.Time { return now }
+
+	observe := func(ip string) []string {
+		return v.Observe(Observation{IP: net.ParseIP(ip), UserAgent: "curl/7.68.0", Host: "example.com", URI: "/login"}).Names()
+	}
+
+	assert.Empty(t, observe("192.0.2.1"))
+	assert.Empty(t, observe("192.0.2.1"))
+	assert.Empty(t, observe("192.0.2.1"))
+	assert.Equal(t, []string{SignalBurst}, observe("192.0.2.1"))
+	assert.Equal(t, []string{SignalSubnetBurst}, observe("192.0.2.2"))
+	assert.Empty(t, observe("198.51.100.1"))
+	assert.Equal(t, []string{SignalURIBurst}, observe("203.0.113.1"))
+
+	// query doesn't change the URI key
+	uri := v.Observe(Observation{Host: "example.com", URI: "/login?next=/a"}).Names()
+	assert.Equal(t, []string{SignalURIBurst}, uri)
+	uri = v.Observe(Observation{Host: "example.com", URI: "/login?next=/b"}).Names()
+	assert.Equal(t, []string{SignalURIBurst}, uri)
+
+	// 1 minute windows are empty, but IP address is still over the limit per hour
+	now = now.Add(time.Minute)
+	assert.Empty(t, observe("192.0.2.1"))
+	assert.Equal(t, []string{SignalScraping}, observe("192.0.2.1"))
+
+	assert.Empty(t, v.ObserveEmailDomain("example.com").Names())
+	assert.Empty(t, v.ObserveEmailDomain("EXAMPLE.com").Names())
+	assert.Equal(t, []string{SignalEmailDomainBurst}, v.ObserveEmailDomain("example.com").Names())
+	assert.Empty(t, v.ObserveEmailDomain("").Names())
+
+	var disabled *Velocity
+	assert.Empty(t, disabled.Observe(Observation{IP: net.ParseIP("192.0.2.1")}))
+}
+
+func TestSubnet(t *testing.T) {
+	assert.Equal(t, "192.0.2.0/24", Subnet(net.ParseIP("192.0.2.77")))
+	assert.Equal(t, "2001:db8:1:2::/64", Subnet(net.ParseIP("2001:db8:1:2:3:4:5:6")))
+}